@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcessStats aggregates the tasks of a single process
+type ProcessStats struct {
+	Process       string
+	Tasks         int
+	Runtime       time.Duration // total runtime of all tasks
+	CPUHours      float64       // CPU time used: %cpu times runtime, or allocated CPUs without %cpu
+	MemoryGBHours float64       // peak memory (RSS) in GiB times runtime
+}
+
+// MeanRuntime returns the average runtime per task
+func (p *ProcessStats) MeanRuntime() time.Duration {
+	if p.Tasks == 0 {
+		return 0
+	}
+	return p.Runtime / time.Duration(p.Tasks)
+}
+
+// aggregateByProcess groups records by process, preserving the order in which
+// processes first appear in the trace
+func aggregateByProcess(records []TraceRecord) []*ProcessStats {
+	var order []*ProcessStats
+	byProcess := make(map[string]*ProcessStats)
+	for _, rec := range records {
+		stats, ok := byProcess[rec.Process]
+		if !ok {
+			stats = &ProcessStats{Process: rec.Process}
+			byProcess[rec.Process] = stats
+			order = append(order, stats)
+		}
+		stats.Tasks++
+		stats.Runtime, _ = addDurations(stats.Runtime, rec.Runtime())
+		stats.CPUHours += taskCPUHours(rec)
+		stats.MemoryGBHours += float64(rec.PeakRSS) / (1 << 30) * rec.Runtime().Hours()
+	}
+	return order
+}
+
+// taskCPUHours returns the CPU time a task used in hours, from %cpu or, if
+// it was not recorded or is implausible, the allocated CPUs
+func taskCPUHours(rec TraceRecord) float64 {
+	if rec.HasCPUPercent && rec.CPUSuspect == "" {
+		return rec.CPUPercent / 100 * rec.Runtime().Hours()
+	}
+	return float64(max(rec.CPUs, 1)) * rec.Runtime().Hours()
+}
+
+// processShares returns the share of every process in the CPU time and
+// memory-GB-hours of the run, in percent, by process
+func processShares(stats []*ProcessStats) (cpu, memory map[string]float64) {
+	var totalCPU, totalMemory float64
+	for _, s := range stats {
+		totalCPU += s.CPUHours
+		totalMemory += s.MemoryGBHours
+	}
+	cpu, memory = make(map[string]float64), make(map[string]float64)
+	for _, s := range stats {
+		if totalCPU > 0 {
+			cpu[s.Process] = 100 * s.CPUHours / totalCPU
+		}
+		if totalMemory > 0 {
+			memory[s.Process] = 100 * s.MemoryGBHours / totalMemory
+		}
+	}
+	return cpu, memory
+}
+
+// countSamples estimates the number of samples in a run from the task tags:
+// per-sample processes have one tag per sample, so the most common number
+// of distinct tags among processes with more than one tag is taken, the
+// larger one on ties. Runs without such processes count as one sample.
+func countSamples(records []TraceRecord) int {
+	frequency := make(map[int]int)
+	for _, processTags := range tagsByProcess(records) {
+		if len(processTags) > 1 {
+			frequency[len(processTags)]++
+		}
+	}
+	samples := 1
+	for count, n := range frequency {
+		if n > frequency[samples] || (n == frequency[samples] && count > samples) {
+			samples = count
+		}
+	}
+	return samples
+}
+
+// tagsByProcess returns the distinct task tags of every process
+func tagsByProcess(records []TraceRecord) map[string]map[string]bool {
+	tags := make(map[string]map[string]bool)
+	for _, rec := range records {
+		if rec.Tag == "" {
+			continue
+		}
+		if tags[rec.Process] == nil {
+			tags[rec.Process] = make(map[string]bool)
+		}
+		tags[rec.Process][rec.Tag] = true
+	}
+	return tags
+}
+
+// parseSampleCounts parses a --normalize-by value: "samples" or
+// "samples=auto" to detect the number of samples of each run from the task
+// tags, "samples=N" for runs of equal size or "samples=OLD,NEW". Zero means
+// the count is detected.
+func parseSampleCounts(value string) ([2]int, error) {
+	var counts [2]int
+	key, spec, _ := strings.Cut(value, "=")
+	if key != "samples" {
+		return counts, fmt.Errorf("unsupported normalization '%s' (use samples, samples=N or samples=OLD,NEW)", value)
+	}
+	if spec == "" || spec == "auto" {
+		return counts, nil
+	}
+	parts := strings.Split(spec, ",")
+	if len(parts) > len(counts) {
+		return counts, fmt.Errorf("unsupported normalization '%s' (use samples, samples=N or samples=OLD,NEW)", value)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 {
+			return counts, fmt.Errorf("invalid sample count '%s'", part)
+		}
+		counts[i] = n
+	}
+	if len(parts) == 1 {
+		counts[1] = counts[0]
+	}
+	return counts, nil
+}
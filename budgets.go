@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadBudgets reads a YAML mapping of process names or regular expressions
+// to their limits, e.g.
+//
+//	STAR_ALIGN:
+//	  p95_time: 2h
+//	  peak_memory: 40 GB
+//	  failure_rate: 5%
+//	"SAMTOOLS_.*": {p95_time: 10m, failure_rate: 0}
+//
+// Only this subset of YAML (a mapping of mappings of scalars) is supported.
+func loadBudgets(filePath string) (budgetList, error) {
+	mappings, err := loadYAMLMappings(filePath, "budgets")
+	if err != nil {
+		return nil, err
+	}
+	var budgets budgetList
+	for _, m := range mappings {
+		current, err := budgets.get(m.Name)
+		if err != nil {
+			return nil, fmt.Errorf("budgets file line %d: %w", m.Line, err)
+		}
+		for _, e := range m.Entries {
+			if err := current.set(e.Key, e.Value); err != nil {
+				return nil, fmt.Errorf("budgets file line %d: %w", e.Line, err)
+			}
+		}
+	}
+	return budgets, nil
+}
+
+// yamlMapping is a key of a YAML mapping of mappings with its entries
+type yamlMapping struct {
+	Name    string
+	Line    int
+	Entries []yamlEntry
+}
+
+// yamlEntry is a key and unquoted scalar value of a nested mapping
+type yamlEntry struct {
+	Line       int
+	Key, Value string
+}
+
+// loadYAMLMappings reads a YAML mapping of keys to mappings of scalars, in
+// flow ({a: 1, b: 2}) or block style, preserving the order of the keys.
+// Keys may contain colons when quoted. What names the kind of file in
+// errors.
+func loadYAMLMappings(filePath, what string) ([]yamlMapping, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s file: %w", what, err)
+	}
+	defer file.Close()
+
+	var mappings []yamlMapping
+	addEntry := func(lineNum int, item string) error {
+		if len(mappings) == 0 {
+			return fmt.Errorf("%s file line %d: entry outside of a key", what, lineNum)
+		}
+		key, value, ok := strings.Cut(item, ":")
+		if !ok {
+			return fmt.Errorf("%s file line %d: expected 'key: value'", what, lineNum)
+		}
+		m := &mappings[len(mappings)-1]
+		m.Entries = append(m.Entries, yamlEntry{Line: lineNum, Key: strings.TrimSpace(key), Value: unquoteYAML(strings.TrimSpace(value))})
+		return nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := stripYAMLComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if err := addEntry(lineNum, trimmed); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		key, value := trimmed, ""
+		if strings.HasPrefix(trimmed, `"`) || strings.HasPrefix(trimmed, "'") {
+			if end := quotedKeyEnd(trimmed); end >= 0 {
+				key, value = trimmed[:end], trimmed[end:]
+			}
+		} else if i := strings.IndexByte(trimmed, ':'); i >= 0 {
+			key, value = trimmed[:i], trimmed[i:]
+		}
+		value, ok := strings.CutPrefix(strings.TrimSpace(value), ":")
+		if !ok {
+			return nil, fmt.Errorf("%s file line %d: expected 'key:'", what, lineNum)
+		}
+		mappings = append(mappings, yamlMapping{Name: unquoteYAML(strings.TrimSpace(key)), Line: lineNum})
+
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+		case strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}"):
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					if err := addEntry(lineNum, item); err != nil {
+						return nil, err
+					}
+				}
+			}
+		default:
+			return nil, fmt.Errorf("%s file line %d: expected the entries of %s on the following lines", what, lineNum, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s file: %w", what, err)
+	}
+
+	return mappings, nil
+}
+
+// measureBudgets records the current performance of every process as a
+// budget, in the order processes first appear in the trace
+func measureBudgets(records []TraceRecord) budgetList {
+	var budgets budgetList
+	for _, stats := range aggregateByProcess(records) {
+		b, err := budgets.get(regexp.QuoteMeta(stats.Process))
+		if err != nil {
+			continue
+		}
+		var runtimes []float64
+		failed := 0
+		for _, rec := range records {
+			if rec.Process != stats.Process {
+				continue
+			}
+			runtimes = append(runtimes, float64(rec.Runtime()))
+			b.MaxMemory = max(b.MaxMemory, rec.PeakRSS)
+			if rec.Status != "" && !isSuccess(rec.Status) {
+				failed++
+			}
+		}
+		b.MaxTime = time.Duration(percentile(runtimes, 95))
+		b.MaxFailureRate, b.HasFailureRate = float64(failed)/float64(len(runtimes)), true
+	}
+	return budgets
+}
+
+// withTolerance returns the budgets with every limit raised by the given
+// fraction, e.g. 0.1 for 10%
+func (l budgetList) withTolerance(tolerance float64) budgetList {
+	raised := make(budgetList, len(l))
+	for i, b := range l {
+		r := *b
+		r.MaxTime = time.Duration(float64(b.MaxTime) * (1 + tolerance))
+		r.MaxMemory = int64(float64(b.MaxMemory) * (1 + tolerance))
+		r.MaxFailureRate = math.Min(b.MaxFailureRate*(1+tolerance), 1)
+		raised[i] = &r
+	}
+	return raised
+}
+
+// ceilDuration rounds a duration up to the precision of FormatDuration, so
+// that a baseline is not exceeded by the run it was recorded from
+func ceilDuration(d time.Duration) time.Duration {
+	precision := time.Second
+	if d < time.Second {
+		precision = time.Millisecond
+	}
+	if rounded := d.Truncate(precision); rounded < d {
+		return rounded + precision
+	}
+	return d
+}
+
+// ceilSize formats a size like FormatSize, rounded up instead of to the
+// nearest value
+func ceilSize(bytes int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	value := float64(bytes)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.1f %s", math.Ceil(value*10)/10, units[i])
+}
+
+// writeBaseline writes budgets measured from a trace as a budgets file,
+// with the trace and nfu version it was recorded from as comments, so that
+// the baseline can be committed and its updates reviewed
+func writeBaseline(path string, trace *Trace, budgets budgetList) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Performance baseline recorded by nfu %s on %s\n", version, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# from %s", trace.Path)
+	if sum, err := fileSHA256(trace.Path); err == nil {
+		fmt.Fprintf(&b, " (sha256 %s)", sum)
+	}
+	fmt.Fprintf(&b, "\n# Update with: nfu check -i <trace> --baseline %s --update-baseline\n", path)
+	for _, budget := range budgets {
+		fmt.Fprintf(&b, "%s:\n", quoteYAML(budget.Process))
+		if budget.MaxTime > 0 {
+			fmt.Fprintf(&b, "  %s: %s\n", limitTime, formatDurationUnits(ceilDuration(budget.MaxTime), 3))
+		}
+		if budget.MaxMemory > 0 {
+			fmt.Fprintf(&b, "  %s: %s\n", limitMemory, ceilSize(budget.MaxMemory))
+		}
+		if budget.HasFailureRate {
+			fmt.Fprintf(&b, "  %s: %s%%\n", limitFailureRate, strconv.FormatFloat(100*budget.MaxFailureRate, 'f', -1, 64))
+		}
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("error writing baseline: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// gib is the unit of input sizes in fitted scaling functions
+const gib = 1 << 30
+
+// stagedInputSize returns the size of the inputs staged into a task
+// directory, which Nextflow links into it: links to files count with the
+// size of their target, linked directories with the size of their contents
+func stagedInputSize(dir string) int64 {
+	var size int64
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSymlink == 0 {
+			continue
+		}
+		target := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(target)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			size += info.Size()
+			continue
+		}
+		filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				if info, err := d.Info(); err == nil {
+					size += info.Size()
+				}
+			}
+			return nil
+		})
+	}
+	return size
+}
+
+// taskInputSizes returns the input size of every task, keyed by task hash,
+// from the staged inputs in the work directory
+func taskInputSizes(records []TraceRecord, workRoot string) map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, rec := range records {
+		if dir, err := resolveWorkDir(workRoot, rec.Hash); err == nil {
+			if size := stagedInputSize(dir); size > 0 {
+				sizes[rec.Hash] = size
+			}
+		}
+	}
+	return sizes
+}
+
+// sampleInputSizes returns the input size of every sample of a samplesheet
+func sampleInputSizes(path string) (map[string]int64, error) {
+	samples, err := readSamplesheet(path)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	sizes := make(map[string]int64)
+	for _, s := range samples {
+		for i, f := range s.Files {
+			s.Files[i] = statInput(client, f.Path)
+		}
+		if size := s.Size(); size > 0 {
+			sizes[s.Sample] = size
+		}
+	}
+	return sizes, nil
+}
+
+// scalingFit relates the runtime and peak memory of the tasks of a process
+// to their input size in GiB
+type scalingFit struct {
+	Process string
+	Tasks   int
+	Runtime regression // seconds per GiB
+	Memory  regression // bytes per GiB
+	HasMem  bool
+}
+
+// fitScaling fits runtime and memory against input size for every process
+// with at least minTasks successful tasks of known, not all equal, size
+func fitScaling(records []TraceRecord, inputSize func(TraceRecord) (int64, bool), minTasks int) []*scalingFit {
+	type samples struct{ size, runtime, memSize, memory []float64 }
+	var order []string
+	byProcess := make(map[string]*samples)
+	for _, rec := range records {
+		size, ok := inputSize(rec)
+		if !ok || (rec.Status != "" && !isSuccess(rec.Status)) || rec.Runtime() <= 0 {
+			continue
+		}
+		s := byProcess[rec.Process]
+		if s == nil {
+			s = &samples{}
+			byProcess[rec.Process] = s
+			order = append(order, rec.Process)
+		}
+		x := float64(size) / gib
+		s.size = append(s.size, x)
+		s.runtime = append(s.runtime, rec.Runtime().Seconds())
+		if rec.PeakRSS > 0 {
+			s.memSize = append(s.memSize, x)
+			s.memory = append(s.memory, float64(rec.PeakRSS))
+		}
+	}
+
+	var fits []*scalingFit
+	for _, process := range order {
+		s := byProcess[process]
+		if len(s.size) < minTasks || percentile(s.size, 0) == percentile(s.size, 100) {
+			continue
+		}
+		fit := &scalingFit{Process: process, Tasks: len(s.size), Runtime: linearRegression(s.size, s.runtime)}
+		if len(s.memory) >= minTasks {
+			fit.Memory, fit.HasMem = linearRegression(s.memSize, s.memory), true
+		}
+		fits = append(fits, fit)
+	}
+	return fits
+}
+
+// printScalingConfig prints the fits as dynamic directives of a Nextflow
+// configuration, raised by margin
+func printScalingConfig(fits []*scalingFit, margin float64) {
+	size := fmt.Sprintf("(reads*.size().sum() / %d)", gib)
+	fmt.Println("// Fitted by nfu calibrate; replace 'reads' by the input files of each process")
+	fmt.Println("process {")
+	for _, fit := range fits {
+		fmt.Printf("    withName: '%s' {\n", shortProcessName(fit.Process))
+		fmt.Printf("        time   = { 1.sec * Math.ceil(%.0f + %.1f * %s) * task.attempt }\n",
+			max(fit.Runtime.Intercept*margin, 60), max(fit.Runtime.Slope*margin, 0), size)
+		if fit.HasMem {
+			fmt.Printf("        memory = { 1.MB * Math.ceil(%.0f + %.1f * %s) * task.attempt }\n",
+				max(fit.Memory.Intercept*margin/(1<<20), 100), max(fit.Memory.Slope*margin/(1<<20), 0), size)
+		}
+		fmt.Println("    }")
+	}
+	fmt.Println("}")
+}
+
+// runCalibrate implements the "calibrate" subcommand, fitting the runtime
+// and memory of every process against the size of its inputs
+func runCalibrate(args []string) error {
+	fs := newFlagSet("calibrate")
+	input := inputFlags(fs)
+	workRoot := fs.String("w", "", "Work directory of the run, to measure the inputs staged into every task")
+	fs.StringVar(workRoot, "work-dir", "", "Work directory of the run, to measure the inputs staged into every task")
+	samplesheet := fs.String("samplesheet", "", "Samplesheet of the run, to relate the tasks of each sample (by tag) to its input size")
+	minTasks := fs.Int("min-tasks", 5, "Minimum number of tasks of known input size to fit a process")
+	config := fs.Bool("config", false, "Print the fits as dynamic directives of a Nextflow configuration")
+	margin := fs.Float64("margin", 1.2, "Factor applied to the fits in the --config output")
+	fs.Parse(args)
+
+	if (*workRoot == "") == (*samplesheet == "") {
+		return fmt.Errorf("give the input sizes with either --work-dir or --samplesheet")
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	var inputSize func(TraceRecord) (int64, bool)
+	if *workRoot != "" {
+		sizes := taskInputSizes(trace.Records, *workRoot)
+		inputSize = func(rec TraceRecord) (int64, bool) { size, ok := sizes[rec.Hash]; return size, ok }
+	} else {
+		sizes, err := sampleInputSizes(*samplesheet)
+		if err != nil {
+			return err
+		}
+		inputSize = func(rec TraceRecord) (int64, bool) { size, ok := sizes[rec.Tag]; return size, ok }
+	}
+
+	fits := fitScaling(trace.Records, inputSize, *minTasks)
+	if len(fits) == 0 {
+		return fmt.Errorf("no process with at least %d tasks of different known input sizes", *minTasks)
+	}
+	if *config {
+		printScalingConfig(fits, *margin)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tRUNTIME\tR²\tPEAK MEMORY\tR²")
+	for _, fit := range fits {
+		runtime := fmt.Sprintf("%s %s/GiB", FormatDuration(time.Duration(fit.Runtime.Intercept*float64(time.Second))),
+			FormatSignedDuration(time.Duration(fit.Runtime.Slope*float64(time.Second))))
+		memory, memR2 := "-", "-"
+		if fit.HasMem {
+			slope := "+" + FormatSize(int64(fit.Memory.Slope))
+			if fit.Memory.Slope < 0 {
+				slope = "-" + FormatSize(int64(-fit.Memory.Slope))
+			}
+			memory = fmt.Sprintf("%s %s/GiB", FormatSize(int64(fit.Memory.Intercept)), slope)
+			memR2 = fmt.Sprintf("%.2f", fit.Memory.R2)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%.2f\t%s\t%s\n", fit.Process, fit.Tasks, runtime, fit.Runtime.R2, memory, memR2)
+	}
+	return w.Flush()
+}
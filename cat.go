@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// normalizedRecord is a trace record with values in canonical units:
+// durations in milliseconds, memory in bytes and timestamps in RFC 3339.
+// Values missing from the trace are omitted, while a value of 0 is kept.
+type normalizedRecord struct {
+	TaskID      string   `json:"task_id,omitempty"`
+	Hash        string   `json:"hash,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Process     string   `json:"process"`
+	Tag         string   `json:"tag,omitempty"`
+	Status      string   `json:"status,omitempty"`
+	Exit        string   `json:"exit,omitempty"`
+	Attempt     *int     `json:"attempt,omitempty"`
+	CPUs        *int     `json:"cpus,omitempty"`
+	MemoryBytes *int64   `json:"memory_bytes,omitempty"`
+	TimeMs      *int64   `json:"time_ms,omitempty"`
+	Submit      string   `json:"submit,omitempty"`
+	Start       string   `json:"start,omitempty"`
+	Complete    string   `json:"complete,omitempty"`
+	DurationMs  *int64   `json:"duration_ms,omitempty"`
+	RealtimeMs  *int64   `json:"realtime_ms,omitempty"`
+	CPUPercent  *float64 `json:"cpu_percent,omitempty"`
+	PeakRSS     *int64   `json:"peak_rss_bytes,omitempty"`
+	PeakVmem    *int64   `json:"peak_vmem_bytes,omitempty"`
+	Rchar       *int64   `json:"rchar_bytes,omitempty"`
+	Wchar       *int64   `json:"wchar_bytes,omitempty"`
+	Hostname    string   `json:"hostname,omitempty"`
+	CPUSuspect  string   `json:"cpu_suspect,omitempty"`
+	ClockSkew   string   `json:"clock_skew,omitempty"`
+	Incident    string   `json:"incident,omitempty"`
+
+	// Raw values as found in the trace, kept with --raw to audit parsing
+	MemoryRaw     string `json:"memory_raw,omitempty"`
+	TimeRaw       string `json:"time_raw,omitempty"`
+	SubmitRaw     string `json:"submit_raw,omitempty"`
+	StartRaw      string `json:"start_raw,omitempty"`
+	CompleteRaw   string `json:"complete_raw,omitempty"`
+	DurationRaw   string `json:"duration_raw,omitempty"`
+	RealtimeRaw   string `json:"realtime_raw,omitempty"`
+	CPUPercentRaw string `json:"cpu_percent_raw,omitempty"`
+	PeakRSSRaw    string `json:"peak_rss_raw,omitempty"`
+	PeakVmemRaw   string `json:"peak_vmem_raw,omitempty"`
+	RcharRaw      string `json:"rchar_raw,omitempty"`
+	WcharRaw      string `json:"wchar_raw,omitempty"`
+}
+
+// normalizedColumns is the column order of the tab-separated output of "cat"
+var normalizedColumns = []string{
+	"task_id", "hash", "name", "process", "tag", "status", "exit", "attempt", "cpus", "memory_bytes", "time_ms",
+	"submit", "start", "complete", "duration_ms", "realtime_ms", "cpu_percent", "peak_rss_bytes", "peak_vmem_bytes", "rchar_bytes", "wchar_bytes", "hostname",
+}
+
+// rawColumns are the columns of the tab-separated output of "cat --raw"
+// holding the raw values of normalized fields
+var rawColumns = []string{
+	"memory_raw", "time_raw", "submit_raw", "start_raw", "complete_raw", "duration_raw", "realtime_raw",
+	"cpu_percent_raw", "peak_rss_raw", "peak_vmem_raw", "rchar_raw", "wchar_raw",
+}
+
+// formatTimestamp renders a timestamp in RFC 3339, or "" for a missing one
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// normalizeRecord converts a trace record, read with its raw fields, to
+// canonical units. Numbers are only set if the record has a value in their
+// column, as a 0 does not tell a missing value from a genuine one.
+func normalizeRecord(rec TraceRecord, columns []string) normalizedRecord {
+	has := make(map[string]bool, len(columns))
+	for i, col := range columns {
+		if i < len(rec.Fields) {
+			value := strings.TrimSpace(rec.Fields[i])
+			has[col] = value != "" && value != "-"
+		}
+	}
+	number := func(col string, v int64) *int64 {
+		if !has[col] {
+			return nil
+		}
+		return &v
+	}
+	count := func(col string, v int) *int {
+		if !has[col] {
+			return nil
+		}
+		return &v
+	}
+
+	n := normalizedRecord{
+		TaskID:      rec.TaskID,
+		Hash:        rec.Hash,
+		Name:        rec.Name,
+		Process:     rec.Process,
+		Tag:         rec.Tag,
+		Status:      rec.Status,
+		Exit:        rec.Exit,
+		Attempt:     count("attempt", rec.Attempt),
+		CPUs:        count("cpus", rec.CPUs),
+		MemoryBytes: number("memory", rec.Memory),
+		TimeMs:      number("time", rec.Time.Milliseconds()),
+		Submit:      formatTimestamp(rec.Submit),
+		Start:       formatTimestamp(rec.Start),
+		Complete:    formatTimestamp(rec.Complete),
+		DurationMs:  number("duration", rec.Duration.Milliseconds()),
+		RealtimeMs:  number("realtime", rec.Realtime.Milliseconds()),
+		PeakRSS:     number("peak_rss", rec.PeakRSS),
+		PeakVmem:    number("peak_vmem", rec.PeakVmem),
+		Rchar:       number("rchar", rec.Rchar),
+		Wchar:       number("wchar", rec.Wchar),
+		Hostname:    rec.Hostname,
+		CPUSuspect:  rec.CPUSuspect,
+		ClockSkew:   rec.ClockSkew,
+		Incident:    rec.Incident,
+	}
+	if rec.HasCPUPercent {
+		cpuPercent := rec.CPUPercent
+		n.CPUPercent = &cpuPercent
+	}
+	return n
+}
+
+// setRaw keeps the raw values of the normalized fields from the fields of
+// the record as read with the trace columns
+func (n *normalizedRecord) setRaw(columns, fields []string) {
+	for i, col := range columns {
+		if i >= len(fields) {
+			break
+		}
+		value := strings.TrimSpace(fields[i])
+		if value == "-" {
+			value = ""
+		}
+		switch col {
+		case "memory":
+			n.MemoryRaw = value
+		case "time":
+			n.TimeRaw = value
+		case "submit":
+			n.SubmitRaw = value
+		case "start":
+			n.StartRaw = value
+		case "complete":
+			n.CompleteRaw = value
+		case "duration":
+			n.DurationRaw = value
+		case "realtime":
+			n.RealtimeRaw = value
+		case "%cpu":
+			n.CPUPercentRaw = value
+		case "peak_rss":
+			n.PeakRSSRaw = value
+		case "peak_vmem":
+			n.PeakVmemRaw = value
+		case "rchar":
+			n.RcharRaw = value
+		case "wchar":
+			n.WcharRaw = value
+		}
+	}
+}
+
+// rawFields returns the raw values in the order of rawColumns
+func (n normalizedRecord) rawFields() []string {
+	return []string{
+		n.MemoryRaw, n.TimeRaw, n.SubmitRaw, n.StartRaw, n.CompleteRaw, n.DurationRaw, n.RealtimeRaw,
+		n.CPUPercentRaw, n.PeakRSSRaw, n.PeakVmemRaw, n.RcharRaw, n.WcharRaw,
+	}
+}
+
+// fields returns the values of the record in the order of normalizedColumns
+func (n normalizedRecord) fields() []string {
+	number := func(v *int64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatInt(*v, 10)
+	}
+	count := func(v *int) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	}
+	cpuPercent := ""
+	if n.CPUPercent != nil {
+		cpuPercent = strconv.FormatFloat(*n.CPUPercent, 'f', -1, 64)
+	}
+	return []string{
+		n.TaskID, n.Hash, n.Name, n.Process, n.Tag, n.Status, n.Exit,
+		count(n.Attempt), count(n.CPUs), number(n.MemoryBytes), number(n.TimeMs),
+		n.Submit, n.Start, n.Complete, number(n.DurationMs), number(n.RealtimeMs), cpuPercent,
+		number(n.PeakRSS), number(n.PeakVmem), number(n.Rchar), number(n.Wchar), n.Hostname,
+	}
+}
+
+// recordStream writes normalized records as JSON Lines, or as rows of
+// tab-separated values (CSV with --output-format csv) after a header row
+type recordStream struct {
+	out *bufio.Writer
+	enc *json.Encoder
+	csv *csv.Writer
+	raw bool
+}
+
+func newRecordStream(w io.Writer, asJSON, raw bool) *recordStream {
+	s := &recordStream{out: bufio.NewWriter(w), raw: raw}
+	if asJSON {
+		s.enc = json.NewEncoder(s.out)
+		return s
+	}
+	if outputFormat == "csv" {
+		s.csv = csv.NewWriter(s.out)
+	}
+	columns := normalizedColumns
+	if raw {
+		columns = append(columns[:len(columns):len(columns)], rawColumns...)
+	}
+	s.writeFields(columns)
+	return s
+}
+
+// writeFields writes a row; tab-separated values are written as they are,
+// values never contain tabs, while CSV needs quoting of commas in names
+func (s *recordStream) writeFields(fields []string) {
+	if s.csv != nil {
+		s.csv.Write(fields)
+	} else {
+		fmt.Fprintln(s.out, strings.Join(fields, "\t"))
+	}
+}
+
+// Write writes a record
+func (s *recordStream) Write(n normalizedRecord) error {
+	if s.enc != nil {
+		if err := s.enc.Encode(n); err != nil {
+			return fmt.Errorf("error writing record: %w", err)
+		}
+		return nil
+	}
+	fields := n.fields()
+	if s.raw {
+		fields = append(fields, n.rawFields()...)
+	}
+	s.writeFields(fields)
+	return nil
+}
+
+// Flush writes buffered records to the underlying writer
+func (s *recordStream) Flush() error {
+	if s.csv != nil {
+		s.csv.Flush()
+		if err := s.csv.Error(); err != nil {
+			return fmt.Errorf("error writing record: %w", err)
+		}
+	}
+	return s.out.Flush()
+}
+
+// runCat implements the "cat" subcommand, writing every parsed record with
+// normalized values as JSON Lines or tab-separated values
+func runCat(args []string) error {
+	fs := newFlagSet("cat")
+	input := inputFlags(fs)
+	asJSON := fs.Bool("json", false, "Write one JSON object per record (JSON Lines)")
+	raw := fs.Bool("raw", false, "Also write the raw values of normalized fields as found in the trace (realtime_raw, peak_rss_raw, ...)")
+	fs.Parse(args)
+
+	// The raw fields tell missing values from zeros, besides --raw
+	input.Read.KeepFields = true
+	if outputFormat == "json" {
+		*asJSON = true
+	}
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	stream := newRecordStream(os.Stdout, *asJSON, *raw)
+	for _, rec := range trace.Records {
+		n := normalizeRecord(rec, trace.Columns)
+		if *raw {
+			n.setRaw(trace.Columns, rec.Fields)
+		}
+		if err := stream.Write(n); err != nil {
+			return err
+		}
+	}
+	return stream.Flush()
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// processChange relates a process in the old run to its counterpart in the new run
+type processChange struct {
+	Old, New    *ProcessStats
+	CountEffect time.Duration // runtime change caused by a different number of tasks
+	SpeedEffect time.Duration // runtime change caused by a different runtime per task
+}
+
+// pipelineChanges summarizes how two runs of a pipeline differ
+type pipelineChanges struct {
+	Added   []*ProcessStats
+	Removed []*ProcessStats
+	Renamed []processChange
+	Common  []processChange
+
+	OldTotal, NewTotal time.Duration
+}
+
+// shortProcessName strips the workflow scope from a fully qualified process
+// name (e.g. "NFCORE_RNASEQ:RNASEQ:FASTQC" becomes "FASTQC")
+func shortProcessName(process string) string {
+	if i := strings.LastIndex(process, ":"); i >= 0 {
+		return process[i+1:]
+	}
+	return process
+}
+
+// newProcessChange decomposes the runtime difference of a process into the
+// effect of the task count (valued at the old mean runtime) and the effect of
+// the runtime per task (applied to the new task count)
+func newProcessChange(old, new *ProcessStats) processChange {
+	return processChange{
+		Old:         old,
+		New:         new,
+		CountEffect: time.Duration(new.Tasks-old.Tasks) * old.MeanRuntime(),
+		SpeedEffect: time.Duration(new.Tasks) * (new.MeanRuntime() - old.MeanRuntime()),
+	}
+}
+
+// diffRuns matches processes of two runs by name, pairing otherwise unmatched
+// processes whose unqualified names agree as renamed
+func diffRuns(oldStats, newStats []*ProcessStats) pipelineChanges {
+	var changes pipelineChanges
+
+	oldByName := make(map[string]*ProcessStats)
+	for _, stats := range oldStats {
+		oldByName[stats.Process] = stats
+		changes.OldTotal += stats.Runtime
+	}
+	newByName := make(map[string]*ProcessStats)
+	for _, stats := range newStats {
+		newByName[stats.Process] = stats
+		changes.NewTotal += stats.Runtime
+	}
+
+	var added []*ProcessStats
+	for _, stats := range newStats {
+		if old, ok := oldByName[stats.Process]; ok {
+			changes.Common = append(changes.Common, newProcessChange(old, stats))
+		} else {
+			added = append(added, stats)
+		}
+	}
+
+	// Candidates for renaming are removed processes whose short name is unique
+	removedByShort := make(map[string][]*ProcessStats)
+	for _, stats := range oldStats {
+		if _, ok := newByName[stats.Process]; !ok {
+			short := shortProcessName(stats.Process)
+			removedByShort[short] = append(removedByShort[short], stats)
+		}
+	}
+	renamedFrom := make(map[string]bool)
+	for _, stats := range added {
+		candidates := removedByShort[shortProcessName(stats.Process)]
+		if len(candidates) == 1 && !renamedFrom[candidates[0].Process] {
+			renamedFrom[candidates[0].Process] = true
+			changes.Renamed = append(changes.Renamed, newProcessChange(candidates[0], stats))
+		} else {
+			changes.Added = append(changes.Added, stats)
+		}
+	}
+	for _, stats := range oldStats {
+		if _, ok := newByName[stats.Process]; !ok && !renamedFrom[stats.Process] {
+			changes.Removed = append(changes.Removed, stats)
+		}
+	}
+
+	return changes
+}
+
+// runChanges implements the "changes" subcommand, explaining what
+// changed between two pipeline runs and where the runtime difference comes from
+func runChanges(args []string) error {
+	fs := newFlagSet("changes")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu changes <old_trace> <new_trace>")
+		fs.PrintDefaults()
+	}
+	opts := &inputOptions{}
+	readFlags(fs, opts)
+	normalizeBy := fs.String("normalize-by", "", "Also report runtime per sample: samples (detected from task tags), samples=N or samples=OLD,NEW")
+	failAbove := fs.Float64("fail-above", 0, "Fail if the total runtime grew by more than this percentage (0 disables the check)")
+	steps := fs.Int("progress", 0, "Also compare concurrency and throughput of both runs at this many steps of relative progress (share of tasks completed)")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	var samples [2]int
+	if *normalizeBy != "" {
+		var err error
+		if samples, err = parseSampleCounts(*normalizeBy); err != nil {
+			return err
+		}
+	}
+	oldTrace, err := readGroupedTrace(fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	newTrace, err := readGroupedTrace(fs.Arg(1), opts)
+	if err != nil {
+		return err
+	}
+
+	changes := diffRuns(aggregateByProcess(oldTrace.Records), aggregateByProcess(newTrace.Records))
+
+	printProcessList("Processes added", changes.Added)
+	printProcessList("Processes removed", changes.Removed)
+	if len(changes.Renamed) > 0 {
+		fmt.Printf("Processes renamed (%d):\n", len(changes.Renamed))
+		for _, change := range changes.Renamed {
+			fmt.Printf("  %s -> %s\n", change.Old.Process, change.New.Process)
+		}
+	}
+
+	var addedTotal, removedTotal, countEffect, speedEffect time.Duration
+	for _, stats := range changes.Added {
+		addedTotal += stats.Runtime
+	}
+	for _, stats := range changes.Removed {
+		removedTotal += stats.Runtime
+	}
+	matched := append(append([]processChange(nil), changes.Common...), changes.Renamed...)
+	for _, change := range matched {
+		countEffect += change.CountEffect
+		speedEffect += change.SpeedEffect
+	}
+
+	delta := changes.NewTotal - changes.OldTotal
+	fmt.Println()
+	fmt.Printf("Total runtime: %s -> %s (%s", FormatDuration(changes.OldTotal),
+		FormatDuration(changes.NewTotal), FormatSignedDuration(delta))
+	if changes.OldTotal > 0 {
+		fmt.Printf(", %s", FormatSignedPercent(100*delta.Seconds()/changes.OldTotal.Seconds()))
+	}
+	fmt.Println(")")
+	fmt.Printf("  New processes:       %s\n", FormatSignedDuration(addedTotal))
+	fmt.Printf("  Removed processes:   %s\n", FormatSignedDuration(-removedTotal))
+	fmt.Printf("  Task count changes:  %s\n", FormatSignedDuration(countEffect))
+	fmt.Printf("  Runtime per task:    %s\n", FormatSignedDuration(speedEffect))
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tMEAN RUNTIME\tCOUNT EFFECT\tSPEED EFFECT")
+	for _, change := range matched {
+		fmt.Fprintf(w, "%s\t%d -> %d\t%s -> %s\t%s\t%s\n", change.New.Process,
+			change.Old.Tasks, change.New.Tasks,
+			FormatDuration(change.Old.MeanRuntime()), FormatDuration(change.New.MeanRuntime()),
+			FormatSignedDuration(change.CountEffect), FormatSignedDuration(change.SpeedEffect))
+	}
+	w.Flush()
+
+	if *normalizeBy != "" {
+		if samples[0] == 0 {
+			samples[0] = countSamples(oldTrace.Records)
+		}
+		if samples[1] == 0 {
+			samples[1] = countSamples(newTrace.Records)
+		}
+		printPerSample(changes, matched, samples)
+	}
+
+	if *steps > 0 {
+		printProgressComparison(progressCurve(oldTrace.Records, *steps), progressCurve(newTrace.Records, *steps))
+	}
+
+	if *failAbove > 0 && changes.OldTotal > 0 {
+		if growth := 100 * delta.Seconds() / changes.OldTotal.Seconds(); growth > *failAbove {
+			return fmt.Errorf("total runtime grew by %s, more than the allowed %s", FormatPercent(growth), FormatPercent(*failAbove))
+		}
+	}
+	return nil
+}
+
+// printPerSample prints the runtime of both runs divided by their number of
+// samples, making runs of different size comparable
+func printPerSample(changes pipelineChanges, matched []processChange, samples [2]int) {
+	perSample := func(d time.Duration, n int) time.Duration { return d / time.Duration(n) }
+	change := func(old, new time.Duration) string {
+		if old == 0 {
+			return "-"
+		}
+		return FormatSignedPercent(100 * (new.Seconds()/old.Seconds() - 1))
+	}
+
+	oldTotal, newTotal := perSample(changes.OldTotal, samples[0]), perSample(changes.NewTotal, samples[1])
+	fmt.Println()
+	fmt.Printf("Runtime per sample (%d -> %d samples): %s -> %s (%s)\n", samples[0], samples[1],
+		FormatDuration(oldTotal), FormatDuration(newTotal), change(oldTotal, newTotal))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tRUNTIME/SAMPLE\tCHANGE")
+	for _, c := range matched {
+		old, new := perSample(c.Old.Runtime, samples[0]), perSample(c.New.Runtime, samples[1])
+		fmt.Fprintf(w, "%s\t%s -> %s\t%s\n", c.New.Process, FormatDuration(old), FormatDuration(new), change(old, new))
+	}
+	w.Flush()
+}
+
+// printProgressComparison prints the progress curves of two runs side by side
+func printProgressComparison(oldCurve, newCurve []progressStep) {
+	if len(oldCurve) == 0 || len(newCurve) == 0 {
+		fmt.Println("\nProgress comparison needs start times and runtimes in both traces")
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Runs aligned by relative progress (share of tasks completed):")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROGRESS\tELAPSED\tCONCURRENCY\tTASKS/H\tMAIN PROCESS")
+	for i := range oldCurve {
+		old, new := oldCurve[i], newCurve[i]
+		process := shortProcessName(new.Process)
+		if shortProcessName(old.Process) != process {
+			process = shortProcessName(old.Process) + " -> " + process
+		}
+		fmt.Fprintf(w, "%.0f%%\t%s -> %s\t%.1f -> %.1f\t%.1f -> %.1f\t%s\n", 100*float64(i+1)/float64(len(oldCurve)),
+			FormatDuration(old.Elapsed), FormatDuration(new.Elapsed),
+			old.Concurrency, new.Concurrency, old.Throughput, new.Throughput, process)
+	}
+	w.Flush()
+}
+
+// printProcessList prints a titled list of processes with their total runtime
+func printProcessList(title string, processes []*ProcessStats) {
+	if len(processes) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d):\n", title, len(processes))
+	for _, stats := range processes {
+		fmt.Printf("  %s (%d tasks, %s)\n", stats.Process, stats.Tasks, FormatDuration(stats.Runtime))
+	}
+}
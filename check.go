@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// budget limits the performance of the processes matching a pattern; zero
+// limits are not checked
+type budget struct {
+	Process        string // process name or regular expression as given
+	pattern        *regexp.Regexp
+	MaxTime        time.Duration // 95th percentile of the task runtime
+	MaxMemory      int64         // peak RSS of any task in bytes
+	MaxFailureRate float64       // share of failed task attempts, 0 to 1
+	HasFailureRate bool          // a failure rate limit was set; 0 allows no failures
+}
+
+// Budget limits as named in budget files; --max-<limit> sets them on the
+// command line
+const (
+	limitTime        = "p95_time"
+	limitMemory      = "peak_memory"
+	limitFailureRate = "failure_rate"
+)
+
+// set parses and sets one limit of the budget
+func (b *budget) set(limit, value string) error {
+	switch limit {
+	case limitTime:
+		d, err := ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid time limit '%s'", value)
+		}
+		b.MaxTime = d
+	case limitMemory:
+		size, err := ParseSize(value)
+		if err != nil || size <= 0 {
+			return fmt.Errorf("invalid memory limit '%s'", value)
+		}
+		b.MaxMemory = size
+	case limitFailureRate:
+		// A percentage or a fraction
+		rate, err := parseNumber(strings.TrimSpace(strings.TrimSuffix(value, "%")))
+		if err == nil && strings.HasSuffix(value, "%") {
+			rate /= 100
+		}
+		if err != nil || rate < 0 || rate > 1 {
+			return fmt.Errorf("invalid failure rate limit '%s' (use e.g. 5%% or 0.05)", value)
+		}
+		b.MaxFailureRate, b.HasFailureRate = rate, true
+	default:
+		return fmt.Errorf("unknown budget limit '%s' (use %s, %s or %s)", limit, limitTime, limitMemory, limitFailureRate)
+	}
+	return nil
+}
+
+// matches reports whether the budget applies to a process; like groups,
+// the pattern is matched against the full and the short process name
+func (b *budget) matches(process string) bool {
+	return b.pattern.MatchString(process) || b.pattern.MatchString(shortProcessName(process))
+}
+
+// budgetList collects the budgets given on the command line, one per process
+type budgetList []*budget
+
+// get returns the budget of a process pattern, adding it if necessary
+func (l *budgetList) get(process string) (*budget, error) {
+	for _, b := range *l {
+		if b.Process == process {
+			return b, nil
+		}
+	}
+	pattern, err := regexp.Compile("^(?:" + process + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid process pattern %q: %w", process, err)
+	}
+	b := &budget{Process: process, pattern: pattern}
+	*l = append(*l, b)
+	return b, nil
+}
+
+// merge adds the limits of other budgets, replacing limits already set
+func (l *budgetList) merge(other budgetList) {
+	for _, o := range other {
+		b, _ := l.get(o.Process)
+		if o.MaxTime > 0 {
+			b.MaxTime = o.MaxTime
+		}
+		if o.MaxMemory > 0 {
+			b.MaxMemory = o.MaxMemory
+		}
+		if o.HasFailureRate {
+			b.MaxFailureRate, b.HasFailureRate = o.MaxFailureRate, true
+		}
+	}
+}
+
+// budgetFlag is a repeatable PROCESS=LIMIT flag setting one limit of a budget
+type budgetFlag struct {
+	budgets *budgetList
+	limit   string
+}
+
+func (f budgetFlag) String() string { return "" }
+
+func (f budgetFlag) Set(value string) error {
+	process, limit, ok := strings.Cut(value, "=")
+	if !ok || process == "" || limit == "" {
+		return fmt.Errorf("expected PROCESS=LIMIT, got '%s'", value)
+	}
+	b, err := f.budgets.get(process)
+	if err != nil {
+		return err
+	}
+	return b.set(f.limit, limit)
+}
+
+// assertion is the result of checking one limit of a budget
+type assertion struct {
+	Process string
+	Metric  string
+	Limit   string
+	Actual  string
+	Tasks   int  // tasks of the processes matching the budget
+	Passed  bool // false if the limit was exceeded or there was nothing to check
+}
+
+// Skipped returns why the assertion could not be checked, or "" if it was
+func (a assertion) Skipped() string {
+	switch {
+	case a.Tasks == 0:
+		return "no tasks"
+	case a.Actual == "-":
+		return "no values"
+	}
+	return ""
+}
+
+// Name describes the assertion, e.g. "p95 runtime <= 2h"
+func (a assertion) Name() string {
+	return fmt.Sprintf("%s <= %s", a.Metric, a.Limit)
+}
+
+// evaluateBudgets checks every limit of every budget against the tasks of the
+// matching processes
+func evaluateBudgets(records []TraceRecord, budgets budgetList) []assertion {
+	var assertions []assertion
+	for _, b := range budgets {
+		var runtimes []float64
+		var peakRSS int64
+		failed := 0
+		for _, rec := range records {
+			if !b.matches(rec.Process) {
+				continue
+			}
+			runtimes = append(runtimes, float64(rec.Runtime()))
+			if rec.Status != "" && !isSuccess(rec.Status) {
+				failed++
+			}
+			peakRSS = max(peakRSS, rec.PeakRSS)
+		}
+
+		if b.MaxTime > 0 {
+			a := assertion{Process: b.Process, Metric: "p95 runtime", Limit: FormatDuration(b.MaxTime), Actual: "-", Tasks: len(runtimes)}
+			if len(runtimes) > 0 {
+				p95 := time.Duration(percentile(runtimes, 95))
+				a.Actual, a.Passed = FormatDuration(p95), p95 <= b.MaxTime
+			}
+			assertions = append(assertions, a)
+		}
+		if b.MaxMemory > 0 {
+			a := assertion{Process: b.Process, Metric: "peak memory", Limit: FormatSize(b.MaxMemory), Actual: "-", Tasks: len(runtimes)}
+			if peakRSS > 0 {
+				a.Actual, a.Passed = FormatSize(peakRSS), peakRSS <= b.MaxMemory
+			}
+			assertions = append(assertions, a)
+		}
+		if b.HasFailureRate {
+			a := assertion{Process: b.Process, Metric: "failure rate", Limit: FormatPercent(100 * b.MaxFailureRate), Actual: "-", Tasks: len(runtimes)}
+			if len(runtimes) > 0 {
+				rate := float64(failed) / float64(len(runtimes))
+				a.Actual, a.Passed = FormatPercent(100*rate), rate <= b.MaxFailureRate
+			}
+			assertions = append(assertions, a)
+		}
+	}
+	return assertions
+}
+
+// JUnit XML elements, as read by CI systems and test report dashboards
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes the assertions as JUnit XML test cases, one per limit;
+// limits that could not be checked are skipped
+func writeJUnit(path, suite string, assertions []assertion) error {
+	s := junitTestSuite{Name: suite, Tests: len(assertions)}
+	for _, a := range assertions {
+		tc := junitTestCase{ClassName: a.Process, Name: a.Name()}
+		switch {
+		case a.Skipped() != "":
+			tc.Skipped = &junitMessage{Message: a.Skipped()}
+			s.Skipped++
+		case !a.Passed:
+			tc.Failure = &junitMessage{Message: fmt.Sprintf("%s is %s, limit %s", a.Metric, a.Actual, a.Limit)}
+			s.Failures++
+		}
+		s.Cases = append(s.Cases, tc)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{s}}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error writing JUnit report: %w", err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing JUnit report: %w", err)
+	}
+	return nil
+}
+
+// parseTolerance parses a relative tolerance given as a percentage, e.g.
+// "10%", or a fraction
+func parseTolerance(value string) (float64, error) {
+	tol, err := parseNumber(strings.TrimSpace(strings.TrimSuffix(value, "%")))
+	if err != nil || tol < 0 {
+		return 0, fmt.Errorf("invalid tolerance '%s'", value)
+	}
+	if strings.HasSuffix(value, "%") {
+		tol /= 100
+	}
+	return tol, nil
+}
+
+// runCheck implements the "check" subcommand, asserting per-process
+// performance budgets and failing if any is exceeded
+func runCheck(args []string) error {
+	fs := newFlagSet("check")
+	input := inputFlags(fs)
+	budgetsFile := fs.String("b", "", "YAML file of per-process budgets")
+	fs.StringVar(budgetsFile, "budgets", "", "YAML file of per-process budgets")
+	var flagBudgets budgetList
+	fs.Var(budgetFlag{&flagBudgets, limitTime}, "max-time", "Maximum 95th percentile runtime of a process, as PROCESS=DURATION (repeatable)")
+	fs.Var(budgetFlag{&flagBudgets, limitMemory}, "max-memory", "Maximum peak memory (RSS) of any task of a process, as PROCESS=SIZE (repeatable)")
+	fs.Var(budgetFlag{&flagBudgets, limitFailureRate}, "max-failure-rate", "Maximum share of failed attempts of a process, as PROCESS=RATE, e.g. 5% (repeatable)")
+	baselineFile := fs.String("baseline", "", "Budgets file recorded with --update-baseline to compare against")
+	updateBaseline := fs.Bool("update-baseline", false, "Record the performance of every process as the new --baseline instead of checking")
+	tolerance := fs.String("tolerance", "0%", "Allowed excess over the --baseline, e.g. 10%")
+	junit := fs.String("junit", "", "Also write the results as JUnit XML to this file")
+	fs.Parse(args)
+
+	if *updateBaseline {
+		if *baselineFile == "" {
+			return fmt.Errorf("--update-baseline requires --baseline")
+		}
+		trace, err := loadTrace(fs, input)
+		if err != nil {
+			return err
+		}
+		budgets := measureBudgets(trace.Records)
+		if err := writeBaseline(*baselineFile, trace, budgets); err != nil {
+			return err
+		}
+		fmt.Printf("Baseline of %d processes written to %s\n", len(budgets), *baselineFile)
+		return nil
+	}
+
+	// Limits of the budgets file override the baseline, and limits given
+	// on the command line override both
+	var budgets budgetList
+	if *baselineFile != "" {
+		tol, err := parseTolerance(*tolerance)
+		if err != nil {
+			return err
+		}
+		baseline, err := loadBudgets(*baselineFile)
+		if err != nil {
+			return err
+		}
+		budgets = baseline.withTolerance(tol)
+	}
+	if *budgetsFile != "" {
+		fileBudgets, err := loadBudgets(*budgetsFile)
+		if err != nil {
+			return err
+		}
+		budgets.merge(fileBudgets)
+	}
+	budgets.merge(flagBudgets)
+	if len(budgets) == 0 {
+		return fmt.Errorf("no budgets given (use --budgets, --baseline or --max-time, --max-memory, --max-failure-rate)")
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	assertions := evaluateBudgets(trace.Records, budgets)
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tMETRIC\tLIMIT\tACTUAL\tTASKS\tRESULT")
+	for _, a := range assertions {
+		result := "PASS"
+		switch {
+		case a.Skipped() != "":
+			result = "SKIP (" + a.Skipped() + ")"
+		case !a.Passed:
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", a.Process, a.Metric, a.Limit, a.Actual, a.Tasks, result)
+	}
+	w.Flush()
+
+	if *junit != "" {
+		if err := writeJUnit(*junit, "nfu check "+trace.Path, assertions); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d budget assertions failed", failed, len(assertions))
+	}
+	return nil
+}
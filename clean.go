@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Actions recorded in the deletion log of "clean"
+const (
+	cleanWouldRemove = "would-remove"
+	cleanRemoved     = "removed"
+	cleanTrashed     = "trashed"
+	cleanRestored    = "restored"
+)
+
+// cleanLog writes one tab-separated line per action to stdout and, if given,
+// appends it to a log file: time, action, path, size in bytes and the trash
+// path of trashed directories. Paths are absolute so that --undo works
+// from any directory.
+type cleanLog struct {
+	out io.Writer
+}
+
+func (l cleanLog) record(action, path string, size int64, trashPath string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if abs, err := filepath.Abs(trashPath); err == nil && trashPath != "" {
+		trashPath = abs
+	}
+	fmt.Fprintf(l.out, "%s\t%s\t%s\t%d\t%s\n", time.Now().Format(time.RFC3339), action, path, size, trashPath)
+}
+
+// moveToTrash moves a task directory into the trash directory, keeping its
+// place under the work directory so that it can be restored
+func moveToTrash(dir workDir, trash string) (string, error) {
+	target := filepath.Join(trash, dir.Hash[:2], filepath.Base(dir.Path))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(dir.Path, target); err != nil {
+		return "", fmt.Errorf("%w (the trash directory must be on the same file system as the work directory)", err)
+	}
+	return target, nil
+}
+
+// restoreFromLog moves the directories trashed according to a deletion log
+// back to their place
+func restoreFromLog(logPath string, log cleanLog) error {
+	file, err := os.Open(logPath)
+	if err != nil {
+		return fmt.Errorf("error opening deletion log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	restored := 0
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 || fields[1] != cleanTrashed {
+			continue
+		}
+		path, trashPath := fields[2], fields[4]
+		if _, err := os.Stat(trashPath); err != nil {
+			continue // already restored or emptied from the trash
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("error restoring %s: %w", path, err)
+		}
+		if err := os.Rename(trashPath, path); err != nil {
+			return fmt.Errorf("error restoring %s: %w", path, err)
+		}
+		log.record(cleanRestored, path, 0, trashPath)
+		restored++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading deletion log: %w", err)
+	}
+	slog.Info("restored task directories", "count", restored)
+	return nil
+}
+
+// runClean implements the "clean" subcommand, removing the orphaned task
+// directories found by "orphans" or moving them to a trash directory
+func runClean(args []string) error {
+	fs := newFlagSet("clean")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu clean [flags] -i <trace> [-i <trace>...] [<trace>...]")
+		fmt.Fprintln(fs.Output(), "       nfu clean --undo <log>")
+		fmt.Fprintln(fs.Output(), "Removes the task directories 'nfu orphans' lists for the same traces.")
+		fmt.Fprintln(fs.Output(), "Nothing is removed without --apply.")
+		fs.PrintDefaults()
+	}
+	// Only the input files: a filter leaving tasks out would have their
+	// directories removed
+	var paths pathList
+	pathFlags(fs, &paths)
+	workRoot, minAge := orphanFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Only list the directories that would be removed (the default)")
+	apply := fs.Bool("apply", false, "Remove the directories")
+	trash := fs.String("trash", "", "Move the directories into this directory instead of removing them (same file system as the work directory)")
+	rate := fs.Float64("rate", 0, "Maximum number of directories removed per second, to spare shared file systems (0 for no limit)")
+	logPath := fs.String("log", "", "Also append the deletion log to this file")
+	undo := fs.String("undo", "", "Move the directories trashed according to this deletion log back")
+	fs.Parse(args)
+
+	log := cleanLog{out: os.Stdout}
+	if *logPath != "" {
+		file, err := os.OpenFile(*logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("error opening deletion log: %w", err)
+		}
+		defer file.Close()
+		log.out = io.MultiWriter(os.Stdout, file)
+	}
+	if *undo != "" {
+		return restoreFromLog(*undo, log)
+	}
+
+	if *dryRun && *apply {
+		return fmt.Errorf("--dry-run and --apply exclude each other")
+	}
+	if paths = append(paths, fs.Args()...); len(paths) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	known, err := traceHashes(paths)
+	if err != nil {
+		return err
+	}
+	if len(known) == 0 {
+		return fmt.Errorf("no task hashes found in the traces, refusing to treat every directory as orphaned")
+	}
+	dirs, err := scanWorkDirs(*workRoot)
+	if err != nil {
+		return err
+	}
+	orphans := findOrphans(dirs, known, *minAge, time.Now())
+
+	var interval time.Duration
+	if *rate > 0 {
+		interval = time.Duration(float64(time.Second) / *rate)
+	}
+	var freed int64
+	for i, dir := range orphans {
+		if !*apply {
+			log.record(cleanWouldRemove, dir.Path, dir.Size, "")
+			freed += dir.Size
+			continue
+		}
+		if i > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+		if *trash != "" {
+			target, err := moveToTrash(dir, *trash)
+			if err != nil {
+				return fmt.Errorf("error moving %s to the trash: %w", dir.Path, err)
+			}
+			log.record(cleanTrashed, dir.Path, dir.Size, target)
+		} else {
+			if err := os.RemoveAll(dir.Path); err != nil {
+				return fmt.Errorf("error removing %s: %w", dir.Path, err)
+			}
+			log.record(cleanRemoved, dir.Path, dir.Size, "")
+		}
+		freed += dir.Size
+	}
+
+	switch {
+	case !*apply:
+		slog.Info("dry run, run with --apply to remove the directories", "orphaned", len(orphans),
+			"directories", len(dirs), "size", FormatSize(freed))
+	case *trash != "":
+		slog.Info("moved orphaned task directories to the trash", "count", len(orphans), "size", FormatSize(freed), "trash", *trash)
+	default:
+		slog.Info("removed orphaned task directories", "count", len(orphans), "size", FormatSize(freed))
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// processComparison pairs the summaries of a process in two runs
+type processComparison struct {
+	Old, New  *processSummary
+	Regressed []string // what got worse by more than the threshold
+}
+
+// comparisonRecord is a process in the machine-readable output formats of
+// "compare"; the values of a run the process did not run in are omitted
+type comparisonRecord struct {
+	Process           string   `json:"process"`
+	Runs              string   `json:"runs"` // both, new or old
+	OldTasks          *int     `json:"old_tasks,omitempty"`
+	NewTasks          *int     `json:"new_tasks,omitempty"`
+	OldRuntimeMs      *int64   `json:"old_runtime_ms,omitempty"`
+	NewRuntimeMs      *int64   `json:"new_runtime_ms,omitempty"`
+	OldMeanRuntimeMs  *int64   `json:"old_mean_runtime_ms,omitempty"`
+	NewMeanRuntimeMs  *int64   `json:"new_mean_runtime_ms,omitempty"`
+	OldMeanCPUPercent *float64 `json:"old_mean_cpu_percent,omitempty"`
+	NewMeanCPUPercent *float64 `json:"new_mean_cpu_percent,omitempty"`
+	OldPeakRSSBytes   *int64   `json:"old_peak_rss_bytes,omitempty"`
+	NewPeakRSSBytes   *int64   `json:"new_peak_rss_bytes,omitempty"`
+	Regressed         []string `json:"regressed,omitempty"`
+}
+
+// comparisonRecords returns the records of the processes of both runs,
+// followed by those of only the new and only the old run
+func comparisonRecords(common []processComparison, added, removed []*ProcessStats) []comparisonRecord {
+	var records []comparisonRecord
+	totals := func(s *ProcessStats) (*int, *int64) {
+		tasks, runtime := s.Tasks, s.Runtime.Milliseconds()
+		return &tasks, &runtime
+	}
+	summary := func(s *processSummary) (meanRuntime *int64, cpuPercent *float64, peakRSS *int64) {
+		ms := int64(mean(s.Runtimes) / float64(time.Millisecond))
+		meanRuntime = &ms
+		if len(s.CPUPercent) > 0 {
+			v := mean(s.CPUPercent)
+			cpuPercent = &v
+		}
+		if s.PeakRSS > 0 {
+			peakRSS = &s.PeakRSS
+		}
+		return meanRuntime, cpuPercent, peakRSS
+	}
+	for _, c := range common {
+		r := comparisonRecord{Process: c.New.Process, Runs: "both", Regressed: c.Regressed}
+		r.OldTasks, r.OldRuntimeMs = totals(c.Old.ProcessStats)
+		r.NewTasks, r.NewRuntimeMs = totals(c.New.ProcessStats)
+		r.OldMeanRuntimeMs, r.OldMeanCPUPercent, r.OldPeakRSSBytes = summary(c.Old)
+		r.NewMeanRuntimeMs, r.NewMeanCPUPercent, r.NewPeakRSSBytes = summary(c.New)
+		records = append(records, r)
+	}
+	for _, s := range added {
+		r := comparisonRecord{Process: s.Process, Runs: "new"}
+		r.NewTasks, r.NewRuntimeMs = totals(s)
+		records = append(records, r)
+	}
+	for _, s := range removed {
+		r := comparisonRecord{Process: s.Process, Runs: "old"}
+		r.OldTasks, r.OldRuntimeMs = totals(s)
+		records = append(records, r)
+	}
+	return records
+}
+
+// percentChange returns the relative change from old to new in percent, NaN
+// when there is nothing to compare
+func percentChange(old, new float64) float64 {
+	if old == 0 || math.IsNaN(old) || math.IsNaN(new) {
+		return math.NaN()
+	}
+	return 100 * (new - old) / old
+}
+
+// byTag keys the tasks of each process by their tag, so that tasks are
+// joined by process and tag; tasks without a tag stay with their process
+func byTag(records []TraceRecord) []TraceRecord {
+	keyed := make([]TraceRecord, len(records))
+	for i, rec := range records {
+		if rec.Tag != "" {
+			rec.Process = fmt.Sprintf("%s (%s)", rec.Process, rec.Tag)
+		}
+		keyed[i] = rec
+	}
+	return keyed
+}
+
+// compareRuns joins the processes of two runs by name and flags those whose
+// mean runtime or peak RSS grew by more than threshold percent; processes of
+// only one run are returned separately
+func compareRuns(oldRecords, newRecords []TraceRecord, threshold float64) (common []processComparison, added, removed []*ProcessStats) {
+	oldSummaries := summarizeProcesses(oldRecords)
+	oldByName := make(map[string]*processSummary)
+	for _, s := range oldSummaries {
+		oldByName[s.Process] = s
+	}
+	newSummaries := summarizeProcesses(newRecords)
+	seen := make(map[string]bool)
+	for _, s := range newSummaries {
+		seen[s.Process] = true
+		old, ok := oldByName[s.Process]
+		if !ok {
+			added = append(added, s.ProcessStats)
+			continue
+		}
+		c := processComparison{Old: old, New: s}
+		if percentChange(mean(old.Runtimes), mean(s.Runtimes)) > threshold {
+			c.Regressed = append(c.Regressed, "mean runtime")
+		}
+		if percentChange(float64(old.PeakRSS), float64(s.PeakRSS)) > threshold {
+			c.Regressed = append(c.Regressed, "peak RSS")
+		}
+		common = append(common, c)
+	}
+	for _, s := range oldSummaries {
+		if !seen[s.Process] {
+			removed = append(removed, s.ProcessStats)
+		}
+	}
+	return common, added, removed
+}
+
+// runCompare implements the "compare" subcommand, reporting per-process
+// changes of runtime, CPU usage and peak memory between two runs
+func runCompare(args []string) error {
+	fs := newFlagSet("compare")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu compare [flags] <old_trace> <new_trace>")
+		fs.PrintDefaults()
+	}
+	opts := &inputOptions{}
+	readFlags(fs, opts)
+	joinByTag := fs.Bool("by-tag", false, "Join tasks by process and tag instead of process only")
+	threshold := fs.Float64("threshold", 10, "Increase of mean runtime or peak RSS in percent reported as a regression")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *threshold < 0 {
+		return fmt.Errorf("threshold cannot be negative")
+	}
+	oldTrace, err := readGroupedTrace(fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	newTrace, err := readGroupedTrace(fs.Arg(1), opts)
+	if err != nil {
+		return err
+	}
+	oldRecords, newRecords := oldTrace.Records, newTrace.Records
+	if *joinByTag {
+		oldRecords, newRecords = byTag(oldRecords), byTag(newRecords)
+	}
+	common, added, removed := compareRuns(oldRecords, newRecords, *threshold)
+	if outputFormat != "text" {
+		return writeRecords(os.Stdout, comparisonRecords(common, added, removed))
+	}
+
+	change := func(old, new float64) string {
+		if c := percentChange(old, new); !math.IsNaN(c) {
+			return FormatSignedPercent(c)
+		}
+		return "-"
+	}
+	duration := func(ns float64) string {
+		if math.IsNaN(ns) {
+			return "-"
+		}
+		return FormatDuration(time.Duration(ns))
+	}
+	cpuPercent := func(s *processSummary) (float64, string) {
+		if len(s.CPUPercent) == 0 {
+			return math.NaN(), "-"
+		}
+		v := mean(s.CPUPercent)
+		return v, FormatPercent(v)
+	}
+	size := func(bytes int64) string {
+		if bytes <= 0 {
+			return "-"
+		}
+		return FormatSize(bytes)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tTOTAL RUNTIME\tCHANGE\tMEAN RUNTIME\tCHANGE\tMEAN %CPU\tCHANGE\tPEAK RSS\tCHANGE")
+	for _, c := range common {
+		oldMean, newMean := mean(c.Old.Runtimes), mean(c.New.Runtimes)
+		oldCPU, oldCPUText := cpuPercent(c.Old)
+		newCPU, newCPUText := cpuPercent(c.New)
+		fmt.Fprintf(w, "%s\t%d -> %d\t%s -> %s\t%s\t%s -> %s\t%s\t%s -> %s\t%s\t%s -> %s\t%s\n", c.New.Process,
+			c.Old.Tasks, c.New.Tasks,
+			FormatDuration(c.Old.Runtime), FormatDuration(c.New.Runtime), change(float64(c.Old.Runtime), float64(c.New.Runtime)),
+			duration(oldMean), duration(newMean), change(oldMean, newMean),
+			oldCPUText, newCPUText, change(oldCPU, newCPU),
+			size(c.Old.PeakRSS), size(c.New.PeakRSS), change(float64(c.Old.PeakRSS), float64(c.New.PeakRSS)))
+	}
+	w.Flush()
+
+	var regressed []processComparison
+	for _, c := range common {
+		if len(c.Regressed) > 0 {
+			regressed = append(regressed, c)
+		}
+	}
+	fmt.Println()
+	if len(regressed) == 0 {
+		fmt.Printf("No process regressed by more than %s\n", FormatPercent(*threshold))
+	} else {
+		fmt.Printf("Processes regressed by more than %s (%d):\n", FormatPercent(*threshold), len(regressed))
+		for _, c := range regressed {
+			fmt.Printf("  %s: %s\n", c.New.Process, strings.Join(c.Regressed, ", "))
+		}
+	}
+	printProcessList("Only in the new run", added)
+	printProcessList("Only in the old run", removed)
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// concurrencyBucket is a period of the timeline of a run
+type concurrencyBucket struct {
+	Start       time.Time
+	MeanRunning float64
+	MaxRunning  int
+	MeanQueued  float64
+}
+
+// concurrencyProfile describes how parallel a run was
+type concurrencyProfile struct {
+	First, Last time.Time // earliest submission and latest completion
+	Tasks       int       // tasks with a start time
+
+	MaxRunning   int
+	MaxRunningAt time.Time
+	MeanRunning  float64 // time-weighted over the makespan
+	MaxQueued    int
+	MeanQueued   float64
+
+	QueueWaits []float64 // start - submit of every task, in nanoseconds
+	Buckets    []concurrencyBucket
+}
+
+// Makespan returns the wall time from the first submission to the last completion
+func (p *concurrencyProfile) Makespan() time.Duration {
+	return p.Last.Sub(p.First)
+}
+
+// concurrencyEvent is a task entering or leaving the queue or running state
+type concurrencyEvent struct {
+	Time           time.Time
+	Running, Queue int // +1 entering, -1 leaving
+}
+
+// profileConcurrency sweeps over the submission, start and end of every
+// task, counting the tasks running and queued between consecutive events.
+// Tasks ending at the time others start do not overlap them. With a
+// positive bucket the counts are also averaged per period of the timeline.
+func profileConcurrency(records []TraceRecord, bucket time.Duration) *concurrencyProfile {
+	p := &concurrencyProfile{}
+	var events []concurrencyEvent
+	for _, rec := range records {
+		if rec.Start.IsZero() {
+			excludeTask(rec, "no start time")
+			continue
+		}
+		p.Tasks++
+		first := rec.Start
+		if !rec.Submit.IsZero() && rec.Submit.Before(rec.Start) {
+			first = rec.Submit
+			events = append(events, concurrencyEvent{rec.Submit, 0, 1}, concurrencyEvent{rec.Start, 0, -1})
+		}
+		if !rec.Submit.IsZero() {
+			p.QueueWaits = append(p.QueueWaits, float64(max(rec.Start.Sub(rec.Submit), 0)))
+		}
+		events = append(events, concurrencyEvent{rec.Start, 1, 0}, concurrencyEvent{rec.End(), -1, 0})
+		if p.First.IsZero() || first.Before(p.First) {
+			p.First = first
+		}
+		if rec.End().After(p.Last) {
+			p.Last = rec.End()
+		}
+	}
+	if p.Tasks == 0 {
+		return p
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Time.Equal(events[j].Time) {
+			return events[i].Time.Before(events[j].Time)
+		}
+		// Leaving before entering, so that back-to-back tasks do not overlap
+		return events[i].Running+events[i].Queue < events[j].Running+events[j].Queue
+	})
+
+	if bucket > 0 {
+		for t := p.First; t.Before(p.Last); t = t.Add(bucket) {
+			p.Buckets = append(p.Buckets, concurrencyBucket{Start: t})
+		}
+	}
+	// addSegment accounts for a period with constant counts to the buckets it overlaps
+	addSegment := func(from, to time.Time, running, queued int) {
+		if len(p.Buckets) == 0 || !to.After(from) {
+			return
+		}
+		for i := int(from.Sub(p.First) / bucket); i < len(p.Buckets); i++ {
+			b := &p.Buckets[i]
+			end := b.Start.Add(bucket)
+			if !b.Start.Before(to) {
+				break
+			}
+			overlap := float64(minTime(end, to).Sub(maxTime(b.Start, from))) / float64(bucket)
+			b.MeanRunning += float64(running) * overlap
+			b.MeanQueued += float64(queued) * overlap
+			b.MaxRunning = max(b.MaxRunning, running)
+		}
+	}
+
+	var runningArea, queuedArea float64
+	running, queued := 0, 0
+	last := p.First
+	for _, e := range events {
+		if e.Time.After(last) {
+			dt := float64(e.Time.Sub(last))
+			runningArea += float64(running) * dt
+			queuedArea += float64(queued) * dt
+			addSegment(last, e.Time, running, queued)
+			last = e.Time
+		}
+		running += e.Running
+		queued += e.Queue
+		if running > p.MaxRunning {
+			p.MaxRunning, p.MaxRunningAt = running, e.Time
+		}
+		p.MaxQueued = max(p.MaxQueued, queued)
+	}
+	if makespan := float64(p.Makespan()); makespan > 0 {
+		p.MeanRunning = runningArea / makespan
+		p.MeanQueued = queuedArea / makespan
+	}
+	return p
+}
+
+// minTime returns the earlier of two times
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// maxTime returns the later of two times
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// timelineRecord is a period of the timeline in the machine-readable
+// output formats
+type timelineRecord struct {
+	Time        string  `json:"time"`
+	MeanRunning float64 `json:"mean_running"`
+	MaxRunning  int     `json:"max_running"`
+	MeanQueued  float64 `json:"mean_queued"`
+}
+
+// runConcurrency implements the "concurrency" subcommand, reporting the
+// makespan of a run, queue waits and how many tasks ran in parallel
+func runConcurrency(args []string) error {
+	fs := newFlagSet("concurrency")
+	input := inputFlags(fs)
+	bucket := fs.Duration("timeline", 0, "Also print a timeline of running and queued tasks in periods of this length, e.g. 10m")
+	fs.Parse(args)
+
+	if *bucket < 0 {
+		return fmt.Errorf("timeline period cannot be negative")
+	}
+	if outputFormat != "text" && *bucket == 0 {
+		return fmt.Errorf("%s output is the timeline, give its period with --timeline", outputFormat)
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	if !trace.HasColumn("start") {
+		return fmt.Errorf("start column not found in input file")
+	}
+	p := profileConcurrency(trace.Records, *bucket)
+	if p.Tasks == 0 {
+		return fmt.Errorf("no tasks with a start time")
+	}
+
+	if outputFormat != "text" {
+		records := make([]timelineRecord, len(p.Buckets))
+		for i, b := range p.Buckets {
+			records[i] = timelineRecord{
+				Time:        b.Start.Format(time.RFC3339),
+				MeanRunning: b.MeanRunning,
+				MaxRunning:  b.MaxRunning,
+				MeanQueued:  b.MeanQueued,
+			}
+		}
+		return writeRecords(os.Stdout, records)
+	}
+
+	fmt.Printf("Makespan: %s (%s to %s)\n", FormatDuration(p.Makespan()),
+		p.First.Format(time.DateTime), p.Last.Format(time.DateTime))
+	fmt.Printf("Tasks: %d\n", p.Tasks)
+	if len(p.QueueWaits) > 0 {
+		duration := func(ns float64) string { return FormatDuration(time.Duration(ns)) }
+		fmt.Printf("Queue wait: mean %s, median %s, p95 %s, max %s\n", duration(mean(p.QueueWaits)),
+			duration(median(p.QueueWaits)), duration(percentile(p.QueueWaits, 95)), duration(percentile(p.QueueWaits, 100)))
+	} else {
+		fmt.Println("Queue wait: - (no submit column)")
+	}
+	fmt.Printf("Running tasks: max %d (at %s), mean %.1f\n", p.MaxRunning, p.MaxRunningAt.Format(time.DateTime), p.MeanRunning)
+	if len(p.QueueWaits) > 0 {
+		fmt.Printf("Queued tasks: max %d, mean %.1f\n", p.MaxQueued, p.MeanQueued)
+	}
+
+	if len(p.Buckets) > 0 {
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tRUNNING\tMAX\tQUEUED")
+		for _, b := range p.Buckets {
+			bar := ""
+			if p.MaxRunning > 0 {
+				bar = strings.Repeat("#", int(40*b.MeanRunning/float64(p.MaxRunning)+0.5))
+			}
+			fmt.Fprintf(w, "%s\t%.1f\t%d\t%.1f\t%s\n", b.Start.Format(time.DateTime), b.MeanRunning, b.MaxRunning, b.MeanQueued, bar)
+		}
+		w.Flush()
+	}
+	return nil
+}
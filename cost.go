@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// price is the price of a CPU hour and of a GB hour of memory
+type price struct {
+	CPUHour, GBHour float64
+}
+
+// pricing maps the queues of the executor to their prices; tasks of queues
+// not listed, or without a queue column, are charged the default price
+type pricing struct {
+	Queues  map[string]price
+	Default *price
+}
+
+// priceOf returns the price of a task and whether it has one
+func (p *pricing) priceOf(rec TraceRecord) (price, bool) {
+	if q, ok := p.Queues[rec.Queue]; ok && rec.Queue != "" {
+		return q, true
+	}
+	if p.Default != nil {
+		return *p.Default, true
+	}
+	return price{}, false
+}
+
+// loadPricing reads a YAML mapping of queue names to prices, e.g.
+//
+//	default: {cpu_hour: 0.04, gb_hour: 0.005}
+//	spot:
+//	  cpu_hour: 0.012
+//	  gb_hour: 0.0015
+//
+// The default entry, if any, prices tasks of other queues.
+func loadPricing(filePath string) (*pricing, error) {
+	mappings, err := loadYAMLMappings(filePath, "pricing")
+	if err != nil {
+		return nil, err
+	}
+	p := &pricing{Queues: make(map[string]price)}
+	for _, m := range mappings {
+		var q price
+		for _, e := range m.Entries {
+			value, err := parseNumber(e.Value)
+			if err == nil && value < 0 {
+				err = fmt.Errorf("negative price")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("pricing file line %d: invalid %s '%s': %w", e.Line, e.Key, e.Value, err)
+			}
+			switch e.Key {
+			case "cpu_hour":
+				q.CPUHour = value
+			case "gb_hour":
+				q.GBHour = value
+			default:
+				return nil, fmt.Errorf("pricing file line %d: unknown price '%s' (use cpu_hour or gb_hour)", e.Line, e.Key)
+			}
+		}
+		if m.Name == "default" {
+			p.Default = &q
+		} else {
+			p.Queues[m.Name] = q
+		}
+	}
+	return p, nil
+}
+
+// processCost is the estimated cost of the tasks of a process
+type processCost struct {
+	Process  string
+	Tasks    int
+	CPUHours float64 // runtime times allocated CPUs
+	GBHours  float64 // runtime times requested memory in GB
+	Cost     float64
+}
+
+// costRecord is a process in the machine-readable output formats of
+// "cost"; the share of the total cost is in percent, omitted if the run
+// cost nothing
+type costRecord struct {
+	Process  string   `json:"process"`
+	Tasks    int      `json:"tasks"`
+	CPUHours float64  `json:"cpu_hours"`
+	GBHours  float64  `json:"gb_hours"`
+	Cost     float64  `json:"cost"`
+	Unit     string   `json:"unit,omitempty"`
+	Share    *float64 `json:"share,omitempty"`
+}
+
+// estimateCosts charges every task its runtime times its allocated CPUs
+// and requested memory at the price of its queue, per process in order of
+// descending cost. Tasks of queues without a price are left out and
+// returned as their total, Process left empty.
+func estimateCosts(records []TraceRecord, p *pricing) ([]*processCost, processCost) {
+	var order []*processCost
+	var unpriced processCost
+	byProcess := make(map[string]*processCost)
+	for _, rec := range records {
+		if rec.Runtime() <= 0 {
+			excludeTask(rec, "no runtime")
+			continue
+		}
+		taskPrice, ok := p.priceOf(rec)
+		if !ok {
+			excludeTask(rec, "no price for queue")
+			unpriced.Tasks++
+			unpriced.CPUHours += allocatedCPUHours(rec)
+			continue
+		}
+		c, ok := byProcess[rec.Process]
+		if !ok {
+			c = &processCost{Process: rec.Process}
+			byProcess[rec.Process] = c
+			order = append(order, c)
+		}
+		hours := rec.Runtime().Hours()
+		cpuHours := float64(max(rec.CPUs, 1)) * hours
+		gbHours := float64(rec.Memory) / (1 << 30) * hours
+		c.Tasks++
+		c.CPUHours += cpuHours
+		c.GBHours += gbHours
+		c.Cost += cpuHours*taskPrice.CPUHour + gbHours*taskPrice.GBHour
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].Cost > order[j].Cost })
+	return order, unpriced
+}
+
+// runCost implements the "cost" subcommand, estimating the cloud or cluster
+// cost of a run per process from CPU hours and GB hours
+func runCost(args []string) error {
+	fs := newFlagSet("cost")
+	input := inputFlags(fs)
+	cpuHour := fs.Float64("price-cpu-hour", 0, "Price of an hour of an allocated CPU")
+	gbHour := fs.Float64("price-gb-hour", 0, "Price of an hour of a GB of requested memory")
+	pricingFile := fs.String("pricing", "", "YAML file of prices per queue (e.g. spot: {cpu_hour: 0.012, gb_hour: 0.0015}), matched to the queue column")
+	unit := fs.String("unit", "", "Currency shown with costs, e.g. USD")
+	profile := presetFlag(fs)
+	fs.Parse(args)
+
+	if *cpuHour < 0 || *gbHour < 0 {
+		return fmt.Errorf("prices cannot be negative")
+	}
+	p := &pricing{}
+	if *pricingFile != "" {
+		var err error
+		if p, err = loadPricing(*pricingFile); err != nil {
+			return err
+		}
+	}
+	// Prices given as flags are the default of a pricing file without one,
+	// and take precedence over those of the preset
+	preset, err := lookupPreset(*profile)
+	if err != nil {
+		return err
+	}
+	if p.Default == nil && (*cpuHour > 0 || *gbHour > 0) {
+		p.Default = &price{CPUHour: *cpuHour, GBHour: *gbHour}
+	}
+	if p.Default == nil && preset != nil && preset.Price != nil {
+		p.Default = preset.Price
+	}
+	if p.Default == nil && len(p.Queues) == 0 {
+		return fmt.Errorf("give prices with --price-cpu-hour and --price-gb-hour or --pricing")
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	if len(p.Queues) > 0 && !trace.HasColumn("queue") {
+		slog.Warn("queue column not found in input file, charging all tasks the default price")
+	}
+
+	costs, unpriced := estimateCosts(trace.Records, p)
+	if unpriced.Tasks > 0 {
+		slog.Warn("tasks of queues without a price are not charged, the total is too low",
+			"tasks", unpriced.Tasks, "cpu_hours", fmt.Sprintf("%.1f", unpriced.CPUHours))
+	}
+	if len(costs) == 0 {
+		return fmt.Errorf("no tasks with a runtime and a price")
+	}
+	suffix := ""
+	if *unit != "" {
+		suffix = " " + *unit
+	}
+	var total processCost
+	for _, c := range costs {
+		total.Tasks += c.Tasks
+		total.CPUHours += c.CPUHours
+		total.GBHours += c.GBHours
+		total.Cost += c.Cost
+	}
+	if outputFormat != "text" {
+		records := make([]costRecord, len(costs))
+		for i, c := range costs {
+			records[i] = costRecord{Process: c.Process, Tasks: c.Tasks, CPUHours: c.CPUHours, GBHours: c.GBHours, Cost: c.Cost, Unit: *unit}
+			if total.Cost > 0 {
+				share := 100 * c.Cost / total.Cost
+				records[i].Share = &share
+			}
+		}
+		return writeRecords(os.Stdout, records)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tCPU HOURS\tGB HOURS\tCOST\tSHARE")
+	for _, c := range costs {
+		share := "-"
+		if total.Cost > 0 {
+			share = FormatPercent(100 * c.Cost / total.Cost)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f\t%.1f\t%s%s\t%s\n", c.Process, c.Tasks, c.CPUHours, c.GBHours, FormatCost(c.Cost), suffix, share)
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%.1f\t%.1f\t%s%s\n", total.Tasks, total.CPUHours, total.GBHours, FormatCost(total.Cost), suffix)
+	w.Flush()
+	return nil
+}
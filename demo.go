@@ -0,0 +1,164 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// demoFiles holds traces of two runs of a small nf-core/rnaseq-like pipeline
+// with six samples: rnaseq.txt includes retried alignments and a straggling
+// QC task, rnaseq-rerun.txt is a later run of the same pipeline on faster
+// storage
+//
+//go:embed demo/*.txt
+var demoFiles embed.FS
+
+const (
+	demoTraceName = "rnaseq.txt"
+	demoRerunName = "rnaseq-rerun.txt"
+)
+
+// tutorialStep is one step of the guided tour through the reports
+type tutorialStep struct {
+	Title   string
+	Text    string
+	Command string
+	Args    []string
+}
+
+// tutorialSteps walk through the reports in the order a new user would
+// typically look at a run
+var tutorialSteps = []tutorialStep{
+	{
+		Title: "Retries",
+		Text: `Failed attempts cost time twice. The retries report shows which processes
+were retried and what initial resources would have avoided the retries.
+In the demo run two STAR_ALIGN tasks were killed for exceeding their memory.`,
+		Command: "retries",
+	},
+	{
+		Title: "Phases",
+		Text: `Phases group processes running at the same time. They show where the wall
+time of a run goes and which processes are worth optimizing.`,
+		Command: "phases",
+	},
+	{
+		Title: "Stragglers",
+		Text: `A fan-out phase ends with its slowest task. The imbalance report flags
+processes whose longest task runs much longer than the average one.`,
+		Command: "imbalance",
+	},
+	{
+		Title: "CPU efficiency",
+		Text: `Efficiency relates used CPU time to requested CPUs. Processes with low
+efficiency request more CPUs than they can use.`,
+		Command: "efficiency",
+	},
+	{
+		Title: "Comparing runs",
+		Text: `The changes report compares two runs and splits the runtime difference into
+the effect of task counts and of faster or slower tasks. Here the demo run
+is compared with a rerun of the same pipeline.`,
+		Command: "changes",
+	},
+}
+
+// writeDemoTrace writes an embedded demo trace to dir and returns its path
+func writeDemoTrace(dir, name string) (string, error) {
+	data, err := demoFiles.ReadFile("demo/" + name)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("error writing demo trace: %w", err)
+	}
+	return path, nil
+}
+
+// runDemoCommand runs a report against the demo traces, which are written to
+// a temporary directory
+func runDemoCommand(name string, args []string) error {
+	run, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command '%s'", name)
+	}
+	if !commandDocs[name].Demo {
+		return fmt.Errorf("command '%s' cannot be run against the demo trace", name)
+	}
+
+	dir, err := os.MkdirTemp("", "nfu-demo-")
+	if err != nil {
+		return fmt.Errorf("error writing demo trace: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	trace, err := writeDemoTrace(dir, demoTraceName)
+	if err != nil {
+		return err
+	}
+	// changes and nodes read runs given as positional arguments
+	if name == "changes" || name == "nodes" {
+		rerun, err := writeDemoTrace(dir, demoRerunName)
+		if err != nil {
+			return err
+		}
+		return run(append(args, trace, rerun))
+	}
+	return run(append([]string{"-i", trace}, args...))
+}
+
+// runTutorial walks through the tutorial steps, running each report against
+// the demo traces
+func runTutorial() error {
+	for i, step := range tutorialSteps {
+		title := fmt.Sprintf("Step %d of %d: %s", i+1, len(tutorialSteps), step.Title)
+		fmt.Printf("%s\n%s\n\n%s\n\n", title, strings.Repeat("=", len(title)), step.Text)
+		fmt.Printf("$ nfu demo %s\n\n", strings.Join(append([]string{step.Command}, step.Args...), " "))
+		if err := runDemoCommand(step.Command, step.Args); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	fmt.Println("Run 'nfu demo export' to write the demo traces and try the reports with your own flags.")
+	return nil
+}
+
+// runDemo implements the "demo" subcommand, running reports against the
+// embedded demo traces, walking through them in a tutorial or exporting the
+// traces
+func runDemo(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: nfu demo <command> [flags]")
+		fmt.Fprintln(os.Stderr, "       nfu demo tutorial")
+		fmt.Fprintln(os.Stderr, "       nfu demo export [-o dir]")
+		fmt.Fprintln(os.Stderr, "\nCommands that can be run against the demo trace:")
+		for _, name := range sortedCommandNames() {
+			if commandDocs[name].Demo {
+				fmt.Fprintf(os.Stderr, "  %s\n", name)
+			}
+		}
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "tutorial":
+		return runTutorial()
+	case "export":
+		fs := newFlagSet("demo export")
+		dir := fs.String("o", ".", "Directory to write the demo traces to")
+		fs.Parse(args[1:])
+		for _, name := range []string{demoTraceName, demoRerunName} {
+			path, err := writeDemoTrace(*dir, name)
+			if err != nil {
+				return err
+			}
+			fmt.Println(path)
+		}
+		return nil
+	}
+	return runDemoCommand(args[0], args[1:])
+}
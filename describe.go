@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// runDescribe implements the "describe" subcommand, listing the processes
+// of a run with the tool and description of the nf-core module they run
+func runDescribe(args []string) error {
+	fs := newFlagSet("describe")
+	input := inputFlags(fs)
+	modulesDir := fs.String("modules", "", "Modules directory of the pipeline, e.g. modules/nf-core, to look up the meta.yml of every process in")
+	fetch := fs.Bool("fetch", false, "Look up modules not found in --modules in the nf-core/modules repository on GitHub")
+	fs.Parse(args)
+
+	if *modulesDir == "" && !*fetch {
+		return fmt.Errorf("give the module metadata with --modules or --fetch")
+	}
+	index, err := newModuleIndex(*modulesDir, *fetch)
+	if err != nil {
+		return fmt.Errorf("error reading modules directory: %w", err)
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	found := 0
+	stats := aggregateByProcess(trace.Records)
+	cpuShare, memoryShare := processShares(stats)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tRUNTIME\tCPU SHARE\tMEMORY SHARE\tTOOLS\tDESCRIPTION")
+	for _, s := range stats {
+		tools, description := "-", "-"
+		if info := index.Lookup(s.Process); info != nil {
+			found++
+			if len(info.Tools) > 0 {
+				tools = strings.Join(info.Tools, ", ")
+			}
+			if info.Description != "" {
+				description = info.Description
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", s.Process, s.Tasks, FormatDuration(s.Runtime),
+			FormatPercent(cpuShare[s.Process]), FormatPercent(memoryShare[s.Process]), tools, description)
+	}
+	w.Flush()
+	if found < len(stats) {
+		fmt.Printf("\nNo module metadata found for %d of %d processes\n", len(stats)-found, len(stats))
+	}
+	return nil
+}
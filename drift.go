@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// driftStats describes how task runtimes of a process change over the run
+type driftStats struct {
+	Process string
+	Tasks   int
+	Span    time.Duration // time between the first and the last task start
+	Mean    time.Duration
+	Fit     regression // runtime in seconds against start offset in hours
+}
+
+// Change returns the relative runtime change predicted over the process span
+func (d driftStats) Change() float64 {
+	if d.Mean <= 0 {
+		return 0
+	}
+	return d.Fit.Slope * d.Span.Hours() / d.Mean.Seconds()
+}
+
+// collectDriftStats regresses task runtime against start time per process
+func collectDriftStats(records []TraceRecord, minTasks int) []driftStats {
+	var order []string
+	starts := make(map[string][]TraceRecord)
+	for _, rec := range records {
+		if rec.Start.IsZero() {
+			excludeTask(rec, "no start time")
+			continue
+		}
+		if rec.Runtime() <= 0 {
+			excludeTask(rec, "no runtime")
+			continue
+		}
+		if _, ok := starts[rec.Process]; !ok {
+			order = append(order, rec.Process)
+		}
+		starts[rec.Process] = append(starts[rec.Process], rec)
+	}
+
+	var result []driftStats
+	for _, process := range order {
+		tasks := starts[process]
+		if len(tasks) < minTasks {
+			continue
+		}
+
+		first, last := tasks[0].Start, tasks[0].Start
+		for _, rec := range tasks {
+			if rec.Start.Before(first) {
+				first = rec.Start
+			}
+			if rec.Start.After(last) {
+				last = rec.Start
+			}
+		}
+
+		x := make([]float64, len(tasks))
+		y := make([]float64, len(tasks))
+		for i, rec := range tasks {
+			x[i] = rec.Start.Sub(first).Hours()
+			y[i] = rec.Runtime().Seconds()
+		}
+
+		result = append(result, driftStats{
+			Process: process,
+			Tasks:   len(tasks),
+			Span:    last.Sub(first),
+			Mean:    time.Duration(mean(y) * float64(time.Second)),
+			Fit:     linearRegression(x, y),
+		})
+	}
+	return result
+}
+
+// runDrift implements the "drift" subcommand, detecting processes whose
+// later tasks run slower (or faster) than earlier ones
+func runDrift(args []string) error {
+	fs := newFlagSet("drift")
+	input := inputFlags(fs)
+	minTasks := fs.Int("min-tasks", 5, "Minimum number of tasks required to analyze a process")
+	threshold := fs.Float64("threshold", 20, "Flag processes whose runtime changes by more than this percentage over the run")
+	minSpan := fs.Duration("min-span", 10*time.Minute, "Minimum time between the first and the last task start required to flag a process")
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	if !trace.HasColumn("start") {
+		return fmt.Errorf("start column not found in input file")
+	}
+
+	stats := collectDriftStats(trace.Records, *minTasks)
+	if len(stats) == 0 {
+		fmt.Printf("No process has at least %d tasks with start times\n", *minTasks)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tSPAN\tMEAN\tSLOPE (s/h)\tCHANGE\tR2\tT\tFLAG")
+	for _, d := range stats {
+		// |t| >= 2 roughly corresponds to a 5% significance level
+		flagged := ""
+		if d.Span >= *minSpan && math.Abs(d.Fit.TStat) >= 2 && math.Abs(d.Change())*100 >= *threshold {
+			flagged = "DRIFT"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%.1f\t%s\t%.2f\t%.2f\t%s\n",
+			d.Process, d.Tasks, FormatDuration(d.Span), FormatDuration(d.Mean),
+			d.Fit.Slope, FormatSignedPercent(100*d.Change()), d.Fit.R2, d.Fit.TStat, flagged)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// efficiencyStats summarizes how well a process used its allocated CPUs
+type efficiencyStats struct {
+	Process    string
+	Tasks      int
+	Efficiency []float64 // %cpu divided by the allocated CPUs, per plausible task
+	Suspect    []TraceRecord
+}
+
+// collectEfficiencyStats computes the CPU efficiency of every process.
+// Tasks without %cpu are left out; tasks with implausible values are
+// excluded from the statistics and reported separately.
+func collectEfficiencyStats(records []TraceRecord) []*efficiencyStats {
+	var order []*efficiencyStats
+	byProcess := make(map[string]*efficiencyStats)
+	for _, rec := range records {
+		stats, ok := byProcess[rec.Process]
+		if !ok {
+			stats = &efficiencyStats{Process: rec.Process}
+			byProcess[rec.Process] = stats
+			order = append(order, stats)
+		}
+		stats.Tasks++
+
+		switch {
+		case rec.CPUSuspect != "":
+			excludeTask(rec, "implausible %cpu")
+			stats.Suspect = append(stats.Suspect, rec)
+		case !rec.HasCPUPercent:
+			excludeTask(rec, "no %cpu")
+		case rec.CPUs > 0:
+			stats.Efficiency = append(stats.Efficiency, rec.CPUPercent/float64(rec.CPUs*100))
+		default:
+			excludeTask(rec, "no cpus")
+		}
+	}
+	return order
+}
+
+// efficiencyRecord is a process in the machine-readable output formats of
+// "efficiency"; efficiencies and shares are in percent, efficiencies are
+// omitted for processes without a task to compute them from
+type efficiencyRecord struct {
+	Process          string   `json:"process"`
+	Tasks            int      `json:"tasks"`
+	MeanEfficiency   *float64 `json:"mean_efficiency,omitempty"`
+	MedianEfficiency *float64 `json:"median_efficiency,omitempty"`
+	Suspect          int      `json:"suspect"`
+	CPUShare         float64  `json:"cpu_share"`
+	MemoryShare      float64  `json:"memory_share"`
+}
+
+// runEfficiency implements the "efficiency" subcommand, reporting CPU
+// utilization relative to the allocated CPUs per process
+func runEfficiency(args []string) error {
+	fs := newFlagSet("efficiency")
+	input := inputFlags(fs)
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	if !trace.HasColumn("%cpu") || !trace.HasColumn("cpus") {
+		return fmt.Errorf("%%cpu and cpus columns are required for efficiency statistics")
+	}
+
+	stats := collectEfficiencyStats(trace.Records)
+	cpuShare, memoryShare := processShares(aggregateByProcess(trace.Records))
+	if outputFormat != "text" {
+		records := make([]efficiencyRecord, len(stats))
+		for i, s := range stats {
+			records[i] = efficiencyRecord{
+				Process:     s.Process,
+				Tasks:       s.Tasks,
+				Suspect:     len(s.Suspect),
+				CPUShare:    cpuShare[s.Process],
+				MemoryShare: memoryShare[s.Process],
+			}
+			if len(s.Efficiency) > 0 {
+				meanEfficiency, medianEfficiency := 100*mean(s.Efficiency), 100*median(s.Efficiency)
+				records[i].MeanEfficiency, records[i].MedianEfficiency = &meanEfficiency, &medianEfficiency
+			}
+		}
+		return writeRecords(os.Stdout, records)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tMEAN EFFICIENCY\tMEDIAN EFFICIENCY\tSUSPECT\tCPU SHARE\tMEMORY SHARE")
+	var suspect []TraceRecord
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%s\t%s\n", s.Process, s.Tasks,
+			formatPercent(mean(s.Efficiency)), formatPercent(median(s.Efficiency)), len(s.Suspect),
+			FormatPercent(cpuShare[s.Process]), FormatPercent(memoryShare[s.Process]))
+		suspect = append(suspect, s.Suspect...)
+	}
+	w.Flush()
+
+	if len(suspect) > 0 {
+		fmt.Printf("\nTasks excluded because of suspicious %%cpu values (%d):\n", len(suspect))
+		for _, rec := range suspect {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", rec.Name, rec.Hash, rec.CPUSuspect)
+		}
+		w.Flush()
+	}
+	return nil
+}
+
+// formatPercent renders a fraction as a percentage, or "-" for NaN
+func formatPercent(fraction float64) string {
+	if formatted := formatFloat(100*fraction, percentDecimals); formatted != "-" {
+		return formatted + "%"
+	}
+	return "-"
+}
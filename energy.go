@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// hourlyRates holds a price or carbon intensity per kWh for every hour of
+// the day, in local time
+type hourlyRates [24]float64
+
+// parseHourlyRates parses rates given as a single value for the whole day or
+// as hour ranges, e.g. "0-7=0.12,7-23=0.30,23-24=0.12"; every hour must be
+// covered
+func parseHourlyRates(spec string) (hourlyRates, error) {
+	var rates hourlyRates
+	if value, err := parseNumber(strings.TrimSpace(spec)); err == nil {
+		for h := range rates {
+			rates[h] = value
+		}
+		return rates, nil
+	}
+
+	var covered [24]bool
+	for _, part := range strings.Split(spec, ",") {
+		hours, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		from, to, ok2 := strings.Cut(hours, "-")
+		if !ok || !ok2 {
+			return rates, fmt.Errorf("invalid rate '%s' (use e.g. 0-7=0.12)", part)
+		}
+		start, err1 := strconv.Atoi(strings.TrimSpace(from))
+		end, err2 := strconv.Atoi(strings.TrimSpace(to))
+		rate, err3 := parseNumber(strings.TrimSpace(value))
+		if err1 != nil || err2 != nil || err3 != nil || start < 0 || end > 24 || start >= end {
+			return rates, fmt.Errorf("invalid rate '%s' (use e.g. 0-7=0.12)", part)
+		}
+		for h := start; h < end; h++ {
+			rates[h], covered[h] = rate, true
+		}
+	}
+	for h, ok := range covered {
+		if !ok {
+			return rates, fmt.Errorf("no rate for hour %d", h)
+		}
+	}
+	return rates, nil
+}
+
+// cost integrates the rates over the interval from start to end for a
+// constant power draw in kW
+func (r *hourlyRates) cost(start, end time.Time, kw float64) float64 {
+	total := 0.0
+	for t := start; t.Before(end); {
+		next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		if next.After(end) {
+			next = end
+		}
+		total += kw * next.Sub(t).Hours() * r[t.Hour()]
+		t = next
+	}
+	return total
+}
+
+// taskPower is the estimated power draw of a task while it ran
+type taskPower struct {
+	Start, End time.Time
+	KW         float64
+}
+
+// estimateTaskPower estimates the power draw of every task from the CPU
+// cores it used (%cpu, or the requested CPUs if %cpu was not recorded)
+func estimateTaskPower(records []TraceRecord, wattsPerCore float64) []taskPower {
+	var tasks []taskPower
+	for _, rec := range records {
+		if rec.Start.IsZero() || rec.Runtime() <= 0 {
+			excludeTask(rec, "no start or runtime")
+			continue
+		}
+		cores := float64(rec.CPUs)
+		if rec.HasCPUPercent && rec.CPUSuspect == "" {
+			cores = rec.CPUPercent / 100
+		}
+		if cores <= 0 {
+			excludeTask(rec, "no cpus or %cpu")
+			continue
+		}
+		tasks = append(tasks, taskPower{Start: rec.Start, End: rec.End(), KW: cores * wattsPerCore / 1000})
+	}
+	return tasks
+}
+
+// runEnergy implements the "energy" subcommand, estimating the energy of a
+// run and what it would cost, in money or carbon, if it had started at
+// another hour of the day
+func runEnergy(args []string) error {
+	fs := newFlagSet("energy")
+	input := inputFlags(fs)
+	wattsPerCore := fs.Float64("watts-per-core", 12, "Power draw of a fully used CPU core in watts, including its share of memory and cooling")
+	ratesSpec := fs.String("rates", "", "Price or carbon intensity per kWh by hour of the day, e.g. 0-7=0.12,7-23=0.30,23-24=0.12")
+	unit := fs.String("unit", "", "Unit of the rates shown with costs, e.g. EUR or gCO2")
+	fs.Parse(args)
+
+	var rates hourlyRates
+	if *ratesSpec != "" {
+		var err error
+		if rates, err = parseHourlyRates(*ratesSpec); err != nil {
+			return err
+		}
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	tasks := estimateTaskPower(trace.Records, *wattsPerCore)
+	if len(tasks) == 0 {
+		return fmt.Errorf("no tasks with start time and runtime found")
+	}
+	var runStart time.Time
+	kwh := 0.0
+	for _, task := range tasks {
+		if runStart.IsZero() || task.Start.Before(runStart) {
+			runStart = task.Start
+		}
+		kwh += task.KW * task.End.Sub(task.Start).Hours()
+	}
+	fmt.Printf("Energy: %s kWh (%g W per fully used core)\n", FormatCost(kwh), *wattsPerCore)
+	if *ratesSpec == "" {
+		return nil
+	}
+
+	// Shift the whole run so that it starts at every full hour of the day
+	costAt := func(shift time.Duration) float64 {
+		total := 0.0
+		for _, task := range tasks {
+			total += rates.cost(task.Start.Add(shift), task.End.Add(shift), task.KW)
+		}
+		return total
+	}
+	actual := costAt(0)
+	suffix := ""
+	if *unit != "" {
+		suffix = " " + *unit
+	}
+	fmt.Printf("Cost as run, started at %s: %s%s\n", runStart.Format("15:04"), FormatCost(actual), suffix)
+
+	type option struct {
+		hour  int
+		shift time.Duration
+		cost  float64
+	}
+	var options []option
+	best := -1
+	for h := 0; h < 24; h++ {
+		shift := time.Date(runStart.Year(), runStart.Month(), runStart.Day(), h, 0, 0, 0, runStart.Location()).Sub(runStart)
+		if shift < 0 {
+			shift += 24 * time.Hour
+		}
+		options = append(options, option{h, shift, costAt(shift)})
+		if best < 0 || options[h].cost < options[best].cost {
+			best = h
+		}
+	}
+	if b := options[best]; b.cost < actual {
+		fmt.Printf("Cheapest start: %02d:00 (%s later): %s%s (%s)\n", b.hour, FormatDuration(b.shift),
+			FormatCost(b.cost), suffix, FormatSignedPercent(100*(b.cost/actual-1)))
+	} else {
+		fmt.Println("Cheapest start: as run")
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tCOST\tCHANGE")
+	for _, o := range options {
+		change := "-"
+		if actual > 0 {
+			change = FormatSignedPercent(100 * (o.cost/actual - 1))
+		}
+		fmt.Fprintf(w, "%02d:00\t%s%s\t%s\n", o.hour, FormatCost(o.cost), suffix, change)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// expectation is the number of tasks a process should run: PerSample tasks
+// for every sample, or Total tasks for the whole run
+type expectation struct {
+	Process   string
+	pattern   *regexp.Regexp
+	Count     int
+	PerSample bool
+}
+
+// Expected returns the number of tasks expected for the given sample count
+func (e *expectation) Expected(samples int) int {
+	if e.PerSample {
+		return e.Count * samples
+	}
+	return e.Count
+}
+
+// loadExpectations reads a YAML mapping of process names or regular
+// expressions to the number of tasks they run per sample, or for the whole
+// run when followed by "total", e.g.
+//
+//	FASTQC: 2            # one task per read file of paired samples
+//	STAR_ALIGN: 1
+//	MULTIQC: 1 total
+//
+// Only this subset of YAML (a mapping of scalars) is supported.
+func loadExpectations(filePath string) ([]*expectation, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening expectations file: %w", err)
+	}
+	defer file.Close()
+
+	var expectations []*expectation
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		trimmed := strings.TrimSpace(stripYAMLComment(scanner.Text()))
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+
+		// The process pattern may contain colons when quoted
+		key, value := trimmed, ""
+		if strings.HasPrefix(trimmed, `"`) || strings.HasPrefix(trimmed, "'") {
+			if end := strings.IndexByte(trimmed[1:], trimmed[0]); end >= 0 {
+				key, value = trimmed[:end+2], trimmed[end+2:]
+			}
+		} else if i := strings.LastIndexByte(trimmed, ':'); i >= 0 {
+			key, value = trimmed[:i], trimmed[i:]
+		}
+		value, ok := strings.CutPrefix(strings.TrimSpace(value), ":")
+		if !ok {
+			return nil, fmt.Errorf("expectations file line %d: expected 'process: count'", lineNum)
+		}
+		e := &expectation{Process: unquoteYAML(strings.TrimSpace(key)), PerSample: true}
+		if e.pattern, err = regexp.Compile("^(?:" + e.Process + ")$"); err != nil {
+			return nil, fmt.Errorf("expectations file line %d: invalid process pattern %q: %w", lineNum, e.Process, err)
+		}
+		value = unquoteYAML(strings.TrimSpace(value))
+		if count, ok := strings.CutSuffix(value, "total"); ok {
+			value, e.PerSample = strings.TrimSpace(count), false
+		}
+		if e.Count, err = strconv.Atoi(value); err != nil || e.Count < 0 {
+			return nil, fmt.Errorf("expectations file line %d: invalid task count '%s' (use e.g. 2 or '1 total')", lineNum, value)
+		}
+		expectations = append(expectations, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading expectations file: %w", err)
+	}
+	return expectations, nil
+}
+
+// taskCount is the number of distinct tasks of the processes matching an
+// expectation, and how many of them succeeded in any attempt
+type taskCount struct {
+	Tasks, Succeeded int
+}
+
+// countExpectedTasks counts the tasks of the processes matching every
+// expectation. Tasks are told apart by name, so retries count once.
+func countExpectedTasks(records []TraceRecord, expectations []*expectation) []taskCount {
+	counts := make([]taskCount, len(expectations))
+	for i, e := range expectations {
+		succeeded := make(map[string]bool)
+		for j, rec := range records {
+			if !e.pattern.MatchString(rec.Process) && !e.pattern.MatchString(shortProcessName(rec.Process)) {
+				continue
+			}
+			name := rec.Name
+			if name == "" {
+				name = strconv.Itoa(j)
+			}
+			ok := (rec.Status == "" && !rec.Incomplete()) || isSuccess(rec.Status)
+			succeeded[name] = succeeded[name] || ok
+		}
+		counts[i].Tasks = len(succeeded)
+		for _, ok := range succeeded {
+			if ok {
+				counts[i].Succeeded++
+			}
+		}
+	}
+	return counts
+}
+
+// runExpect implements the "expect" subcommand, verifying that every process
+// ran the number of tasks expected for the number of samples
+func runExpect(args []string) error {
+	fs := newFlagSet("expect")
+	input := inputFlags(fs)
+	expectFile := fs.String("e", "", "YAML file of the number of tasks expected per sample for every process")
+	fs.StringVar(expectFile, "expectations", "", "YAML file of the number of tasks expected per sample for every process")
+	samples := fs.Int("samples", 0, "Number of samples of the run")
+	samplesheet := fs.String("samplesheet", "", "Samplesheet of the run, to count its samples")
+	fs.Parse(args)
+
+	if *expectFile == "" {
+		return fmt.Errorf("--expectations is required")
+	}
+	if (*samples > 0) == (*samplesheet != "") {
+		return fmt.Errorf("give the number of samples with either --samples or --samplesheet")
+	}
+	expectations, err := loadExpectations(*expectFile)
+	if err != nil {
+		return err
+	}
+	if *samplesheet != "" {
+		sheet, err := readSamplesheet(*samplesheet)
+		if err != nil {
+			return err
+		}
+		*samples = len(sheet)
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	counts := countExpectedTasks(trace.Records, expectations)
+	failed := 0
+	fmt.Printf("Samples: %d\n\n", *samples)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tEXPECTED\tTASKS\tSUCCEEDED\tRESULT")
+	for i, e := range expectations {
+		expected, c := e.Expected(*samples), counts[i]
+		result := "OK"
+		switch {
+		case c.Succeeded < expected:
+			result = fmt.Sprintf("FAIL (%d missing)", expected-c.Succeeded)
+		case c.Tasks > expected:
+			result = fmt.Sprintf("FAIL (%d extra)", c.Tasks-expected)
+		}
+		if result != "OK" {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", e.Process, expected, c.Tasks, c.Succeeded, result)
+	}
+	w.Flush()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d processes did not run the expected number of tasks", failed, len(expectations))
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parsedColumns are the trace columns nfu interprets; other columns are ignored
+var parsedColumns = []string{
+	"task_id", "hash", "name", "process", "tag", "status", "exit", "attempt", "cpus", "memory", "time",
+	"submit", "start", "complete", "duration", "realtime", "%cpu", "peak_rss", "peak_vmem", "rchar", "wchar", "hostname", "queue",
+}
+
+// reportColumns are columns some reports depend on; their absence is pointed out
+var reportColumns = []string{"realtime", "start", "complete", "%cpu", "cpus", "memory", "hostname", "attempt", "status"}
+
+// columnValues counts the kinds of values found in a column
+type columnValues struct {
+	Present      int
+	Missing      int // "-" or empty
+	PlainNumbers int // numbers without unit
+	DecimalComma int
+}
+
+// explanation records how a trace was interpreted, printed with --explain
+type explanation struct {
+	Columns         []string
+	Values          map[string]*columnValues
+	Records         int
+	Blank           int
+	Headers         int // repeated header lines of concatenated traces
+	Duplicates      int // rows of task attempts already read
+	ClockSkew       int // records with timestamps out of order
+	ClockSkewPolicy string
+	Incidents       int // records of tasks that ran during known incidents
+	IncidentPolicy  string
+	Reclassified    int      // records given a status derived by the status rules
+	Dropped         []string // records left out by every selection, e.g. "3 of other runs (--runs 2)"
+	FieldCount      []string // lines with an unexpected number of fields
+	Malformed       []*FieldError
+}
+
+func newExplanation(columns []string) *explanation {
+	e := &explanation{Columns: columns, Values: make(map[string]*columnValues)}
+	for _, col := range columns {
+		e.Values[col] = &columnValues{}
+	}
+	return e
+}
+
+// addColumns adds the columns not seen yet, of a trace read from several
+// files with different columns
+func (e *explanation) addColumns(columns []string) {
+	for _, col := range columns {
+		if _, ok := e.Values[col]; !ok {
+			e.Columns = append(e.Columns, col)
+			e.Values[col] = &columnValues{}
+		}
+	}
+}
+
+// observe records the kinds of values of one trace line
+func (e *explanation) observe(fields []string) {
+	for i, col := range e.Columns {
+		if i >= len(fields) {
+			break
+		}
+		values := e.Values[col]
+		value := strings.TrimSpace(fields[i])
+		if value == "" || value == "-" {
+			values.Missing++
+			continue
+		}
+		values.Present++
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			values.PlainNumbers++
+		}
+		if strings.Count(value, ",") == 1 && !strings.Contains(value, ".") {
+			values.DecimalComma++
+		}
+	}
+}
+
+// unitAssumptions describes how the values of the parsed columns were read
+func (e *explanation) unitAssumptions() []string {
+	var notes []string
+	describe := func(columns []string, plain, withUnit string) {
+		var found []string
+		plainNumbers, present := 0, 0
+		for _, col := range columns {
+			if values, ok := e.Values[col]; ok && values.Present > 0 {
+				found = append(found, col)
+				plainNumbers += values.PlainNumbers
+				present += values.Present
+			}
+		}
+		switch {
+		case len(found) == 0:
+			return
+		case plainNumbers == present:
+			notes = append(notes, fmt.Sprintf("%s: %s", strings.Join(found, ", "), plain))
+		case plainNumbers == 0:
+			notes = append(notes, fmt.Sprintf("%s: %s", strings.Join(found, ", "), withUnit))
+		default:
+			notes = append(notes, fmt.Sprintf("%s: %s; %d of %d values without unit read as %s",
+				strings.Join(found, ", "), withUnit, plainNumbers, present, plain))
+		}
+	}
+
+	describe([]string{"duration", "realtime", "time"},
+		"numbers without unit read as milliseconds (trace.raw = true)",
+		"values with units (d, h, m, s, ms)")
+	describe([]string{"memory"},
+		"numbers without unit read as bytes (trace.raw = true)",
+		"binary units (1 GB = 1024 MB)")
+	zone, _ := time.Now().Zone()
+	describe([]string{"submit", "start", "complete"},
+		"numbers read as milliseconds since the epoch (trace.raw = true)",
+		"timestamps read in the local time zone ("+zone+")")
+	if values, ok := e.Values["%cpu"]; ok && values.Present > 0 {
+		notes = append(notes, "%cpu: percent of a single CPU (100% = one fully used core)")
+	}
+
+	for _, col := range e.Columns {
+		if values := e.Values[col]; values.DecimalComma > 0 && containsString(parsedColumns, col) {
+			notes = append(notes, fmt.Sprintf("%s: %d values with a decimal comma", col, values.DecimalComma))
+		}
+	}
+	return notes
+}
+
+// print writes the explanation of the trace read from path
+func (e *explanation) print(w io.Writer, path string) {
+	var used, ignored, absent []string
+	for _, col := range e.Columns {
+		if containsString(parsedColumns, col) {
+			used = append(used, col)
+		} else {
+			ignored = append(ignored, col)
+		}
+	}
+	for _, col := range reportColumns {
+		if !containsString(e.Columns, col) {
+			absent = append(absent, col)
+		}
+	}
+
+	fmt.Fprintf(w, "Explanation of %s:\n", path)
+	fmt.Fprintf(w, "  Columns used (%d): %s\n", len(used), strings.Join(used, ", "))
+	if len(ignored) > 0 {
+		fmt.Fprintf(w, "  Columns ignored (%d): %s\n", len(ignored), strings.Join(ignored, ", "))
+	}
+	if len(absent) > 0 {
+		fmt.Fprintf(w, "  Columns not present: %s (reports relying on them skip the affected tasks)\n", strings.Join(absent, ", "))
+	}
+	for _, col := range used {
+		if values := e.Values[col]; values.Missing > 0 {
+			fmt.Fprintf(w, "  Column %s: %d of %d values missing\n", col, values.Missing, values.Missing+values.Present)
+		}
+	}
+
+	fmt.Fprintln(w, "  Units assumed:")
+	for _, note := range e.unitAssumptions() {
+		fmt.Fprintf(w, "    %s\n", note)
+	}
+
+	fmt.Fprintf(w, "  Rows read: %d", e.Records)
+	var skipped []string
+	if e.Blank > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d blank lines", e.Blank))
+	}
+	if e.Headers > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d repeated header lines", e.Headers))
+	}
+	if e.Duplicates > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d duplicate task rows (same hash and attempt)", e.Duplicates))
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(w, " (skipped %s)", strings.Join(skipped, ", "))
+	}
+	fmt.Fprintln(w)
+	if e.ClockSkew > 0 {
+		fmt.Fprintf(w, "  Records with timestamps out of order (clock skew): %d, policy %s\n", e.ClockSkew, e.ClockSkewPolicy)
+	}
+	if e.Reclassified > 0 {
+		fmt.Fprintf(w, "  Records given a derived status by the status rules: %d\n", e.Reclassified)
+	}
+	if e.Incidents > 0 {
+		fmt.Fprintf(w, "  Records of tasks that ran during known incidents: %d, policy %s\n", e.Incidents, e.IncidentPolicy)
+	}
+	if len(e.Dropped) > 0 {
+		fmt.Fprintln(w, "  Records left out:")
+		for _, line := range e.Dropped {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+	for _, line := range e.FieldCount {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+	if len(e.Malformed) > 0 {
+		fmt.Fprintf(w, "  Malformed fields left empty (%d):\n", len(e.Malformed))
+		for _, fieldErr := range e.Malformed {
+			fmt.Fprintf(w, "    %v\n", fieldErr)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// exclusionLog collects the tasks a report left out of its statistics,
+// grouped by reason in the order the reasons first occurred
+type exclusionLog struct {
+	Reasons []string
+	Tasks   map[string][]string
+}
+
+// exclusions is set when --explain is given; reports record excluded tasks
+// with excludeTask
+var exclusions *exclusionLog
+
+// excludeTask records that a report left out a task and why
+func excludeTask(rec TraceRecord, reason string) {
+	if exclusions == nil {
+		return
+	}
+	if _, ok := exclusions.Tasks[reason]; !ok {
+		exclusions.Reasons = append(exclusions.Reasons, reason)
+	}
+	name := rec.Name
+	if name == "" {
+		name = rec.Process
+	}
+	exclusions.Tasks[reason] = append(exclusions.Tasks[reason], name)
+}
+
+// printExclusions writes the tasks excluded by the report, if --explain is given
+func printExclusions(w io.Writer) {
+	if exclusions == nil {
+		return
+	}
+	fmt.Fprintln(w)
+	if len(exclusions.Reasons) == 0 {
+		fmt.Fprintln(w, "Rows excluded by the report: none")
+		return
+	}
+	fmt.Fprintln(w, "Rows excluded by the report:")
+	for _, reason := range exclusions.Reasons {
+		tasks := exclusions.Tasks[reason]
+		examples := tasks
+		if len(examples) > 3 {
+			examples = examples[:3]
+		}
+		more := ""
+		if len(tasks) > len(examples) {
+			more = fmt.Sprintf(", ... %d more", len(tasks)-len(examples))
+		}
+		fmt.Fprintf(w, "  %d  %s (%s%s)\n", len(tasks), reason, strings.Join(examples, ", "), more)
+	}
+}
+
+// explainTrace prints the explanation of a trace to stderr and starts
+// recording the tasks excluded by the report
+func explainTrace(trace *Trace) {
+	if trace.Explanation == nil {
+		return
+	}
+	trace.Explanation.print(os.Stderr, trace.Path)
+	if exclusions == nil {
+		exclusions = &exclusionLog{Tasks: make(map[string][]string)}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RowFilter restricts a trace to the tasks matching all of the given
+// conditions; empty conditions match every task
+type RowFilter struct {
+	Status  string // comma-separated statuses, e.g. "FAILED,CACHED"
+	Process string // regular expression searched for in the process name
+	Tag     string // regular expression searched for in the tag
+
+	// SubmittedAfter and SubmittedBefore bound the submission time, with
+	// times of day as for ReadOptions.Since and Until
+	SubmittedAfter, SubmittedBefore string
+}
+
+// filterFlags registers the flags of a RowFilter
+func filterFlags(fs *flag.FlagSet, f *RowFilter) {
+	fs.StringVar(&f.Status, "status", "", "Only include tasks with these statuses, comma-separated (e.g. FAILED,CACHED)")
+	fs.StringVar(&f.Process, "process", "", "Only include tasks of processes matching this regular expression (e.g. 'fastqc|trim.*')")
+	fs.StringVar(&f.Tag, "tag", "", "Only include tasks with tags matching this regular expression")
+	fs.StringVar(&f.SubmittedAfter, "submitted-after", "", "Only include tasks submitted at or after this time")
+	fs.StringVar(&f.SubmittedBefore, "submitted-before", "", "Only include tasks submitted before this time")
+}
+
+// rowPredicate reports whether a task is kept by a RowFilter
+type rowPredicate func(rec TraceRecord) bool
+
+// submitted returns the submission time of a task, falling back to its
+// start for traces without a submit column
+func submitted(rec TraceRecord) time.Time {
+	if !rec.Submit.IsZero() {
+		return rec.Submit
+	}
+	return rec.Start
+}
+
+// runStart returns the earliest submission time of the tasks
+func runStart(records []TraceRecord) time.Time {
+	var start time.Time
+	for _, rec := range records {
+		if ts := submitted(rec); !ts.IsZero() && (start.IsZero() || ts.Before(start)) {
+			start = ts
+		}
+	}
+	return start
+}
+
+// predicates compiles the conditions of the filter against the run started
+// at start
+func (f RowFilter) predicates(start time.Time) ([]rowPredicate, error) {
+	var preds []rowPredicate
+	if f.Status != "" {
+		statuses := make(map[string]bool)
+		for _, s := range strings.Split(f.Status, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				statuses[strings.ToUpper(s)] = true
+			}
+		}
+		preds = append(preds, func(rec TraceRecord) bool { return statuses[strings.ToUpper(rec.Status)] })
+	}
+	for _, c := range []struct {
+		flag, pattern string
+		value         func(TraceRecord) string
+	}{
+		{"process", f.Process, func(rec TraceRecord) string { return rec.Process }},
+		{"tag", f.Tag, func(rec TraceRecord) string { return rec.Tag }},
+	} {
+		if c.pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + c.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern: %w", c.flag, err)
+		}
+		value := c.value
+		preds = append(preds, func(rec TraceRecord) bool { return re.MatchString(value(rec)) })
+	}
+	if f.SubmittedAfter != "" {
+		after, err := resolveTimeBound(f.SubmittedAfter, start)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, func(rec TraceRecord) bool { return !submitted(rec).Before(after) })
+	}
+	if f.SubmittedBefore != "" {
+		before, err := resolveTimeBound(f.SubmittedBefore, start)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, func(rec TraceRecord) bool { ts := submitted(rec); return !ts.IsZero() && ts.Before(before) })
+	}
+	return preds, nil
+}
+
+// filterRows keeps the tasks matching every condition of the filter
+func filterRows(records []TraceRecord, f RowFilter) ([]TraceRecord, error) {
+	preds, err := f.predicates(runStart(records))
+	if err != nil || len(preds) == 0 {
+		return records, err
+	}
+	var result []TraceRecord
+	for _, rec := range records {
+		keep := true
+		for _, pred := range preds {
+			if !pred(rec) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result = append(result, rec)
+		}
+	}
+	slog.Debug("filtered rows", "records", len(result), "dropped", len(records)-len(result))
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// inGitHubActions reports whether nfu runs in a GitHub Actions job
+func inGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// annotateError emits a workflow command that shows err as an error
+// annotation of the GitHub Actions job
+func annotateError(err error) {
+	message := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(err.Error())
+	fmt.Fprintf(os.Stdout, "::error title=nfu::%s\n", message)
+}
+
+// runWithStepSummary runs a command and, in a GitHub Actions job, appends
+// its report to the job summary at $GITHUB_STEP_SUMMARY. The report still
+// goes to stdout as well.
+func runWithStepSummary(name string, run func([]string) error, args []string) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" || !inGitHubActions() {
+		return run(args)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return run(args)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	var report bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(stdout, &report), r)
+		close(copied)
+	}()
+
+	runErr := run(args)
+	w.Close()
+	<-copied
+	os.Stdout = stdout
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "### nfu %s\n\n", strings.Join(append([]string{name}, args...), " "))
+	if report.Len() > 0 {
+		fmt.Fprintf(&summary, "```text\n%s", report.String())
+		if !strings.HasSuffix(report.String(), "\n") {
+			summary.WriteString("\n")
+		}
+		summary.WriteString("```\n\n")
+	}
+	if runErr != nil {
+		fmt.Fprintf(&summary, "> **Error:** %s\n\n", runErr)
+	}
+
+	// A job summary that cannot be written must not fail the report itself
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err == nil {
+		_, err = f.WriteString(summary.String())
+		f.Close()
+	}
+	if err != nil {
+		slog.Warn("error writing job summary", "path", path, "error", err)
+	}
+	return runErr
+}
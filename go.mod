@@ -0,0 +1,3 @@
+module github.com/vmikk/nfu
+
+go 1.21
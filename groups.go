@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// processGroup maps a user-defined group name to the processes it contains
+type processGroup struct {
+	Name     string
+	Patterns []*regexp.Regexp
+}
+
+// ProcessGroups is an ordered list of groups; a process belongs to the first
+// group with a matching pattern
+type ProcessGroups []processGroup
+
+// Group returns the group of a process, or the process name itself when no
+// group matches. Patterns are matched against both the fully qualified and
+// the short process name.
+func (g ProcessGroups) Group(process string) string {
+	short := shortProcessName(process)
+	for _, group := range g {
+		for _, pattern := range group.Patterns {
+			if pattern.MatchString(process) || pattern.MatchString(short) {
+				return group.Name
+			}
+		}
+	}
+	return process
+}
+
+// Apply replaces the process name of every record by its group
+func (g ProcessGroups) Apply(records []TraceRecord) {
+	for i := range records {
+		records[i].Process = g.Group(records[i].Process)
+	}
+}
+
+// loadProcessGroups reads a YAML mapping of group names to lists of process
+// names or regular expressions, e.g.
+//
+//	QC: [FASTQC, MULTIQC]
+//	alignment:
+//	  - STAR_ALIGN
+//	  - "SAMTOOLS_.*"
+//
+// Only this subset of YAML (a mapping of scalars or lists) is supported.
+func loadProcessGroups(filePath string) (ProcessGroups, error) {
+	lists, err := loadYAMLLists(filePath, "groups")
+	if err != nil {
+		return nil, err
+	}
+	groups := make(ProcessGroups, len(lists))
+	for i, list := range lists {
+		groups[i].Name = list.Name
+		for _, item := range list.Items {
+			pattern, err := regexp.Compile("^(?:" + item.Value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("groups file line %d: invalid pattern %q: %w", item.Line, item.Value, err)
+			}
+			groups[i].Patterns = append(groups[i].Patterns, pattern)
+		}
+	}
+	return groups, nil
+}
+
+// yamlList is a key of a YAML mapping of lists with its items
+type yamlList struct {
+	Name  string
+	Items []yamlItem
+}
+
+// yamlItem is an unquoted list item and the line it is on
+type yamlItem struct {
+	Line  int
+	Value string
+}
+
+// loadYAMLLists reads a YAML mapping of keys to a scalar or a list, in
+// flow ([a, b]) or block style, preserving the order of the keys; what
+// names the kind of file in errors
+func loadYAMLLists(filePath, what string) ([]yamlList, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s file: %w", what, err)
+	}
+	defer file.Close()
+
+	var lists []yamlList
+	addItem := func(lineNum int, value string) error {
+		if len(lists) == 0 {
+			return fmt.Errorf("%s file line %d: list item outside of a key", what, lineNum)
+		}
+		list := &lists[len(lists)-1]
+		list.Items = append(list.Items, yamlItem{Line: lineNum, Value: unquoteYAML(value)})
+		return nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := stripYAMLComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if err := addItem(lineNum, strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("%s file line %d: expected 'key:' or '- item'", what, lineNum)
+		}
+		lists = append(lists, yamlList{Name: unquoteYAML(strings.TrimSpace(key))})
+
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					if err := addItem(lineNum, item); err != nil {
+						return nil, err
+					}
+				}
+			}
+		default:
+			if err := addItem(lineNum, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s file: %w", what, err)
+	}
+
+	return lists, nil
+}
+
+// stripYAMLComment removes a trailing "# comment" that is not part of a quoted value
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteYAML removes surrounding single or double quotes from a scalar;
+// within single quotes, a doubled quote stands for one
+func unquoteYAML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		if value[0] == '\'' {
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// quoteYAML single-quotes a scalar so that unquoteYAML returns it exactly,
+// whatever backslashes, colons or quotes it contains
+func quoteYAML(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// quotedKeyEnd returns the index just past the closing quote of a key
+// starting with a quote, skipping doubled single quotes, or -1 if the
+// quote is not closed
+func quotedKeyEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] != s[0] {
+			continue
+		}
+		if s[0] == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return -1
+}
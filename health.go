@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Thresholds of the health checks; below them a process is not an issue
+const (
+	healthRetriedShare = 0.1              // share of tasks retried
+	healthEfficiency   = 0.5              // mean CPU efficiency of multi-CPU tasks
+	healthQueueWait    = 10 * time.Minute // median wait from submission to start
+	healthHighShare    = 0.10             // share of the allocated CPU hours lost for a high severity
+	healthMediumShare  = 0.02             // and for a medium severity
+)
+
+// healthIssue is a problem of a run with advice on how to fix it
+type healthIssue struct {
+	Lost   float64 // allocated CPU hours lost, the severity of the issue
+	Issue  string
+	Advice string
+}
+
+// healthRecord is an issue in the machine-readable output formats of
+// "health", in order of severity
+type healthRecord struct {
+	Rank     int     `json:"rank"`
+	Severity string  `json:"severity"` // high, medium or low
+	Issue    string  `json:"issue"`
+	Advice   string  `json:"advice"`
+	CPUHours float64 `json:"cpu_hours"` // allocated CPU hours lost
+}
+
+// severity rates an issue by its share of the allocated CPU hours of a run
+func (i healthIssue) severity(total float64) string {
+	switch {
+	case i.Lost >= healthHighShare*total:
+		return "HIGH"
+	case i.Lost >= healthMediumShare*total:
+		return "MEDIUM"
+	}
+	return "LOW"
+}
+
+// allocatedCPUHours returns the CPU hours allocated to a task
+func allocatedCPUHours(rec TraceRecord) float64 {
+	return float64(max(rec.CPUs, 1)) * rec.Runtime().Hours()
+}
+
+// isOOMKill reports whether a task was killed for exceeding its memory:
+// exit code 137 (SIGKILL) or an OOM status derived by --status-rules
+func isOOMKill(rec TraceRecord) bool {
+	return rec.Status == "OOM" || (rec.Exit == "137" && !isSuccess(rec.Status))
+}
+
+// diagnoseHealth looks for out-of-memory kills, processes with many retried
+// tasks, low CPU efficiency and long queue waits, ranked by the allocated
+// CPU hours they cost. For queue waits these are the CPU hours the waiting
+// tasks were allocated while in the queue.
+func diagnoseHealth(records []TraceRecord) []healthIssue {
+	var issues []healthIssue
+
+	type oomKills struct {
+		tasks  int
+		lost   float64
+		memory int64
+	}
+	var oomOrder []string
+	ooms := make(map[string]*oomKills)
+	for _, rec := range records {
+		if !isOOMKill(rec) {
+			continue
+		}
+		k, ok := ooms[rec.Process]
+		if !ok {
+			k = &oomKills{}
+			ooms[rec.Process] = k
+			oomOrder = append(oomOrder, rec.Process)
+		}
+		k.tasks++
+		k.lost += allocatedCPUHours(rec)
+		k.memory = max(k.memory, rec.Memory)
+	}
+	for _, process := range oomOrder {
+		k := ooms[process]
+		advice := "raise its memory"
+		if k.memory > 0 {
+			// The next step above the killed request, which roundMemory
+			// would return unchanged if it already was a whole step
+			raised := roundMemory(float64(k.memory) + 1)
+			advice = fmt.Sprintf("raise its memory above %s, e.g. memory = { %s * task.attempt } with errorStrategy 'retry'",
+				FormatSize(k.memory), strings.Replace(configMemory(raised), " ", ".", 1))
+		}
+		issues = append(issues, healthIssue{
+			Lost:   k.lost,
+			Issue:  fmt.Sprintf("%s: %d tasks killed for exceeding their memory", shortProcessName(process), k.tasks),
+			Advice: advice,
+		})
+	}
+
+	runtimes := make(map[string]time.Duration)
+	cpus := make(map[string]int)
+	hasCPUPercent := false
+	for _, rec := range records {
+		runtimes[rec.Process] += rec.Runtime()
+		cpus[rec.Process] = max(cpus[rec.Process], rec.CPUs, 1)
+		hasCPUPercent = hasCPUPercent || rec.HasCPUPercent
+	}
+	for _, stats := range collectRetryStats(records) {
+		if stats.Retried == 0 || float64(stats.Retried) < healthRetriedShare*float64(stats.Tasks) {
+			continue
+		}
+		issues = append(issues, healthIssue{
+			Lost: float64(cpus[stats.Process]) * stats.Wasted.Hours(),
+			Issue: fmt.Sprintf("%s: %d of %d tasks retried (%s)", shortProcessName(stats.Process), stats.Retried, stats.Tasks,
+				FormatPercent(100*float64(stats.Retried)/float64(stats.Tasks))),
+			Advice: "start with the resources that sufficed, see 'nfu retries'",
+		})
+	}
+
+	for _, stats := range collectEfficiencyStats(records) {
+		if !hasCPUPercent || len(stats.Efficiency) == 0 || cpus[stats.Process] < 2 {
+			continue
+		}
+		efficiency := mean(stats.Efficiency)
+		if efficiency >= healthEfficiency {
+			continue
+		}
+		used := max(int(efficiency*float64(cpus[stats.Process])+0.999), 1)
+		issues = append(issues, healthIssue{
+			Lost: (1 - efficiency) * float64(cpus[stats.Process]) * runtimes[stats.Process].Hours(),
+			Issue: fmt.Sprintf("%s: %s CPU efficiency of %d CPUs", shortProcessName(stats.Process),
+				formatPercent(efficiency), cpus[stats.Process]),
+			Advice: fmt.Sprintf("lower cpus to %d, or check that the tool is given task.cpus threads", used),
+		})
+	}
+
+	waits := make(map[string][]float64)
+	var waitOrder []string
+	for _, rec := range records {
+		if rec.Submit.IsZero() || rec.Start.IsZero() {
+			continue
+		}
+		if _, ok := waits[rec.Process]; !ok {
+			waitOrder = append(waitOrder, rec.Process)
+		}
+		waits[rec.Process] = append(waits[rec.Process], float64(max(rec.Start.Sub(rec.Submit), 0)))
+	}
+	for _, process := range waitOrder {
+		wait := time.Duration(median(waits[process]))
+		if wait < healthQueueWait {
+			continue
+		}
+		var total time.Duration
+		for _, w := range waits[process] {
+			total += time.Duration(w)
+		}
+		issues = append(issues, healthIssue{
+			Lost:   float64(cpus[process]) * total.Hours(),
+			Issue:  fmt.Sprintf("%s: tasks waited %s in the queue (median)", shortProcessName(process), FormatDuration(wait)),
+			Advice: "request fewer resources or a less busy queue, or raise the executor queueSize",
+		})
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Lost > issues[j].Lost })
+	return issues
+}
+
+// runHealth implements the "health" subcommand, a short triage of the
+// issues of a run ranked by severity with advice on each
+func runHealth(args []string) error {
+	fs := newFlagSet("health")
+	input := inputFlags(fs)
+	top := fs.Int("top", 5, "Number of issues to report")
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	issues := diagnoseHealth(trace.Records)
+	total := 0.0
+	for _, rec := range trace.Records {
+		total += allocatedCPUHours(rec)
+	}
+	shown := min(len(issues), max(*top, 1))
+	if outputFormat != "text" {
+		records := make([]healthRecord, shown)
+		for i, issue := range issues[:shown] {
+			records[i] = healthRecord{Rank: i + 1, Severity: strings.ToLower(issue.severity(total)),
+				Issue: issue.Issue, Advice: issue.Advice, CPUHours: issue.Lost}
+		}
+		return writeRecords(os.Stdout, records)
+	}
+	if len(issues) == 0 {
+		fmt.Println("No issues found")
+		return nil
+	}
+
+	fmt.Printf("Top %d of %d issues, by allocated CPU hours lost:\n\n", shown, len(issues))
+	for i, issue := range issues[:shown] {
+		fmt.Printf("%d. [%s] %s, %.1f CPU hours\n", i+1, issue.severity(total), issue.Issue, issue.Lost)
+		fmt.Printf("   -> %s\n", issue.Advice)
+	}
+	return nil
+}
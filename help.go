@@ -0,0 +1,733 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// commandDoc is the built-in documentation of a subcommand
+type commandDoc struct {
+	Summary     string
+	Description string
+	Examples    []example
+	Demo        bool // the command reads traces and can be run by "nfu demo"
+}
+
+// example is a documented invocation; the arguments follow the command name
+type example struct {
+	Comment string
+	Args    string
+}
+
+// commandDocs holds the documentation shown by "nfu help <command>"
+var commandDocs = map[string]commandDoc{
+	"retries": {
+		Summary: "Summarize retried tasks and recommend initial resources",
+		Description: `Groups tasks by process and attempt, reports how much runtime was spent on
+attempts that were retried and recommends initial memory, time and CPU
+requests that would have covered the given percentage of tasks in one go.
+Failed attempts are counted by status, with statuses derived by
+--status-rules telling e.g. out-of-memory kills from timeouts.`,
+		Examples: []example{
+			{"Processes with retried tasks", "-i execution_trace.txt"},
+			{"All processes, sized to cover 95% of tasks", "-i execution_trace.txt --all --coverage 95"},
+			{"Failed attempts by derived status", "-i execution_trace.txt --status-rules status_rules.yaml"},
+		},
+		Demo: true,
+	},
+	"drift": {
+		Summary: "Detect processes whose runtime trends up or down over a run",
+		Description: `Regresses task runtime on start time per process. Processes whose
+runtime changes by more than --threshold percent over the run with a
+significant trend (|t| >= 2) are flagged, e.g. caused by growing caches or
+degrading storage. Processes whose tasks all started within --min-span are
+not flagged, as runtimes over a few seconds say nothing about a trend.`,
+		Examples: []example{
+			{"Default thresholds", "-i execution_trace.txt"},
+			{"Only strong trends in processes with many tasks", "-i execution_trace.txt --min-tasks 20 --threshold 50"},
+		},
+		Demo: true,
+	},
+	"interference": {
+		Summary: "Quantify how heavy processes sharing a node slow each other down",
+		Description: `Finds periods when tasks of several heavy processes ran on the same host and
+relates the co-located load of every task to its runtime relative to the
+process median. Requires the hostname and start columns.`,
+		Examples: []example{
+			{"Tasks with at least 4 CPUs count as heavy", "-i execution_trace.txt"},
+			{"Consider tasks with 8 or more CPUs, list 20 windows", "-i execution_trace.txt --min-cpus 8 --top 20"},
+		},
+		Demo: true,
+	},
+	"changes": {
+		Summary: "Explain how the runtime of a pipeline changed between two runs",
+		Description: `Compares two traces per process and splits the change of total runtime into
+the effect of a different number of tasks and the effect of faster or slower
+tasks. Renamed processes are matched by their short name. With --progress
+both runs are also aligned by relative progress (share of tasks completed)
+instead of wall time, so concurrency and throughput can be compared phase
+by phase even if the runs processed different numbers of samples.
+--normalize-by samples divides runtimes by the number of samples of each
+run, detected from the distinct tags per process unless given as
+samples=N or samples=OLD,NEW. --fail-above turns the report into a check
+that fails when the total runtime grew by more than the given percentage.`,
+		Examples: []example{
+			{"Compare two runs", "old_trace.txt new_trace.txt"},
+			{"Also compare both runs at every 10% of progress", "--progress 10 old_trace.txt new_trace.txt"},
+			{"Fail a CI job if the runtime grew by more than 10%", "--fail-above 10 old_trace.txt new_trace.txt"},
+			{"Compare runtime per sample of runs of 12 and 48 samples", "--normalize-by samples=12,48 old_trace.txt new_trace.txt"},
+		},
+		Demo: true,
+	},
+	"intervals": {
+		Summary: "Recommend splitting or merging genomic intervals",
+		Description: `Extracts genomic intervals from task tags, relates interval length to runtime
+and recommends which intervals to split or merge so that scatter tasks take
+similar time.`,
+		Examples: []example{
+			{"Intervals in tags such as chr1:1-1000000", "-i execution_trace.txt"},
+			{"Custom tag format", `-i execution_trace.txt --pattern 'region_(\S+)'`},
+		},
+	},
+	"imbalance": {
+		Summary: "Highlight fan-out processes dominated by straggler tasks",
+		Description: `Scores every process by the runtime of its longest task relative to the mean
+and lists the stragglers of processes above --threshold, together with how
+long the longest task kept the process running after all of its other tasks
+had ended, from their start and completion times.`,
+		Examples: []example{
+			{"Default thresholds", "-i execution_trace.txt"},
+			{"Flag processes whose longest task runs 3x the mean", "-i execution_trace.txt --threshold 3"},
+		},
+		Demo: true,
+	},
+	"phases": {
+		Summary: "Segment a run into phases of concurrently running processes",
+		Description: `Groups processes whose active periods overlap into phases and reports wall
+time, task count, runtime and CPU time of every phase.`,
+		Examples: []example{
+			{"Default segmentation", "-i execution_trace.txt"},
+			{"Require more overlap to join a phase", "-i execution_trace.txt --min-overlap 0.8"},
+		},
+		Demo: true,
+	},
+	"efficiency": {
+		Summary: "Report CPU efficiency per process",
+		Description: `Relates %cpu to the number of requested CPUs. Tasks with implausible %cpu
+values are excluded and counted as suspect. The share of every process in
+the CPU time (%cpu, or requested CPUs, times runtime) and memory-GB-hours
+(peak RSS times runtime) of the run shows where tuning pays off most.`,
+		Examples: []example{
+			{"CPU efficiency of all processes", "-i execution_trace.txt"},
+		},
+		Demo: true,
+	},
+	"cat": {
+		Summary: "Write all records with normalized values as JSON Lines or TSV",
+		Description: `Writes every parsed record with durations in milliseconds, memory and I/O in bytes
+and timestamps in RFC 3339, the canonical form for processing traces with
+other tools. Values missing from the trace are omitted, or left empty,
+while zeros are written. With --raw, the values of normalized fields are
+also written as found in the trace (realtime_raw, peak_rss_raw, ...), to
+audit how they were parsed.`,
+		Examples: []example{
+			{"One JSON object per task", "-i execution_trace.txt --json"},
+			{"Normalized next to raw values", "-i execution_trace.txt --raw"},
+			{"Tasks of one process with jq", `-i execution_trace.txt --json | jq 'select(.process == "ALIGN")'`},
+		},
+		Demo: true,
+	},
+	"profile": {
+		Summary: "Profile the type, fill rate and value range of every column",
+		Description: `Prints for every column of the trace its detected type (integer, number,
+percent, duration, size, timestamp or text), the share of non-missing
+values, the number of distinct values, minimum, maximum and examples.
+A quick data-quality check before deeper analysis.`,
+		Examples: []example{
+			{"Profile all columns", "-i execution_trace.txt"},
+		},
+		Demo: true,
+	},
+	"throughput": {
+		Summary: "Report samples completed per day and the turnaround of every sample",
+		Description: `Follows every sample through the run, from the first submission of one of
+its tasks to the completion of the last one, and reports the samples
+completed per day. Samples are the tags of processes with more than one
+tag. Steady-state throughput counts the samples completed after the first
+one, leaving out the time it takes the first sample to pass the pipeline.`,
+		Examples: []example{
+			{"Samples per day and turnaround per sample", "-i execution_trace.txt"},
+		},
+		Demo: true,
+	},
+	"sla": {
+		Summary: "Report the samples that exceeded a turnaround target",
+		Description: `Compares the turnaround of every completed sample, from the first submission
+of one of its tasks to the completion of the last one, with the target
+given by --sla. For every breach it names the process the sample spent
+the most time in, counting from the first submission to the last
+completion of the process' tasks of the sample, so queueing and retries
+are included.`,
+		Examples: []example{
+			{"Samples that took longer than a day", "-i execution_trace.txt --sla 24h"},
+		},
+		Demo: true,
+	},
+	"metrics": {
+		Summary: "Write run metrics in the GitLab CI metrics report format",
+		Description: `Writes the number of tasks and failed tasks, total runtime, wall time and
+tasks and runtime per process as "name value" lines (OpenMetrics text
+format). Uploaded as a metrics report artifact, GitLab shows how they
+changed compared with the target branch in merge requests:
+
+  artifacts:
+    reports:
+      metrics: metrics.txt`,
+		Examples: []example{
+			{"Metrics for a GitLab CI metrics report", "-i execution_trace.txt -o metrics.txt"},
+		},
+		Demo: true,
+	},
+	"check": {
+		Summary: "Check per-process performance budgets, for use as a CI gate",
+		Description: `Asserts limits on the processes matching a name or regular expression
+(matched against the full or the short process name): the 95th percentile
+of the task runtime, the peak RSS of any task and the share of failed
+attempts. Budgets are read from a YAML file given by --budgets:
+
+  STAR_ALIGN:
+    p95_time: 2h
+    peak_memory: 40 GB
+    failure_rate: 5%
+  "SAMTOOLS_.*": {p95_time: 10m, failure_rate: 0}
+
+or given by --max-time, --max-memory and --max-failure-rate, which
+override the file. --update-baseline records the current performance of
+every process as a budgets file given by --baseline, with the trace and
+version it was recorded from; later checks against the --baseline allow
+the --tolerance on top of every limit. Prints PASS, FAIL or SKIP per limit and fails if any
+limit is exceeded. With --junit the results are also written as JUnit
+XML, one test case per limit, for CI test report dashboards.`,
+		Examples: []example{
+			{"Gate a CI job on the budgets of budgets.yaml", "-i execution_trace.txt --budgets budgets.yaml"},
+			{"Record the current performance as the baseline", "-i execution_trace.txt --baseline baseline.yaml --update-baseline"},
+			{"Fail if any process is more than 10% worse than the baseline", "-i execution_trace.txt --baseline baseline.yaml --tolerance 10%"},
+			{"Gate a CI job on alignment time and memory", "-i execution_trace.txt --max-time STAR_ALIGN=2h --max-memory STAR_ALIGN=40GB"},
+			{"Also write a JUnit report", "-i execution_trace.txt --max-time 'SAMTOOLS_.*=10m' --junit budgets.xml"},
+		},
+		Demo: true,
+	},
+	"nodes": {
+		Summary: "Rank nodes by failures and slowdowns and suggest nodes to exclude",
+		Description: `Compares the tasks of every node across one or more runs with those of its
+peers: the failure rate against that of all other nodes and the runtime of
+successful tasks against the median runtime of the same process. Nodes
+with enough tasks whose failure rate or slowdown exceeds the thresholds
+are suggested for exclusion, as a list for the scheduler. The traces of
+the runs are given with -i, or as arguments, e.g. by a glob.`,
+		Examples: []example{
+			{"Nodes of the last runs, ranked", "-i run1/execution_trace.txt -i run2/execution_trace.txt -i run3/execution_trace.txt"},
+			{"Only flag nodes at least twice as slow", "-i execution_trace.txt --max-inflation 2"},
+		},
+		Demo: true,
+	},
+	"energy": {
+		Summary: "Estimate the energy of a run and the savings of starting it off-peak",
+		Description: `Estimates the power draw of every task from the CPU cores it used (%cpu,
+or the requested CPUs) and the energy of the whole run. Given electricity
+prices or carbon intensities by hour of the day with --rates, it also
+computes the cost of the run as it ran and if the same run had started at
+every full hour of the day, showing what shifting non-urgent runs to
+off-peak hours would save.`,
+		Examples: []example{
+			{"Energy of a run", "-i execution_trace.txt"},
+			{"Cost with a night tariff", "-i execution_trace.txt --rates 0-7=0.12,7-23=0.30,23-24=0.12 --unit EUR"},
+			{"Emissions with a carbon-intensity curve", "-i execution_trace.txt --rates 0-6=180,6-10=320,10-16=150,16-21=380,21-24=220 --unit gCO2"},
+		},
+		Demo: true,
+	},
+	"orphans": {
+		Summary: "Find task work directories no trace refers to",
+		Description: `Lists the task directories under the work directory whose hash appears in
+none of the given traces, with their size and the reclaimable total.
+Give the traces of every session whose results should be kept (e.g. for
+-resume); directories modified within --min-age are left out so that
+running sessions are spared. The Nextflow cache database is not read.
+Every line of the traces counts, also a truncated last one, and the
+filters of the reports do not apply. Traces are given with -i, or as
+arguments, e.g. by a glob.`,
+		Examples: []example{
+			{"Orphaned directories left by earlier sessions", "-w work execution_trace_*.txt"},
+			{"Only the paths, for other tools", "-w work --paths -i execution_trace.txt"},
+		},
+	},
+	"clean": {
+		Summary: "Remove orphaned task work directories, with dry run and trash",
+		Description: `Removes the task directories that 'nfu orphans' lists for the same traces,
+read in full without the filters of the reports. Without --apply nothing
+is removed and the directories that would be are listed. --trash moves
+them into a directory on the same file system instead, from where --undo
+with the deletion log moves them back. Every action is written to stdout
+as a tab-separated log line (time, action, path, size in bytes, trash
+path) and appended to the --log file. --rate limits the removals per
+second to spare shared file systems.`,
+		Examples: []example{
+			{"What would be removed", "-w work -i execution_trace.txt"},
+			{"Move orphans to a trash directory, keeping a log", "-w work --apply --trash work-trash --log clean.log execution_trace_*.txt"},
+			{"Undo the move", "--undo clean.log"},
+		},
+	},
+	"stagein": {
+		Summary: "Estimate the volume and time of staging the inputs of a samplesheet",
+		Description: `Reads a CSV or TSV samplesheet (such as that of nf-core pipelines), takes
+the values that look like paths or URLs as input files and determines
+their size: local files are stat'ed, HTTP(S) URLs and objects in public S3
+and GCS buckets asked with a HEAD request; objects in private buckets or
+Azure need credentials and are counted as unknown. Reports the total volume and the staging time at --bandwidth,
+per sample with --fan-out samples sharing the bandwidth.`,
+		Examples: []example{
+			{"Staging volume and time at 100 MB/s", "samplesheet.csv"},
+			{"Staging over a 1 GB/s link, 8 samples at a time", "--bandwidth 1GB --fan-out 8 samplesheet.csv"},
+		},
+	},
+	"calibrate": {
+		Summary: "Fit runtime and memory of every process to its input size",
+		Description: `Pairs the successful tasks of every process with the size of their inputs
+and fits runtime and peak memory linearly to it, in GiB. Input sizes are
+measured in the work directory (--work-dir), from the files Nextflow
+links into every task directory, or taken per sample from a samplesheet
+(--samplesheet), relating tasks to samples by tag.
+
+With --config, the fits are printed as dynamic directives of a Nextflow
+configuration, raised by --margin and by task.attempt; the expression of
+the input size has to be adapted to the input of each process.`,
+		Examples: []example{
+			{"Fits from the inputs staged in the work directory", "-i trace.txt -w work"},
+			{"Dynamic directives from the sizes of a samplesheet", "-i trace.txt --samplesheet samplesheet.csv --config"},
+		},
+	},
+	"snapshot": {
+		Summary: "Record the structure of a run and compare later runs against it",
+		Description: `"snapshot save" records the processes of a run with their number of
+tasks (retries counted once) and cache rate in a tab-separated file,
+sorted by process, to keep under version control next to a pipeline's
+test data. "snapshot compare" reports processes missing from a later
+run, new processes and task counts that changed by more than
+--tolerance, which catch structural changes such as dropped samples or
+duplicated channels that performance checks miss. Cache rates are only
+compared with --cache-tolerance. Fails if any process differs.`,
+		Examples: []example{
+			{"Record the snapshot of a test run", "save -i trace.txt tests/snapshot.tsv"},
+			{"Compare a new run with it", "compare -i trace.txt tests/snapshot.tsv"},
+		},
+	},
+	"expect": {
+		Summary: "Verify that every process ran the expected number of tasks",
+		Description: `Reads a YAML file of the number of tasks every process (name or regular
+expression) runs per sample, or per run when followed by "total":
+
+  FASTQC: 2            # one task per read file of paired samples
+  STAR_ALIGN: 1
+  MULTIQC: 1 total
+
+and compares the tasks that succeeded in the trace (retries counted once)
+with what --samples, or the number of samples of --samplesheet, calls
+for. Missing tasks reveal samples silently dropped by a filter or an
+empty channel; extra tasks, duplicated inputs. Fails if any process
+ran a different number of tasks.`,
+		Examples: []example{
+			{"Verify the task counts of a run of 24 samples", "-i trace.txt -e expected.yaml --samples 24"},
+			{"Count the samples of the samplesheet", "-i trace.txt -e expected.yaml --samplesheet samplesheet.csv"},
+		},
+	},
+	"missing": {
+		Summary: "Report the samples of a samplesheet that never reached the end of the pipeline",
+		Description: `Follows every sample of --samplesheet through the tasks tagged with its
+name and reports those without a successful task of the terminal
+process: by default the per-sample process whose tasks completed last,
+or the processes matching --terminal, all of which a sample must
+complete. For every missing sample, shows the last process it completed
+and a process whose task failed in every attempt, if any. Fails if any
+sample is missing.`,
+		Examples: []example{
+			{"Samples that did not make it through the pipeline", "-i trace.txt --samplesheet samplesheet.csv"},
+			{"Samples without quantification", "-i trace.txt --samplesheet samplesheet.csv --terminal SALMON_QUANT"},
+		},
+	},
+	"describe": {
+		Summary: "List the processes of a run with the tools and descriptions of their nf-core modules",
+		Description: `Looks up the meta.yml of the nf-core module every process runs, by process
+name (STAR_ALIGN runs star/align), and lists the processes with their
+tasks and runtime, the tools the module wraps and its description, for
+readers who do not know the pipeline. Metadata is read from the modules
+directory of the pipeline (--modules) and, with --fetch, from the
+nf-core/modules repository on GitHub.`,
+		Examples: []example{
+			{"Describe the processes from the modules of the pipeline", "-i trace.txt --modules rnaseq/modules/nf-core"},
+			{"Fetch the descriptions from nf-core/modules", "-i trace.txt --fetch"},
+		},
+	},
+	"tools": {
+		Summary: "Roll the resource usage of processes up to the tools they run",
+		Description: `Maps every process to the tool it runs and sums up tasks, runtime,
+CPU hours (allocated CPUs times runtime) and peak memory per tool, e.g.
+to weigh license costs or alternative tools. The tool of a process is
+the group it matches in --tools, a YAML file in the format of --groups
+(STAR: [STAR_ALIGN, STAR_GENOMEGENERATE]); else the first tool of its
+nf-core module, with --modules or --fetch as for "describe"; else, by
+nf-core convention, its name up to the first underscore.`,
+		Examples: []example{
+			{"Resource usage per tool by nf-core naming", "-i trace.txt"},
+			{"Tools from a mapping file and the modules of the pipeline", "-i trace.txt --tools tools.yaml --modules rnaseq/modules/nf-core"},
+		},
+		Demo: true,
+	},
+	"post": {
+		Summary: "Post a summary of a run and its samples as JSON to a URL",
+		Description: `Summarises the run (tasks, failures, wall time, runtime, CPU hours) and
+every sample (the tags of per-sample processes, as in "throughput":
+tasks, failures, submission, completion, turnaround, CPU hours and peak
+memory) and posts it as JSON to --url, e.g. the webhook of a LIMS.
+
+The payload can be shaped with --template, a Go text/template over the
+summary whose fields are those of the default payload in CamelCase
+(.Samples, .TurnaroundMs); "json" encodes a value, e.g. {{json .Sample}}.
+Headers given with -H have environment variables expanded, to keep
+tokens out of scripts. --dry-run prints the payload instead.`,
+		Examples: []example{
+			{"Print the default payload", "-i trace.txt --dry-run"},
+			{"Post to a LIMS with a token", "-i trace.txt --url https://lims.example.org/api/runs --template lims.tmpl -H 'Authorization: Bearer $LIMS_TOKEN'"},
+		},
+		Demo: true,
+	},
+	"runs": {
+		Summary: "List the runs of a trace that several runs appended to",
+		Description: `With trace.overwrite = false, resumed runs append to the same trace file,
+each starting with the header again. Lists these runs with their tasks,
+cached and failed tasks, start, end and wall time, the tasks of every
+run counted on their own.
+
+Other commands merge the runs by default, counting every task once;
+--runs last or --runs N restricts them to one run, and --runs split
+keeps the tasks of every run.`,
+		Examples: []example{
+			{"Runs appended to a trace", "-i trace.txt"},
+		},
+		Demo: true,
+	},
+	"pareto": {
+		Summary: "Rank processes by total runtime with their cumulative share",
+		Description: `Sorts processes by their total runtime, or CPU time or memory-GB-hours
+with --by, and shows the share and cumulative share of every process.
+The fewest processes that account for --threshold percent of the total
+are marked, answering which few processes to optimize first.`,
+		Examples: []example{
+			{"Processes accounting for 80% of the runtime", "-i trace.txt"},
+			{"Processes accounting for 90% of the CPU time", "-i trace.txt --by cpu --threshold 90"},
+		},
+		Demo: true,
+	},
+	"task": {
+		Summary: "Show the details of a single task with every attempt",
+		Description: `Finds a task by name, task ID or hash (also shortened, e.g. 3f/8a2c) and
+shows every attempt with its outcome, host, requested resources, start,
+runtime, %cpu and peak memory, and the delay between the end of an
+attempt and the submission of the next, clarifying the retry history of
+problem tasks. All attempts are shown regardless of --attempts.`,
+		Examples: []example{
+			{"Attempts of a task by name", "-i trace.txt 'NFCORE_RNASEQ:RNASEQ:ALIGN_STAR:STAR_ALIGN (WT_REP1)'"},
+			{"A task by hash", "-i trace.txt 3f/8a2c"},
+		},
+	},
+	"summary": {
+		Summary: "Summarize task counts, runtime, CPU and memory per process",
+		Description: `Aggregates the tasks of every process: the number of tasks, the total
+runtime with its share of the run, the mean, median and longest task
+runtime, the mean %cpu, the share of the CPU time and the peak RSS of the
+process. Processes are sorted by total runtime, so the process taking up
+most of the walltime comes first. With --output-format json, csv or tsv
+before the subcommand, every process is written as a record with
+durations in milliseconds and memory in bytes.`,
+		Examples: []example{
+			{"Per-process summary of a run", "-i trace.txt"},
+		},
+		Demo: true,
+	},
+	"workdir-of": {
+		Summary: "Resolve a task hash to its work directory",
+		Description: `Prints the directory under the work directory of the task with the given
+hash, shortened as in traces (e.g. 3f/8a2c41) or in full. With --files
+the .command.* files of the task are listed with their size, followed by
+the exit code recorded in .exitcode.`,
+		Examples: []example{
+			{"Work directory of a task", "-w work 3f/8a2c41"},
+			{"Its log files and exit code", "-w work --files 3f/8a2c41"},
+		},
+	},
+	"serve": {
+		Summary: "Receive live task events from Nextflow -with-weblog",
+		Description: `Listens for the events Nextflow posts when run with -with-weblog and
+writes every completed task as a record, in the same form as 'nfu cat'
+(tab-separated values, or JSON Lines with --output-format json before the
+subcommand), for dashboards following a run without tailing the trace.
+Run start, errors and completion are logged to stderr; --once exits when
+the run completes. --archive appends every event as posted to a JSON Lines
+file, a complete record of the run even without trace or report files.`,
+		Examples: []example{
+			{"Follow a run started with: nextflow run ... -with-weblog http://localhost:8000", "--weblog :8000"},
+			{"Until the run completes", "--weblog :8000 --once"},
+			{"Keeping every event of the run", "--weblog :8000 --archive events.jsonl"},
+		},
+	},
+	"compare": {
+		Summary: "Compare runtime, CPU and peak memory per process between two runs",
+		Description: `Joins two runs by process, or by process and tag with --by-tag, and shows
+the total and mean runtime, mean %cpu and peak RSS of both runs with the
+change in percent, e.g. after tuning resource requests. Processes whose
+mean runtime or peak RSS grew by more than --threshold percent are listed
+as regressed. 'nfu changes' explains the change of the total runtime of a
+pipeline instead.`,
+		Examples: []example{
+			{"Per-process changes between two runs", "old_trace.txt new_trace.txt"},
+			{"Task by task, regressions above 20%", "--by-tag --threshold 20 old_trace.txt new_trace.txt"},
+		},
+	},
+	"recommend": {
+		Summary: "Recommend cpus, memory and time directives per process",
+		Description: `Sizes the resource requests of every process from the usage of its
+successful tasks: the --percentile of %cpu (rounded up to whole CPUs), of
+peak RSS and of runtime, the latter two with --headroom percent on top as
+tasks exceeding them fail. Requested and recommended values are shown side
+by side; --config prints them as withName selectors to paste into
+nextflow.config. 'nfu retries' sizes initial requests from retried tasks
+instead.
+
+Every recommendation comes with its evidence: the number of tasks, the
+usage at the percentile, p95 and maximum and the attempts killed for
+exceeding their memory (exit code 137) or time (exit code 140, or the
+statuses OOM and WALLTIME derived by --status-rules). --evidence prints
+it below the table; --output-format json, csv or tsv writes one record per
+process and resource with the evidence as a nested field.
+
+Recommendations based on fewer than --min-tasks successful tasks, on more
+than --max-anomalous percent of anomalous tasks (implausible %cpu, clock
+skew, known incidents) or on a truncated or incomplete run are of low
+confidence: marked with * and listed with the reasons, or left out with
+--low-confidence suppress.
+
+--review walks through every recommended change with its evidence and asks
+whether to accept, modify or skip it; only the changes accepted, or
+modified, are printed as configuration. Prompts are written to stderr and
+answers read from stdin.
+
+--profile selects the defaults of an executor: slurm-hpc (25% headroom,
+time in 15 minute increments), awsbatch-spot (10%, 5 minutes), k8s (15%,
+5 minutes) or local (10%, 1 minute). A --headroom given takes precedence.
+
+Time is rounded up to --time-increment, e.g. 15m or 1h for schedulers
+that plan in coarse slots, and capped at the maximum time of the partition,
+or queue, tasks ran in as given by a --partitions file:
+
+  short: {max_time: 4h}
+  long: {max_time: 7d}
+  default: {max_time: 2d}
+
+The default entry applies to tasks of other queues or without a queue
+column. Capped recommendations are noted, as their tasks may need more.`,
+		Examples: []example{
+			{"Requested and recommended resources", "-i execution_trace.txt"},
+			{"With the usage every recommendation is based on", "-i execution_trace.txt --evidence"},
+			{"Configuration of recommendations based on 20 tasks or more", "-i execution_trace.txt --config --min-tasks 20 --low-confidence suppress"},
+			{"Review the changes one by one", "-i execution_trace.txt --review"},
+			{"Defaults for a Slurm cluster", "-i execution_trace.txt --profile slurm-hpc --config"},
+			{"Time in hours, within the limits of the partitions", "-i execution_trace.txt --time-increment 1h --partitions partitions.yaml --config"},
+			{"Sized for 95% of tasks with 10% headroom, as configuration", "-i execution_trace.txt --percentile 95 --headroom 10 --config"},
+		},
+		Demo: true,
+	},
+	"rocrate": {
+		Summary: "Export a run with its resource usage as an RO-Crate",
+		Description: `Writes an RO-Crate (Process Run Crate profile) into the directory given
+with -d: the trace, the resource usage per process as written by
+'nfu --output-format tsv summary' and ro-crate-metadata.json describing
+the pipeline run that produced the trace, the nfu run that summarized it
+and the tasks, wall time, runtime and CPU time of the run, so resource
+usage can be archived with the provenance of the workflow. The summary
+reflects the read flags given (e.g. --status), the trace is copied as is,
+as tab- or comma-separated values and possibly gzip-compressed, and named
+trace_<name> if its name is that of another file of the crate.`,
+		Examples: []example{
+			{"Crate of a run", "-i execution_trace.txt -d crate"},
+		},
+	},
+	"concurrency": {
+		Summary: "Report the makespan, queue waits and parallelism of a run",
+		Description: `Reports the wall time of the run from the first submission to the last
+completion, how long tasks waited in the queue from submission to start and
+how many tasks were running and queued at the same time, at most and on
+average over the run. With --timeline the counts are also averaged over
+periods of the given length; --output-format json, csv or tsv writes this
+timeline as records.`,
+		Examples: []example{
+			{"Makespan, queue waits and parallelism", "-i execution_trace.txt"},
+			{"Timeline in periods of 10 minutes", "-i execution_trace.txt --timeline 10m"},
+		},
+		Demo: true,
+	},
+	"cost": {
+		Summary: "Estimate the cost of a run per process from CPU and memory hours",
+		Description: `Charges every task its runtime times its allocated CPUs at the price of a CPU
+hour and, optionally, its runtime times its requested memory at the price
+of a GB hour, and reports the cost per process and in total. Prices may
+differ by queue: a --pricing file maps the values of the queue column to
+prices, with prices given as flags (or its default entry) for other tasks.
+--profile slurm-hpc, awsbatch-spot or k8s charges indicative prices of the
+executor unless prices are given.`,
+		Examples: []example{
+			{"CPU and memory pricing", "-i execution_trace.txt --price-cpu-hour 0.04 --price-gb-hour 0.005 --unit USD"},
+			{"Prices per queue", "-i execution_trace.txt --pricing pricing.yaml"},
+			{"Indicative prices of AWS Batch spot instances", "-i execution_trace.txt --profile awsbatch-spot --unit USD"},
+		},
+	},
+	"health": {
+		Summary: "Triage the worst issues of a run with advice on each",
+		Description: `Looks for tasks killed for exceeding their memory (exit code 137, or the
+status OOM derived by --status-rules), processes retrying more than 10% of
+their tasks, multi-CPU processes using less than half of their CPUs and
+median queue waits over 10 minutes, and reports the worst of them with
+one line of advice each. Issues are ranked by the allocated CPU hours they
+cost, rated HIGH above 10% and MEDIUM above 2% of the CPU hours of the
+run; for queue waits these are the CPU hours of the waiting tasks.`,
+		Examples: []example{
+			{"The 5 worst issues", "-i execution_trace.txt"},
+			{"All issues", "-i execution_trace.txt --top 100"},
+		},
+		Demo: true,
+	},
+	"selfcheck": {
+		Summary: "Verify the parsers against the embedded corpus of trace formats",
+		Description: `Parses the embedded corpus of traces written by different Nextflow versions
+and configurations and reports PASS or FAIL per format feature, along with
+the parsing of durations and sizes and the round trip of a baseline.`,
+		Examples: []example{
+			{"Run all checks and list every failure", "-v"},
+		},
+	},
+	"version": {
+		Summary: "Print version, commit, build date and supported trace formats",
+		Examples: []example{
+			{"Build information as JSON", "--json"},
+		},
+	},
+	"self-update": {
+		Summary: "Update the binary to the latest GitHub release",
+		Description: `Downloads the release archive for the current platform, verifies its SHA-256
+checksum against the published checksums file and replaces the running
+binary.`,
+		Examples: []example{
+			{"Check for a newer release without installing it", "--check"},
+		},
+	},
+	"release": {
+		Summary: "Generate the release configuration or build release archives",
+		Description: `"config" writes a GoReleaser configuration, "build" cross-compiles
+reproducible binaries with embedded version information and packs them into
+archives with a checksums file.`,
+		Examples: []example{
+			{"Write the GoReleaser configuration", "config -o .goreleaser.yaml"},
+			{"Build Linux archives locally", "build --version 0.3.0 --targets linux/amd64,linux/arm64"},
+		},
+	},
+	"help": {
+		Summary: "Show documentation and examples of a command",
+		Examples: []example{
+			{"Documentation of the drift command", "drift"},
+		},
+	},
+	"demo": {
+		Summary: "Run a report against the embedded demo trace",
+		Description: `Runs reports against embedded traces of two runs of an nf-core/rnaseq-like
+pipeline, so reports can be explored without a trace at hand. Flags after
+the command name are passed on to the report. "tutorial" walks through the
+main reports with explanations, "export" writes the demo traces to disk.`,
+		Examples: []example{
+			{"Retry report of the demo run", "retries"},
+			{"Phases of the demo run", "phases --min-overlap 0.8"},
+			{"Guided tour through the reports", "tutorial"},
+			{"Write the demo traces to the current directory", "export"},
+		},
+	},
+}
+
+// sortedCommandNames returns the names of all commands in sorted order
+func sortedCommandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printCommandList lists all commands with their summaries
+func printCommandList() {
+	fmt.Println("Usage: nfu [global flags] <command> [flags]")
+	fmt.Println("       nfu -i execution_trace.txt   (total duration of all tasks)")
+	fmt.Println("       nfu trace_1.txt trace_2.csv.gz")
+	fmt.Println()
+	fmt.Println("Commands:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, name := range sortedCommandNames() {
+		fmt.Fprintf(w, "  %s\t%s\n", name, commandDocs[name].Summary)
+	}
+	w.Flush()
+	fmt.Println()
+	fmt.Println("Run 'nfu help <command>' for details and examples.")
+}
+
+// printCommandHelp prints the documentation of a command followed by its flags
+func printCommandHelp(name string) error {
+	run, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command '%s'", name)
+	}
+	doc := commandDocs[name]
+
+	fmt.Printf("nfu %s - %s\n", name, doc.Summary)
+	if doc.Description != "" {
+		fmt.Printf("\n%s\n", doc.Description)
+	}
+	if len(doc.Examples) > 0 {
+		fmt.Println("\nExamples:")
+		for _, ex := range doc.Examples {
+			fmt.Printf("  # %s\n  nfu %s %s\n", ex.Comment, name, ex.Args)
+		}
+	}
+	if doc.Demo {
+		fmt.Printf("\nTry it on the embedded demo trace:\n  nfu demo %s\n", name)
+	}
+	fmt.Println()
+
+	// Let the command print its flags to stdout, so that the help can be
+	// paged; parsing -h exits with status 0 once the usage is printed
+	if name == "help" || name == "demo" {
+		return nil
+	}
+	usageOutput = os.Stdout
+	return run([]string{"-h"})
+}
+
+// runHelp implements the "help" subcommand
+func runHelp(args []string) error {
+	fs := newFlagSet("help")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		printCommandList()
+		return nil
+	}
+	return printCommandHelp(strings.TrimSpace(fs.Arg(0)))
+}
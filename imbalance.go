@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// imbalanceStats describes how evenly work is spread over the tasks of a process
+type imbalanceStats struct {
+	Process    string
+	Tasks      []TraceRecord // sorted by descending runtime
+	Mean       time.Duration
+	Imbalance  float64       // longest task runtime divided by the mean
+	Stragglers []TraceRecord // tasks running longer than threshold × mean
+}
+
+// collectImbalanceStats computes the imbalance score of every process with at
+// least minTasks tasks
+func collectImbalanceStats(records []TraceRecord, minTasks int, threshold float64) []imbalanceStats {
+	var order []string
+	byProcess := make(map[string][]TraceRecord)
+	for _, rec := range records {
+		if rec.Runtime() <= 0 {
+			excludeTask(rec, "no runtime")
+			continue
+		}
+		if _, ok := byProcess[rec.Process]; !ok {
+			order = append(order, rec.Process)
+		}
+		byProcess[rec.Process] = append(byProcess[rec.Process], rec)
+	}
+
+	var result []imbalanceStats
+	for _, process := range order {
+		tasks := byProcess[process]
+		if len(tasks) < minTasks {
+			continue
+		}
+		sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Runtime() > tasks[j].Runtime() })
+
+		var total time.Duration
+		for _, rec := range tasks {
+			total += rec.Runtime()
+		}
+		stats := imbalanceStats{
+			Process: process,
+			Tasks:   tasks,
+			Mean:    total / time.Duration(len(tasks)),
+		}
+		stats.Imbalance = tasks[0].Runtime().Seconds() / stats.Mean.Seconds()
+		for _, rec := range tasks {
+			if rec.Runtime().Seconds() > threshold*stats.Mean.Seconds() {
+				stats.Stragglers = append(stats.Stragglers, rec)
+			}
+		}
+		result = append(result, stats)
+	}
+	return result
+}
+
+// heldUp returns how long the longest of the tasks of a process kept the
+// process running after the others had ended, from their actual start and
+// completion: zero if another task ended later. It is false if a task has no
+// start time.
+func heldUp(tasks []TraceRecord) (time.Duration, bool) {
+	var others time.Time
+	for _, rec := range tasks {
+		if rec.Start.IsZero() {
+			return 0, false
+		}
+	}
+	for _, rec := range tasks[1:] {
+		others = maxTime(others, rec.End())
+	}
+	return max(tasks[0].End().Sub(others), 0), true
+}
+
+// runImbalance implements the "imbalance" subcommand, highlighting fan-out
+// processes whose duration is determined by a few straggler tasks
+func runImbalance(args []string) error {
+	fs := newFlagSet("imbalance")
+	input := inputFlags(fs)
+	minTasks := fs.Int("min-tasks", 3, "Minimum number of tasks required to analyze a process")
+	threshold := fs.Float64("threshold", 2, "Imbalance score (max/mean runtime) from which a process is flagged")
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	stats := collectImbalanceStats(trace.Records, *minTasks, *threshold)
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Imbalance > stats[j].Imbalance })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tMEAN\tMAX\tIMBALANCE\tFLAG")
+	var flagged []imbalanceStats
+	for _, s := range stats {
+		mark := ""
+		if s.Imbalance >= *threshold {
+			mark = "STRAGGLER"
+			flagged = append(flagged, s)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%.2f\t%s\n", s.Process, len(s.Tasks),
+			FormatDuration(s.Mean), FormatDuration(s.Tasks[0].Runtime()), s.Imbalance, mark)
+	}
+	w.Flush()
+
+	for _, s := range flagged {
+		fmt.Printf("\nStragglers of %s:\n", s.Process)
+		for _, rec := range s.Stragglers {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%.2fx mean\n", rec.Name, rec.Hash,
+				FormatDuration(rec.Runtime()), rec.Runtime().Seconds()/s.Mean.Seconds())
+		}
+		w.Flush()
+
+		if len(s.Tasks) < 2 {
+			continue
+		}
+		if d, ok := heldUp(s.Tasks); !ok {
+			fmt.Println("  Time the longest task held up the process: unknown (no start times)")
+		} else if d > 0 {
+			fmt.Printf("  The longest task ended %s after all other tasks of the process\n", FormatDuration(d))
+		} else {
+			fmt.Println("  The longest task did not hold up the process, other tasks ended later")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// incident is a known infrastructure outage or maintenance window
+type incident struct {
+	Start, End  time.Time
+	Description string
+}
+
+// loadIncidents reads a CSV file of incidents, one per line as
+// start,end[,description], e.g.
+//
+//	start,end,description
+//	2024-03-01 02:00,2024-03-01 04:30,storage maintenance
+//
+// The header line is optional.
+func loadIncidents(filePath string) ([]incident, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening incidents file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	var incidents []incident
+	for lineNum := 1; ; lineNum++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading incidents file: %w", err)
+		}
+		if lineNum == 1 && strings.EqualFold(strings.TrimSpace(fields[0]), "start") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("incidents file line %d: expected start,end[,description]", lineNum)
+		}
+
+		var inc incident
+		if inc.Start, err = resolveTimeBound(strings.TrimSpace(fields[0]), time.Time{}); err != nil {
+			return nil, fmt.Errorf("incidents file line %d: %w", lineNum, err)
+		}
+		if inc.End, err = resolveTimeBound(strings.TrimSpace(fields[1]), time.Time{}); err != nil {
+			return nil, fmt.Errorf("incidents file line %d: %w", lineNum, err)
+		}
+		if inc.End.Before(inc.Start) {
+			return nil, fmt.Errorf("incidents file line %d: incident ends before it starts", lineNum)
+		}
+		if len(fields) > 2 {
+			inc.Description = strings.TrimSpace(strings.Join(fields[2:], ","))
+		}
+		if inc.Description == "" {
+			inc.Description = "incident " + inc.Start.Format("2006-01-02 15:04")
+		}
+		incidents = append(incidents, inc)
+	}
+	return incidents, nil
+}
+
+// applyIncidents finds the tasks that were submitted or running during one of
+// the incidents in the incidents file and handles them according to policy:
+// "drop" leaves them out, "flag" keeps them with the incident recorded
+func applyIncidents(records []TraceRecord, filePath, policy string) ([]TraceRecord, int, error) {
+	if filePath == "" {
+		return records, 0, nil
+	}
+	if policy != "drop" && policy != "flag" {
+		return nil, 0, fmt.Errorf("unknown incident policy '%s' (use drop or flag)", policy)
+	}
+	incidents, err := loadIncidents(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	affected := 0
+	result := records[:0]
+	for _, rec := range records {
+		from := rec.Submit
+		if from.IsZero() {
+			from = rec.Start
+		}
+		var hit []string
+		for _, inc := range incidents {
+			if !from.IsZero() && !from.After(inc.End) && !rec.End().Before(inc.Start) {
+				hit = append(hit, inc.Description)
+			}
+		}
+		if len(hit) == 0 {
+			result = append(result, rec)
+			continue
+		}
+
+		affected++
+		if policy == "drop" {
+			continue
+		}
+		rec.Incident = strings.Join(hit, "; ")
+		result = append(result, rec)
+	}
+	return result, affected, nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// overlapWindow is a period when several heavy processes ran on one node
+type overlapWindow struct {
+	Host      string
+	Start     time.Time
+	End       time.Time
+	Processes []string
+}
+
+// interferenceStats relates co-located load to runtime inflation of a process
+type interferenceStats struct {
+	Process     string
+	Tasks       int
+	Isolated    []float64 // runtime inflation of tasks without co-located heavy load
+	Shared      []float64 // runtime inflation of tasks sharing the node
+	CoLoad      []float64
+	Inflation   []float64
+	MeanCoLoad  float64
+	Correlation float64
+}
+
+// overlap returns the length of the intersection of two time intervals
+func overlap(aStart, aEnd, bStart, bEnd time.Time) time.Duration {
+	start, end := aStart, aEnd
+	if bStart.After(start) {
+		start = bStart
+	}
+	if bEnd.Before(end) {
+		end = bEnd
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// findOverlapWindows sweeps the heavy tasks of every host and returns the
+// periods during which at least two different processes were running
+func findOverlapWindows(byHost map[string][]TraceRecord) []overlapWindow {
+	type event struct {
+		at      time.Time
+		delta   int
+		process string
+	}
+
+	var windows []overlapWindow
+	for host, tasks := range byHost {
+		var events []event
+		for _, rec := range tasks {
+			events = append(events, event{rec.Start, 1, rec.Process}, event{rec.End(), -1, rec.Process})
+		}
+		// Process ends before starts at the same instant so that back-to-back
+		// tasks are not reported as overlapping
+		sort.Slice(events, func(i, j int) bool {
+			if events[i].at.Equal(events[j].at) {
+				return events[i].delta < events[j].delta
+			}
+			return events[i].at.Before(events[j].at)
+		})
+
+		running := make(map[string]int)
+		var current *overlapWindow
+		for _, ev := range events {
+			running[ev.process] += ev.delta
+			if running[ev.process] == 0 {
+				delete(running, ev.process)
+			}
+
+			if len(running) >= 2 {
+				if current == nil {
+					current = &overlapWindow{Host: host, Start: ev.at}
+				}
+				for process := range running {
+					if !containsString(current.Processes, process) {
+						current.Processes = append(current.Processes, process)
+					}
+				}
+			} else if current != nil {
+				current.End = ev.at
+				if current.End.After(current.Start) {
+					sort.Strings(current.Processes)
+					windows = append(windows, *current)
+				}
+				current = nil
+			}
+		}
+	}
+	return windows
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// collectInterferenceStats computes for every task the average number of
+// other heavy tasks running on the same node and relates it to the task's
+// runtime relative to the process median. Heavy tasks are those requesting
+// at least minCPUs CPUs; they are told apart by their index in records, as
+// traces without task_id and hash columns do not identify them.
+func collectInterferenceStats(records []TraceRecord, minCPUs int, sharedLoad float64) []*interferenceStats {
+	medians := make(map[string]float64)
+	runtimes := make(map[string][]float64)
+	heavy := make(map[string][]int)
+	for i, rec := range records {
+		runtimes[rec.Process] = append(runtimes[rec.Process], rec.Runtime().Seconds())
+		if rec.CPUs >= minCPUs {
+			heavy[rec.Hostname] = append(heavy[rec.Hostname], i)
+		}
+	}
+	for process, values := range runtimes {
+		medians[process] = median(values)
+	}
+
+	var order []*interferenceStats
+	byProcess := make(map[string]*interferenceStats)
+	for i, rec := range records {
+		if medians[rec.Process] <= 0 {
+			continue
+		}
+
+		var shared time.Duration
+		for _, j := range heavy[rec.Hostname] {
+			if j == i {
+				continue
+			}
+			other := records[j]
+			shared += overlap(rec.Start, rec.End(), other.Start, other.End())
+		}
+		coLoad := shared.Seconds() / rec.Runtime().Seconds()
+		inflation := rec.Runtime().Seconds() / medians[rec.Process]
+
+		stats, ok := byProcess[rec.Process]
+		if !ok {
+			stats = &interferenceStats{Process: rec.Process}
+			byProcess[rec.Process] = stats
+			order = append(order, stats)
+		}
+		stats.Tasks++
+		stats.CoLoad = append(stats.CoLoad, coLoad)
+		stats.Inflation = append(stats.Inflation, inflation)
+		if coLoad >= sharedLoad {
+			stats.Shared = append(stats.Shared, inflation)
+		} else {
+			stats.Isolated = append(stats.Isolated, inflation)
+		}
+	}
+
+	for _, stats := range order {
+		stats.MeanCoLoad = mean(stats.CoLoad)
+		stats.Correlation = correlation(stats.CoLoad, stats.Inflation)
+	}
+	return order
+}
+
+// runInterference implements the "interference" subcommand, quantifying how
+// heavy processes sharing a node slow each other down
+func runInterference(args []string) error {
+	fs := newFlagSet("interference")
+	input := inputFlags(fs)
+	minCPUs := fs.Int("min-cpus", 4, "Minimum number of requested CPUs for a task to count as heavy")
+	sharedLoad := fs.Float64("shared-load", 0.5, "Average number of co-located heavy tasks from which a task counts as sharing its node")
+	top := fs.Int("top", 10, "Number of longest overlap windows to list")
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	if !trace.HasColumn("hostname") {
+		return fmt.Errorf("hostname column not found in input file")
+	}
+	if !trace.HasColumn("start") {
+		return fmt.Errorf("start column not found in input file")
+	}
+
+	var records []TraceRecord
+	heavy := make(map[string][]TraceRecord)
+	for _, rec := range trace.Records {
+		switch {
+		case rec.Hostname == "":
+			excludeTask(rec, "no hostname")
+			continue
+		case rec.Start.IsZero():
+			excludeTask(rec, "no start time")
+			continue
+		case rec.Runtime() <= 0:
+			excludeTask(rec, "no runtime")
+			continue
+		}
+		records = append(records, rec)
+		if rec.CPUs >= *minCPUs {
+			heavy[rec.Hostname] = append(heavy[rec.Hostname], rec)
+		}
+	}
+
+	windows := findOverlapWindows(heavy)
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].End.Sub(windows[i].Start) > windows[j].End.Sub(windows[j].Start)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Printf("Overlap windows of heavy processes (%d found):\n", len(windows))
+	fmt.Fprintln(w, "HOST\tSTART\tDURATION\tPROCESSES")
+	for i, win := range windows {
+		if i >= *top {
+			break
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", win.Host, win.Start.Format(time.DateTime),
+			FormatDuration(win.End.Sub(win.Start)), strings.Join(win.Processes, ", "))
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Println("Runtime inflation relative to the process median:")
+	fmt.Fprintln(w, "PROCESS\tTASKS\tMEAN CO-LOAD\tISOLATED\tSHARED\tEFFECT\tCORRELATION")
+	for _, stats := range collectInterferenceStats(records, *minCPUs, *sharedLoad) {
+		effect := "-"
+		if len(stats.Isolated) > 0 && len(stats.Shared) > 0 {
+			effect = FormatSignedPercent(100 * (mean(stats.Shared)/mean(stats.Isolated) - 1))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t%s\t%s\t%s\t%s\n",
+			stats.Process, stats.Tasks, stats.MeanCoLoad,
+			formatRatio(stats.Isolated), formatRatio(stats.Shared), effect,
+			formatFloat(stats.Correlation, 2))
+	}
+	return w.Flush()
+}
+
+// formatRatio renders the mean of ratios with the number of observations
+func formatRatio(values []float64) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2fx (n=%d)", mean(values), len(values))
+}
+
+// formatFloat renders a float with the given precision, or "-" for NaN
+func formatFloat(value float64, precision int) string {
+	if math.IsNaN(value) {
+		return "-"
+	}
+	return fmt.Sprintf("%.*f", precision, value)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultIntervalPattern extracts chromosome names with optional coordinates
+// (e.g. "chr1" or "chr2:1000000-2000000") from task tags
+const defaultIntervalPattern = `(?i)(chr[0-9A-Za-z_]+(?::[0-9,]+-[0-9,]+)?)`
+
+// intervalCoordinates matches the ":start-end" part of an interval
+var intervalCoordinates = regexp.MustCompile(`:([0-9,]+)-([0-9,]+)$`)
+
+// intervalStats aggregates the tasks of one process operating on one interval
+type intervalStats struct {
+	Interval string
+	Tasks    int
+	Runtime  time.Duration // total runtime across all tasks (e.g. samples)
+	Length   int64         // interval length in bases, 0 when unknown
+}
+
+// MeanRuntime returns the average runtime per task of the interval
+func (s *intervalStats) MeanRuntime() time.Duration {
+	return s.Runtime / time.Duration(s.Tasks)
+}
+
+// intervalLength returns the number of bases covered by an interval, or 0
+// when the interval has no coordinates
+func intervalLength(interval string) int64 {
+	m := intervalCoordinates.FindStringSubmatch(interval)
+	if m == nil {
+		return 0
+	}
+	start, err1 := strconv.ParseInt(strings.ReplaceAll(m[1], ",", ""), 10, 64)
+	end, err2 := strconv.ParseInt(strings.ReplaceAll(m[2], ",", ""), 10, 64)
+	if err1 != nil || err2 != nil || end < start {
+		return 0
+	}
+	return end - start + 1
+}
+
+// chromosomeLess orders interval names naturally: numbered chromosomes by
+// number first, then the remaining ones alphabetically
+func chromosomeLess(a, b string) bool {
+	key := func(s string) (int, string) {
+		name := strings.TrimPrefix(strings.ToLower(s), "chr")
+		digits := len(name) - len(strings.TrimLeft(name, "0123456789"))
+		if digits == 0 {
+			return math.MaxInt, name
+		}
+		n, _ := strconv.Atoi(name[:digits])
+		return n, name[digits:]
+	}
+	na, ra := key(a)
+	nb, rb := key(b)
+	if na != nb {
+		return na < nb
+	}
+	return ra < rb
+}
+
+// collectIntervalStats groups tasks by process and interval, keeping only
+// processes that were scattered over at least two intervals
+func collectIntervalStats(records []TraceRecord, pattern *regexp.Regexp) ([]string, map[string][]*intervalStats) {
+	var order []string
+	byProcess := make(map[string]map[string]*intervalStats)
+	for _, rec := range records {
+		if rec.Runtime() <= 0 {
+			excludeTask(rec, "no runtime")
+			continue
+		}
+		m := pattern.FindStringSubmatch(rec.Tag)
+		if m == nil {
+			excludeTask(rec, "tag does not match the interval pattern")
+			continue
+		}
+		interval := m[0]
+		if len(m) > 1 {
+			interval = m[1]
+		}
+
+		intervals, ok := byProcess[rec.Process]
+		if !ok {
+			intervals = make(map[string]*intervalStats)
+			byProcess[rec.Process] = intervals
+			order = append(order, rec.Process)
+		}
+		stats, ok := intervals[interval]
+		if !ok {
+			stats = &intervalStats{Interval: interval, Length: intervalLength(interval)}
+			intervals[interval] = stats
+		}
+		stats.Tasks++
+		stats.Runtime += rec.Runtime()
+	}
+
+	var processes []string
+	result := make(map[string][]*intervalStats)
+	for _, process := range order {
+		if len(byProcess[process]) < 2 {
+			continue
+		}
+		var list []*intervalStats
+		for _, stats := range byProcess[process] {
+			list = append(list, stats)
+		}
+		sort.Slice(list, func(i, j int) bool { return chromosomeLess(list[i].Interval, list[j].Interval) })
+		processes = append(processes, process)
+		result[process] = list
+	}
+	return processes, result
+}
+
+// runIntervals implements the "intervals" subcommand, highlighting imbalance
+// across the shards of scatter-gather processes
+func runIntervals(args []string) error {
+	fs := newFlagSet("intervals")
+	input := inputFlags(fs)
+	patternStr := fs.String("pattern", defaultIntervalPattern, "Regular expression extracting the interval from the task tag (first capture group)")
+	fs.Parse(args)
+
+	pattern, err := regexp.Compile(*patternStr)
+	if err != nil {
+		return fmt.Errorf("invalid interval pattern: %w", err)
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	processes, byProcess := collectIntervalStats(trace.Records, pattern)
+	if len(processes) == 0 {
+		fmt.Println("No scattered processes found (no task tags matched the interval pattern)")
+		return nil
+	}
+
+	for _, process := range processes {
+		intervals := byProcess[process]
+
+		runtimes := make([]float64, len(intervals))
+		for i, stats := range intervals {
+			runtimes[i] = stats.MeanRuntime().Seconds()
+		}
+		med, avg := median(runtimes), mean(runtimes)
+		if med <= 0 {
+			slog.Warn("skipping process with a median interval runtime of zero", "process", process)
+			continue
+		}
+		var maxRuntime, sumSquares float64
+		for _, r := range runtimes {
+			maxRuntime = math.Max(maxRuntime, r)
+			sumSquares += (r - avg) * (r - avg)
+		}
+		cv := math.Sqrt(sumSquares/float64(len(runtimes))) / avg
+
+		fmt.Printf("Process: %s (%d intervals, imbalance %.2fx max/mean, CV %.2f)\n",
+			process, len(intervals), maxRuntime/avg, cv)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  INTERVAL\tTASKS\tMEAN RUNTIME\tVS MEDIAN\tRUNTIME/Mb")
+		var split, merge []string
+		for i, stats := range intervals {
+			perMb := "-"
+			if stats.Length > 0 {
+				perMb = FormatDuration(time.Duration(float64(stats.MeanRuntime()) / (float64(stats.Length) / 1e6)))
+			}
+			fmt.Fprintf(w, "  %s\t%d\t%s\t%.2fx\t%s\n", stats.Interval, stats.Tasks,
+				FormatDuration(stats.MeanRuntime()), runtimes[i]/med, perMb)
+
+			// Shards much slower than the median determine the phase duration,
+			// while very fast ones mostly add scheduling overhead
+			if parts := math.Round(runtimes[i] / med); parts >= 2 {
+				split = append(split, fmt.Sprintf("%s (~%d parts)", stats.Interval, int(parts)))
+			} else if runtimes[i] < med/4 {
+				merge = append(merge, stats.Interval)
+			}
+		}
+		w.Flush()
+
+		if len(split) > 0 {
+			fmt.Printf("  Recommendation: split %s\n", strings.Join(split, ", "))
+		}
+		if len(merge) > 1 {
+			fmt.Printf("  Recommendation: merge %s\n", strings.Join(merge, ", "))
+		}
+		fmt.Println()
+	}
+	return nil
+}
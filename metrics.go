@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// runMetrics implements the "metrics" subcommand, writing key figures of a
+// run in the metrics report format of GitLab CI (one "name value" per line,
+// as in the OpenMetrics text format), which merge requests compare with the
+// target branch
+func runMetrics(args []string) error {
+	fs := newFlagSet("metrics")
+	input := inputFlags(fs)
+	output := fs.String("o", "", "Write the metrics to this file instead of stdout (e.g. metrics.txt)")
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("error writing metrics: %w", err)
+		}
+		defer f.Close()
+		out = bufio.NewWriter(f)
+	}
+
+	seconds := func(d time.Duration) string { return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) }
+	var failed int
+	var first, last time.Time
+	for _, rec := range trace.Records {
+		if rec.Status != "" && !isSuccess(rec.Status) {
+			failed++
+		}
+		if !rec.Submit.IsZero() && (first.IsZero() || rec.Submit.Before(first)) {
+			first = rec.Submit
+		}
+		if !rec.Start.IsZero() && rec.End().After(last) {
+			last = rec.End()
+		}
+	}
+	stats := aggregateByProcess(trace.Records)
+	var runtime time.Duration
+	for _, s := range stats {
+		runtime, _ = addDurations(runtime, s.Runtime)
+	}
+
+	fmt.Fprintf(out, "nfu_tasks %d\n", len(trace.Records))
+	fmt.Fprintf(out, "nfu_tasks_failed %d\n", failed)
+	fmt.Fprintf(out, "nfu_runtime_seconds %s\n", seconds(runtime))
+	if !first.IsZero() && last.After(first) {
+		fmt.Fprintf(out, "nfu_wall_time_seconds %s\n", seconds(last.Sub(first)))
+	}
+	for _, s := range stats {
+		label := strconv.Quote(s.Process)
+		fmt.Fprintf(out, "nfu_process_tasks{process=%s} %d\n", label, s.Tasks)
+		fmt.Fprintf(out, "nfu_process_runtime_seconds{process=%s} %s\n", label, seconds(s.Runtime))
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("error writing metrics: %w", err)
+	}
+	return nil
+}
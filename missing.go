@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// terminalProcess guesses the last per-sample process of a pipeline: of the
+// processes run for more than one tag, the one whose tasks completed
+// latest, by median
+func terminalProcess(records []TraceRecord) string {
+	completions := make(map[string][]float64)
+	for _, rec := range records {
+		if !rec.Complete.IsZero() {
+			completions[rec.Process] = append(completions[rec.Process], float64(rec.Complete.Unix()))
+		}
+	}
+	terminal, latest := "", 0.0
+	for process, tags := range tagsByProcess(records) {
+		if len(tags) < 2 || len(completions[process]) == 0 {
+			continue
+		}
+		if m := median(completions[process]); terminal == "" || m > latest || (m == latest && process < terminal) {
+			terminal, latest = process, m
+		}
+	}
+	return terminal
+}
+
+// sampleProgress is how far one sample got through the pipeline
+type sampleProgress struct {
+	Sample        string
+	Reached       bool   // completed every terminal process
+	LastCompleted string // process of the last successful task
+	CompletedAt   time.Time
+	Failed        string // process of a task that failed in every attempt
+}
+
+// traceSampleProgress follows the samples of a samplesheet through the
+// tasks tagged with their name
+func traceSampleProgress(records []TraceRecord, samples []string, terminal *regexp.Regexp) []*sampleProgress {
+	bySample := make(map[string]*sampleProgress)
+	progress := make([]*sampleProgress, 0, len(samples))
+	for _, s := range samples {
+		if _, ok := bySample[s]; !ok {
+			bySample[s] = &sampleProgress{Sample: s}
+			progress = append(progress, bySample[s])
+		}
+	}
+
+	terminals := make(map[string]bool)
+	reached := make(map[string]map[string]bool)
+	succeeded := make(map[string]bool) // by task name, attempts of a task share it
+	for _, rec := range records {
+		if terminal.MatchString(rec.Process) || terminal.MatchString(shortProcessName(rec.Process)) {
+			terminals[rec.Process] = true
+		}
+		p, ok := bySample[rec.Tag]
+		if !ok || !((rec.Status == "" && !rec.Incomplete()) || isSuccess(rec.Status)) {
+			continue
+		}
+		succeeded[rec.Name] = true
+		if rec.Complete.After(p.CompletedAt) {
+			p.LastCompleted, p.CompletedAt = rec.Process, rec.Complete
+		}
+		if terminals[rec.Process] {
+			if reached[p.Sample] == nil {
+				reached[p.Sample] = make(map[string]bool)
+			}
+			reached[p.Sample][rec.Process] = true
+		}
+	}
+	for _, rec := range records {
+		if p, ok := bySample[rec.Tag]; ok && rec.Name != "" && !succeeded[rec.Name] && p.Failed == "" {
+			p.Failed = rec.Process
+		}
+	}
+	for _, p := range progress {
+		p.Reached = len(terminals) > 0 && len(reached[p.Sample]) == len(terminals)
+	}
+	return progress
+}
+
+// runMissing implements the "missing" subcommand, reporting the samples of
+// a samplesheet that never reached the end of the pipeline
+func runMissing(args []string) error {
+	fs := newFlagSet("missing")
+	input := inputFlags(fs)
+	samplesheet := fs.String("samplesheet", "", "Samplesheet of the run")
+	terminalFlag := fs.String("terminal", "", "Name or regular expression of the last per-sample processes (default: the per-sample process that completed last)")
+	fs.Parse(args)
+
+	if *samplesheet == "" {
+		return fmt.Errorf("--samplesheet is required")
+	}
+	sheet, err := readSamplesheet(*samplesheet)
+	if err != nil {
+		return err
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	name, pattern := *terminalFlag, *terminalFlag
+	if name == "" {
+		if name = terminalProcess(trace.Records); name == "" {
+			return fmt.Errorf("no per-sample process found, give the last one with --terminal")
+		}
+		pattern = regexp.QuoteMeta(name)
+	}
+	terminal, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid process pattern %q: %w", pattern, err)
+	}
+	samples := make([]string, len(sheet))
+	for i, s := range sheet {
+		samples[i] = s.Sample
+	}
+	progress := traceSampleProgress(trace.Records, samples, terminal)
+
+	var missing []*sampleProgress
+	for _, p := range progress {
+		if !p.Reached {
+			missing = append(missing, p)
+		}
+	}
+	sort.SliceStable(missing, func(i, j int) bool { return missing[i].CompletedAt.Before(missing[j].CompletedAt) })
+	fmt.Printf("Terminal process: %s\n", name)
+	fmt.Printf("Samples: %d in the samplesheet, %d reached the end, %d missing\n", len(progress), len(progress)-len(missing), len(missing))
+	if len(missing) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SAMPLE\tLAST COMPLETED\tCOMPLETED AT\tFAILED")
+	for _, p := range missing {
+		last, at, failed := "-", "-", "-"
+		if p.LastCompleted != "" {
+			last, at = p.LastCompleted, p.CompletedAt.Format(time.DateTime)
+		}
+		if p.Failed != "" {
+			failed = p.Failed
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Sample, last, at, failed)
+	}
+	w.Flush()
+	return fmt.Errorf("%d of %d samples did not reach the end of the pipeline", len(missing), len(progress))
+}
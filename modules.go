@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// nfcoreModulesURL is where the metadata of nf-core modules is fetched from
+const nfcoreModulesURL = "https://raw.githubusercontent.com/nf-core/modules/master/modules/nf-core/"
+
+// moduleInfo is the part of the meta.yml of an nf-core module shown in
+// reports
+type moduleInfo struct {
+	Name        string // e.g. "star_align"
+	Description string
+	Tools       []string // e.g. ["star"]
+}
+
+// parseModuleMeta reads the name, description and tool names of a meta.yml.
+// Descriptions may continue on more indented lines, as folded by YAML.
+func parseModuleMeta(data []byte) moduleInfo {
+	var info moduleInfo
+	var key string // top-level key of the current block
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := stripYAMLComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' && line[0] != '-' {
+			var value string
+			key, value, _ = strings.Cut(trimmed, ":")
+			value = unquoteYAML(strings.TrimSpace(value))
+			switch key {
+			case "name":
+				info.Name = value
+			case "description":
+				if value != ">" && value != "|" && value != ">-" && value != "|-" {
+					info.Description = value
+				}
+			}
+			continue
+		}
+		switch {
+		case key == "description":
+			info.Description = strings.TrimSpace(info.Description + " " + unquoteYAML(trimmed))
+		case key == "tools" && strings.HasPrefix(trimmed, "- ") && strings.HasSuffix(trimmed, ":"):
+			// Tools are list items named by a key, e.g. "  - star:"
+			info.Tools = append(info.Tools, unquoteYAML(strings.TrimSpace(strings.TrimSuffix(trimmed[2:], ":"))))
+		}
+	}
+	return info
+}
+
+// moduleIndex finds the metadata of the module a process runs, by process
+// name: nf-core processes are named after their module, e.g. STAR_ALIGN
+// runs modules/nf-core/star/align
+type moduleIndex struct {
+	modules map[string]*moduleInfo // by upper case module name
+	client  *http.Client           // fetches modules not found locally, if set
+}
+
+// newModuleIndex indexes the meta.yml files under a modules directory, such
+// as the modules/nf-core directory of a pipeline; with fetch, modules not
+// found there are looked up in the nf-core/modules repository
+func newModuleIndex(dir string, fetch bool) (*moduleIndex, error) {
+	index := &moduleIndex{modules: make(map[string]*moduleInfo)}
+	if fetch {
+		index.client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if dir == "" {
+		return index, nil
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != "meta.yml" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info := parseModuleMeta(data)
+		// Index by the name in the file and by the path, which nested
+		// modules join with underscores
+		rel, _ := filepath.Rel(dir, filepath.Dir(path))
+		index.modules[strings.ToUpper(strings.ReplaceAll(filepath.ToSlash(rel), "/", "_"))] = &info
+		if info.Name != "" {
+			index.modules[strings.ToUpper(info.Name)] = &info
+		}
+		return nil
+	})
+	return index, err
+}
+
+// Lookup returns the metadata of the module of a process, or nil
+func (m *moduleIndex) Lookup(process string) *moduleInfo {
+	name := strings.ToUpper(shortProcessName(process))
+	if info, ok := m.modules[name]; ok {
+		return info
+	}
+	if m.client == nil {
+		return nil
+	}
+	// The module directory is the name split at its first underscore, as
+	// in star/align, or the whole name for modules without subcommands
+	var info *moduleInfo
+	lower := strings.ToLower(name)
+	candidates := []string{lower}
+	if tool, sub, ok := strings.Cut(lower, "_"); ok {
+		candidates = []string{tool + "/" + sub, lower}
+	}
+	for _, candidate := range candidates {
+		data, err := httpGet(m.client, nfcoreModulesURL+candidate+"/meta.yml")
+		if err != nil {
+			slog.Debug("module metadata not found", "process", process, "error", err)
+			continue
+		}
+		parsed := parseModuleMeta(data)
+		info = &parsed
+		break
+	}
+	m.modules[name] = info
+	return info
+}
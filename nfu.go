@@ -1,9 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"os"
 	"regexp"
 	"strconv"
@@ -11,25 +13,23 @@ import (
 	"time"
 )
 
-// TraceRecord represents a single row from the execution trace file
-type TraceRecord struct {
-	TaskID     string
-	Status     string
-	Realtime   time.Duration
-	CPUPercent float64
-	PeakRSS    string
-	PeakVmem   string
-}
-
 // ParseDuration parses time strings with various suffixes to time.Duration
 // Handles formats like "3.6s", "218ms", "1h", "10m", etc.
+// Plain numbers are milliseconds, as written by Nextflow with trace.raw = true.
 func ParseDuration(durationStr string) (time.Duration, error) {
 	// First try to handle standard durations with time.ParseDuration
 	duration, err := time.ParseDuration(durationStr)
 	if err == nil {
+		if duration < 0 {
+			return 0, fmt.Errorf("negative duration: %s", durationStr)
+		}
 		return duration, nil
 	}
 
+	if millis, err := strconv.ParseFloat(durationStr, 64); err == nil {
+		return durationFromFloat(millis * float64(time.Millisecond))
+	}
+
 	// Handle complex formats with multiple units like "1h 21m 27s"
 	parts := strings.Fields(durationStr)
 	var totalDuration time.Duration
@@ -39,7 +39,7 @@ func ParseDuration(durationStr string) (time.Duration, error) {
 		partDuration, err := time.ParseDuration(part)
 		if err != nil {
 			// If parsing fails, it might be due to a non-standard format
-			re := regexp.MustCompile(`^([\d\.]+)\s*([a-zA-Z]+)$`)
+			re := regexp.MustCompile(`^(` + numberPattern + `)\s*([a-zA-Z]+)$`)
 			matches := re.FindStringSubmatch(part)
 
 			if len(matches) != 3 {
@@ -49,166 +49,423 @@ func ParseDuration(durationStr string) (time.Duration, error) {
 			valueStr := matches[1]
 			unit := strings.ToLower(matches[2])
 
-			value, err := strconv.ParseFloat(valueStr, 64)
+			value, err := parseNumber(valueStr)
 			if err != nil {
 				return 0, fmt.Errorf("error parsing duration value %s: %w", valueStr, err)
 			}
 
 			// Convert to time.Duration based on unit
+			var multiplier float64
 			switch unit {
 			case "ns", "nanosecond", "nanoseconds":
-				partDuration = time.Duration(value * float64(time.Nanosecond))
+				multiplier = float64(time.Nanosecond)
 			case "us", "µs", "microsecond", "microseconds":
-				partDuration = time.Duration(value * float64(time.Microsecond))
+				multiplier = float64(time.Microsecond)
 			case "ms", "millisecond", "milliseconds":
-				partDuration = time.Duration(value * float64(time.Millisecond))
+				multiplier = float64(time.Millisecond)
 			case "s", "sec", "second", "seconds":
-				partDuration = time.Duration(value * float64(time.Second))
+				multiplier = float64(time.Second)
 			case "m", "min", "minute", "minutes":
-				partDuration = time.Duration(value * float64(time.Minute))
+				multiplier = float64(time.Minute)
 			case "h", "hr", "hour", "hours":
-				partDuration = time.Duration(value * float64(time.Hour))
+				multiplier = float64(time.Hour)
 			case "d", "day", "days":
-				partDuration = time.Duration(value * 24 * float64(time.Hour))
+				multiplier = 24 * float64(time.Hour)
 			default:
 				return 0, fmt.Errorf("unknown time unit: %s", unit)
 			}
+
+			partDuration, err = durationFromFloat(value * multiplier)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %s", err, part)
+			}
 		}
 
-		totalDuration += partDuration
+		var overflow bool
+		totalDuration, overflow = addDurations(totalDuration, partDuration)
+		if overflow {
+			return 0, fmt.Errorf("duration out of range: %s", durationStr)
+		}
 	}
 
 	return totalDuration, nil
 }
 
-// testDurationParsing tests the ParseDuration function with various formats
-func testDurationParsing() {
-	testDurations := []string{
-		"3.5d",
-		"21h 40m 51s",
-		"1h 21m 27s",
-		"2m",
-		"1m 53s",
-		"42.9s",
-		"500ms",
+// durationFromFloat converts a number of nanoseconds to a time.Duration,
+// rejecting values that are not finite, negative or out of the int64 range
+// (a plain conversion would silently wrap them to arbitrary durations)
+func durationFromFloat(nanos float64) (time.Duration, error) {
+	switch {
+	case math.IsNaN(nanos) || math.IsInf(nanos, 0):
+		return 0, fmt.Errorf("invalid duration")
+	case nanos < 0:
+		return 0, fmt.Errorf("negative duration")
+	case nanos >= math.MaxInt64:
+		return 0, fmt.Errorf("duration out of range")
 	}
+	return time.Duration(nanos), nil
+}
 
-	fmt.Println("Testing ParseDuration function:")
-	fmt.Println("-------------------------------")
-	for _, durStr := range testDurations {
-		dur, err := ParseDuration(durStr)
-		if err != nil {
-			fmt.Printf("Error parsing '%s': %v\n", durStr, err)
-			continue
-		}
+// traceTotals are the totals reported without a subcommand
+type traceTotals struct {
+	Duration Total
+	PeakRSS  int64  // largest peak RSS of any task
+	PeakVmem int64  // largest peak virtual memory of any task
+	Rchar    Total  // bytes read by all tasks
+	Wchar    Total  // bytes written by all tasks
+	trace    *Trace // to tell missing columns from zero totals
+}
 
-		// Calculate minutes for easier comparison
-		minutes := dur.Minutes()
+// totalsRecord is the record of the totals in the machine-readable output
+// formats; values of columns missing from the trace are left out
+type totalsRecord struct {
+	Tasks         int    `json:"tasks"`
+	DurationMs    int64  `json:"duration_ms"`
+	PeakRSSBytes  *int64 `json:"peak_rss_bytes,omitempty"`
+	PeakVmemBytes *int64 `json:"peak_vmem_bytes,omitempty"`
+	RcharBytes    *int64 `json:"rchar_bytes,omitempty"`
+	WcharBytes    *int64 `json:"wchar_bytes,omitempty"`
+}
 
-		fmt.Printf("Original: %-15s | Parsed: %-15v | Minutes: %.2f\n",
-			durStr, dur, minutes)
+// record returns the totals as a record of the machine-readable formats
+func (t *traceTotals) record() totalsRecord {
+	r := totalsRecord{
+		Tasks:      len(t.trace.Records),
+		DurationMs: int64(t.Duration.Float64() / float64(time.Millisecond)),
+	}
+	value := func(column string, v int64) *int64 {
+		if !t.trace.HasColumn(column) {
+			return nil
+		}
+		return &v
+	}
+	total := func(column string, t *Total) *int64 {
+		v, ok := t.Int64()
+		if !ok {
+			slog.Warn("total exceeds the range of the output format and is left out", "column", column, "total", t.Size())
+			return nil
+		}
+		return value(column, v)
 	}
-	fmt.Println("-------------------------------")
+	r.PeakRSSBytes = value("peak_rss", t.PeakRSS)
+	r.PeakVmemBytes = value("peak_vmem", t.PeakVmem)
+	r.RcharBytes = total("rchar", &t.Rchar)
+	r.WcharBytes = total("wchar", &t.Wchar)
+	return r
 }
 
-// calculateTotalDuration calculates the total duration from a file
-func calculateTotalDuration(filePath string) (time.Duration, error) {
-	file, err := os.Open(filePath)
+// calculateTotals calculates the total duration, peak memory and I/O of
+// one or more trace files
+func calculateTotals(paths []string, opts ReadOptions) (*traceTotals, error) {
+	trace, err := readTraces(paths, opts)
 	if err != nil {
-		return 0, fmt.Errorf("error opening file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-
-	// Skip header line
-	if !scanner.Scan() {
-		return 0, fmt.Errorf("error reading header line: %w", scanner.Err())
+	if !trace.HasColumn("duration") {
+		return nil, fmt.Errorf("duration column not found in input file")
 	}
-	header := scanner.Text()
-
-	// Parse header to find the duration column index
-	columns := strings.Split(header, "\t")
-	durationIdx := -1
-	for i, col := range columns {
-		if col == "duration" {
-			durationIdx = i
-			break
-		}
+	warnIncomplete(trace)
+
+	totals := &traceTotals{trace: trace}
+	for _, rec := range trace.Records {
+		totals.Duration.Add(int64(rec.Duration))
+		totals.PeakRSS = max(totals.PeakRSS, rec.PeakRSS)
+		totals.PeakVmem = max(totals.PeakVmem, rec.PeakVmem)
+		totals.Rchar.Add(rec.Rchar)
+		totals.Wchar.Add(rec.Wchar)
 	}
 
-	if durationIdx == -1 {
-		return 0, fmt.Errorf("duration column not found in input file")
-	}
+	return totals, nil
+}
 
-	var totalDuration time.Duration
+// commands maps subcommand names to their entry points
+var commands = map[string]func(args []string) error{
+	"retries":      runRetries,
+	"drift":        runDrift,
+	"interference": runInterference,
+	"changes":      runChanges,
+	"intervals":    runIntervals,
+	"imbalance":    runImbalance,
+	"phases":       runPhases,
+	"efficiency":   runEfficiency,
+	"selfcheck":    runSelfCheck,
+	"version":      runVersion,
+	"self-update":  runSelfUpdate,
+	"release":      runRelease,
+	"cat":          runCat,
+	"profile":      runProfile,
+	"throughput":   runThroughput,
+	"sla":          runSLA,
+	"metrics":      runMetrics,
+	"check":        runCheck,
+	"nodes":        runNodes,
+	"energy":       runEnergy,
+	"orphans":      runOrphans,
+	"clean":        runClean,
+	"stagein":      runStagein,
+	"calibrate":    runCalibrate,
+	"snapshot":     runSnapshot,
+	"expect":       runExpect,
+	"missing":      runMissing,
+	"describe":     runDescribe,
+	"tools":        runTools,
+	"post":         runPost,
+	"runs":         runRuns,
+	"pareto":       runPareto,
+	"task":         runTask,
+	"summary":      runSummary,
+	"workdir-of":   runWorkdirOf,
+	"serve":        runServe,
+	"compare":      runCompare,
+	"recommend":    runRecommend,
+	"rocrate":      runROCrate,
+	"concurrency":  runConcurrency,
+	"cost":         runCost,
+	"health":       runHealth,
+}
 
-	// Process each data line
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Split(line, "\t")
+// usageOutput is where the flag sets of subcommands write their usage;
+// help points it at stdout so that the help can be paged
+var usageOutput io.Writer = os.Stderr
 
-		// Skip lines with insufficient columns
-		if len(fields) <= durationIdx {
-			continue
-		}
+// newFlagSet returns the flag set of a subcommand, which exits on errors and
+// writes its usage to usageOutput
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.SetOutput(usageOutput)
+	return fs
+}
 
-		// Parse duration
-		durationStr := fields[durationIdx]
-		duration, err := ParseDuration(durationStr)
-		if err != nil {
-			fmt.Printf("Warning: error parsing duration '%s': %v\n", durationStr, err)
-			continue
-		}
+// help and demo dispatch to other commands and are registered at startup to
+// avoid an initialization cycle
+func init() {
+	commands["help"] = runHelp
+	commands["demo"] = runDemo
+}
+
+// inputOptions holds the input flags shared by all subcommands
+type inputOptions struct {
+	Paths  pathList
+	Groups string
+	Read   ReadOptions
+}
+
+// pathList is a flag collecting the value of every time it is given
+type pathList []string
+
+func (p *pathList) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *pathList) Set(path string) error {
+	*p = append(*p, path)
+	return nil
+}
+
+// inputFlags registers the input file flags shared by all subcommands
+func inputFlags(fs *flag.FlagSet) *inputOptions {
+	opts := &inputOptions{}
+	pathFlags(fs, &opts.Paths)
+	readFlags(fs, opts)
+	return opts
+}
+
+// pathFlags registers the -i and --input flags of the input files
+func pathFlags(fs *flag.FlagSet, paths *pathList) {
+	fs.Var(paths, "i", "Path to the input file, tab- or comma-separated and optionally gzip-compressed, or - for stdin; repeat to aggregate several traces")
+	fs.Var(paths, "input", "Path to the input file, tab- or comma-separated and optionally gzip-compressed, or - for stdin; repeat to aggregate several traces")
+}
+
+// readFlags registers the flags controlling how traces are parsed
+func readFlags(fs *flag.FlagSet, opts *inputOptions) {
+	fs.StringVar(&opts.Groups, "g", "", "YAML file mapping processes into groups reported instead of individual processes")
+	fs.StringVar(&opts.Groups, "groups", "", "YAML file mapping processes into groups reported instead of individual processes")
+	fs.BoolVar(&opts.Read.Strict, "strict", false, "Fail on the first malformed field instead of skipping it with a warning")
+	fs.StringVar(&opts.Read.Since, "since", "", "Only include tasks completed at or after this time (e.g. '2024-03-01 02:00' or '02:00')")
+	fs.StringVar(&opts.Read.Until, "until", "", "Only include tasks submitted at or before this time")
+	fs.StringVar(&opts.Read.StatusRules, "status-rules", "", "YAML file of statuses derived from status and exit code (e.g. OOM: [137]), used by all reports and filters")
+	filterFlags(fs, &opts.Read.Filter)
+	fs.StringVar(&opts.Read.ClockSkew, "clock-skew", "clamp", "Handling of timestamps out of order because of clock skew: clamp, flag or drop")
+	fs.StringVar(&opts.Read.Incidents, "incidents", "", "CSV file of outages and maintenance windows (start,end,description) whose tasks are left out")
+	fs.StringVar(&opts.Read.IncidentPolicy, "incident-policy", "drop", "Handling of tasks that ran during --incidents: drop or flag")
+	fs.StringVar(&opts.Read.Runs, "runs", "merge", "Runs of a trace appended to by several runs to include: merge, split (tasks of every run), last or the number of a run")
+	fs.StringVar(&opts.Read.Attempts, "attempts", "all", "Attempts of retried tasks to include: all (true cost), final (logical pipeline time) or first")
+	fs.BoolVar(&opts.Read.Explain, "explain", false, "Print detected columns, assumed units and excluded rows to stderr")
+}
 
-		totalDuration += duration
+// loadTrace reads the traces given via the input flags of a subcommand
+func loadTrace(fs *flag.FlagSet, opts *inputOptions) (*Trace, error) {
+	if len(opts.Paths) == 0 {
+		fmt.Fprintln(os.Stderr, "Please provide an input file path using -i or --input flag")
+		fs.Usage()
+		os.Exit(1)
 	}
+	return readGroupedTraces(opts.Paths, opts)
+}
 
-	if err := scanner.Err(); err != nil {
-		return 0, fmt.Errorf("error scanning file: %w", err)
+// readGroupedTrace reads a trace and rolls processes up into the groups
+// defined in the groups file, if one is given
+func readGroupedTrace(filePath string, opts *inputOptions) (*Trace, error) {
+	return readGroupedTraces([]string{filePath}, opts)
+}
+
+// readGroupedTraces reads several traces as one, see readTraces, and rolls
+// processes up into groups as readGroupedTrace does
+func readGroupedTraces(paths []string, opts *inputOptions) (*Trace, error) {
+	trace, err := readTraces(paths, opts.Read)
+	if err != nil {
+		return nil, err
+	}
+	warnIncomplete(trace)
+	explainTrace(trace)
+	if opts.Groups != "" {
+		groups, err := loadProcessGroups(opts.Groups)
+		if err != nil {
+			return nil, err
+		}
+		groups.Apply(trace.Records)
 	}
+	return trace, nil
+}
 
-	return totalDuration, nil
+// isTracePath reports whether a command line argument names a trace rather
+// than a subcommand
+func isTracePath(arg string) bool {
+	if _, ok := commands[arg]; ok {
+		return false
+	}
+	if arg == "-" {
+		return true
+	}
+	info, err := os.Stat(arg)
+	return err == nil && !info.IsDir()
 }
 
 func main() {
 	// Define and parse command line flags
-	testFlag := flag.Bool("t", false, "Run tests for duration parsing")
-	flag.BoolVar(testFlag, "test", false, "Run tests for duration parsing")
-
-	inputFlag := flag.String("i", "", "Path to the input file")
-	flag.StringVar(inputFlag, "input", "", "Path to the input file")
+	testFlag := flag.Bool("t", false, "Run parser self-checks (same as 'nfu selfcheck')")
+	flag.BoolVar(testFlag, "test", false, "Run parser self-checks (same as 'nfu selfcheck')")
+
+	var inputFlag pathList
+	flag.Var(&inputFlag, "i", "Path to the input file, tab- or comma-separated and optionally gzip-compressed, or - for stdin; repeat to aggregate several traces")
+	flag.Var(&inputFlag, "input", "Path to the input file, tab- or comma-separated and optionally gzip-compressed, or - for stdin; repeat to aggregate several traces")
+
+	strictFlag := flag.Bool("strict", false, "Fail on the first malformed field instead of skipping it with a warning")
+	sinceFlag := flag.String("since", "", "Only include tasks completed at or after this time (e.g. '2024-03-01 02:00' or '02:00')")
+	untilFlag := flag.String("until", "", "Only include tasks submitted at or before this time")
+	statusRulesFlag := flag.String("status-rules", "", "YAML file of statuses derived from status and exit code (e.g. OOM: [137]), used by all reports and filters")
+	var filter RowFilter
+	filterFlags(flag.CommandLine, &filter)
+	runsFlag := flag.String("runs", "merge", "Runs of a trace appended to by several runs to include: merge, split (tasks of every run), last or the number of a run")
+	attemptsFlag := flag.String("attempts", "all", "Attempts of retried tasks to sum: all (true cost), final (logical pipeline time) or first")
+
+	// Logging and precision flags apply to all subcommands and have to precede the subcommand name
+	logLevel := flag.String("log-level", "info", "Minimum level of diagnostics written to stderr (debug, info, warn, error)")
+	logFormat := flag.String("log-format", "text", "Format of diagnostics written to stderr (text, json)")
+	durationUnitsFlag := flag.Int("duration-units", 3, "Units of durations shown in reports, e.g. 2 for '1h 5m' instead of '1h 4m 31s'")
+	decimalsFlag := flag.Int("decimals", 1, "Decimal places of percentages in reports")
+	costDecimalsFlag := flag.Int("cost-decimals", 2, "Decimal places of costs and energy in reports")
+
+	// Output flags apply to all subcommands and have to precede the subcommand name
+	outputFormatFlag := flag.String("output-format", "text", "Format of reports: text, or records as json (JSON Lines), csv or tsv ("+structuredCommandList()+")")
+	outputFlag := flag.String("o", "", "Write reports to this file instead of stdout")
+	flag.StringVar(outputFlag, "output", "", "Write reports to this file instead of stdout")
 
 	flag.Parse()
 
-	// If test flag is provided, run test function
+	if err := setupLogging(*logLevel, *logFormat); err != nil {
+		fatal(err)
+	}
+	if err := setPrecision(*durationUnitsFlag, *decimalsFlag, *costDecimalsFlag); err != nil {
+		fatal(err)
+	}
+	command := flag.Arg(0)
+	if isTracePath(command) {
+		command = ""
+	}
+	if err := setOutputFormat(*outputFormatFlag, command); err != nil {
+		fatal(err)
+	}
+	if *outputFlag != "" {
+		f, err := redirectOutput(*outputFlag)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+	}
+
+	// Dispatch to a subcommand if one is given; arguments that are no
+	// subcommand but files, or - for stdin, are traces to total
+	if command != "" {
+		run, ok := commands[command]
+		if !ok {
+			fatal(fmt.Errorf("unknown command '%s'", command))
+		}
+		if err := runWithStepSummary(command, run, flag.Args()[1:]); err != nil {
+			fatal(err)
+		}
+		printExclusions(os.Stderr)
+		return
+	}
+
+	// If test flag is provided, run the self-checks
 	if *testFlag {
-		testDurationParsing()
+		if err := runSelfCheck(nil); err != nil {
+			fatal(err)
+		}
 		return
 	}
 
 	// Check if input flag is provided
-	if *inputFlag == "" {
-		fmt.Println("Please provide an input file path using -i or --input flag")
+	inputFlag = append(inputFlag, flag.Args()...)
+	if len(inputFlag) == 0 {
+		fmt.Fprintln(os.Stderr, "Please provide an input file path using -i or --input flag")
 		flag.Usage()
 		os.Exit(1)
 	}
 
 	// Calculate total duration from the input file
-	totalDuration, err := calculateTotalDuration(*inputFlag)
+	totals, err := calculateTotals(inputFlag, ReadOptions{
+		Strict:      *strictFlag,
+		Since:       *sinceFlag,
+		Until:       *untilFlag,
+		Runs:        *runsFlag,
+		Attempts:    *attemptsFlag,
+		StatusRules: *statusRulesFlag,
+		Filter:      filter,
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
+	}
+
+	if outputFormat != "text" {
+		if err := writeRecords(os.Stdout, []totalsRecord{totals.record()}); err != nil {
+			fatal(err)
+		}
+		return
 	}
 
 	// Print the total duration in various formats
-	fmt.Printf("Total duration: %v\n", totalDuration)
+	fmt.Printf("Total duration: %v\n", &totals.Duration)
 
 	// Convert to human-readable format
-	hours := int(totalDuration.Hours())
-	minutes := int(totalDuration.Minutes()) % 60
-	seconds := int(totalDuration.Seconds()) % 60
+	hours, minutes, seconds := totals.Duration.HMS()
 
-	fmt.Printf("Total duration: %dh %dm %ds\n", hours, minutes, seconds)
-	fmt.Printf("Total minutes: %.2f\n", totalDuration.Minutes())
+	fmt.Printf("Total duration: %sh %dm %ds\n", hours, minutes, seconds)
+	fmt.Printf("Total minutes: %.2f\n", totals.Duration.Float64()/float64(time.Minute))
+
+	// Memory and I/O, for the columns the trace has
+	if totals.trace.HasColumn("peak_rss") {
+		fmt.Printf("Max peak RSS: %s\n", FormatSize(totals.PeakRSS))
+	}
+	if totals.trace.HasColumn("peak_vmem") {
+		fmt.Printf("Max peak vmem: %s\n", FormatSize(totals.PeakVmem))
+	}
+	if totals.trace.HasColumn("rchar") {
+		fmt.Printf("Total read: %s\n", totals.Rchar.Size())
+	}
+	if totals.trace.HasColumn("wchar") {
+		fmt.Printf("Total written: %s\n", totals.Wchar.Size())
+	}
 }
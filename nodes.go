@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// nodeStats compares the tasks that ran on one node with those on its peers
+type nodeStats struct {
+	Host       string
+	Tasks      int
+	Failed     int
+	PeerFailed float64   // failure rate of the tasks on all other nodes
+	Ratios     []float64 // runtime of each successful task relative to the median of its process
+	Exclude    []string  // reasons to exclude the node
+}
+
+// FailureRate returns the share of failed tasks on the node
+func (n *nodeStats) FailureRate() float64 {
+	if n.Tasks == 0 {
+		return 0
+	}
+	return float64(n.Failed) / float64(n.Tasks)
+}
+
+// Inflation returns how much longer tasks ran on the node than the same
+// processes elsewhere, as the median of the runtime ratios
+func (n *nodeStats) Inflation() float64 {
+	if len(n.Ratios) == 0 {
+		return 1
+	}
+	return median(n.Ratios)
+}
+
+// collectNodeStats ranks nodes by their failure rate in excess of their
+// peers and by duration inflation. Runtimes are compared with the median
+// runtime of the same process over all nodes and runs.
+func collectNodeStats(records []TraceRecord, minTasks int, maxExcess, maxInflation float64) []*nodeStats {
+	runtimes := make(map[string][]float64)
+	byHost := make(map[string]*nodeStats)
+	totalTasks, totalFailed := 0, 0
+	for _, rec := range records {
+		if rec.Hostname == "" {
+			excludeTask(rec, "no hostname")
+			continue
+		}
+		n, ok := byHost[rec.Hostname]
+		if !ok {
+			n = &nodeStats{Host: rec.Hostname}
+			byHost[rec.Hostname] = n
+		}
+		n.Tasks++
+		totalTasks++
+		if rec.Status != "" && !isSuccess(rec.Status) {
+			n.Failed++
+			totalFailed++
+		} else if rec.Runtime() > 0 {
+			runtimes[rec.Process] = append(runtimes[rec.Process], rec.Runtime().Seconds())
+		}
+	}
+
+	medians := make(map[string]float64)
+	for process, values := range runtimes {
+		medians[process] = median(values)
+	}
+	for _, rec := range records {
+		n := byHost[rec.Hostname]
+		if n == nil || (rec.Status != "" && !isSuccess(rec.Status)) || rec.Runtime() <= 0 {
+			continue
+		}
+		if m := medians[rec.Process]; m > 0 && len(runtimes[rec.Process]) > 1 {
+			n.Ratios = append(n.Ratios, rec.Runtime().Seconds()/m)
+		}
+	}
+
+	nodes := make([]*nodeStats, 0, len(byHost))
+	for _, n := range byHost {
+		if peers := totalTasks - n.Tasks; peers > 0 {
+			n.PeerFailed = float64(totalFailed-n.Failed) / float64(peers)
+		}
+		if n.Tasks >= minTasks {
+			if excess := n.FailureRate() - n.PeerFailed; excess > maxExcess {
+				n.Exclude = append(n.Exclude, fmt.Sprintf("failure rate +%.0f points", 100*excess))
+			}
+			if n.Inflation() > maxInflation {
+				n.Exclude = append(n.Exclude, fmt.Sprintf("tasks %.1fx slower", n.Inflation()))
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		ei, ej := nodes[i].FailureRate()-nodes[i].PeerFailed, nodes[j].FailureRate()-nodes[j].PeerFailed
+		if ei != ej {
+			return ei > ej
+		}
+		if nodes[i].Inflation() != nodes[j].Inflation() {
+			return nodes[i].Inflation() > nodes[j].Inflation()
+		}
+		return nodes[i].Host < nodes[j].Host
+	})
+	return nodes
+}
+
+// runNodes implements the "nodes" subcommand, ranking the nodes of one or
+// more runs by failures and slowdowns relative to their peers and
+// suggesting nodes to exclude from scheduling
+func runNodes(args []string) error {
+	fs := newFlagSet("nodes")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu nodes [flags] -i <trace> [-i <trace>...] [<trace>...]")
+		fs.PrintDefaults()
+	}
+	input := inputFlags(fs)
+	minTasks := fs.Int("min-tasks", 5, "Minimum number of tasks on a node to suggest excluding it")
+	maxExcess := fs.Float64("max-failure-excess", 10, "Suggest excluding nodes whose failure rate exceeds that of their peers by more than this many percentage points")
+	maxInflation := fs.Float64("max-inflation", 1.5, "Suggest excluding nodes whose tasks run this many times longer than the same processes elsewhere")
+	fs.Parse(args)
+
+	// Traces of several runs may also be given as arguments, e.g. by a glob
+	input.Paths = append(input.Paths, fs.Args()...)
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	if !trace.HasColumn("hostname") {
+		return fmt.Errorf("hostname column not found in input file")
+	}
+
+	nodes := collectNodeStats(trace.Records, *minTasks, *maxExcess/100, *maxInflation)
+	var exclude []string
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tTASKS\tFAILED\tFAILURE RATE\tPEERS\tINFLATION\tSUGGESTION")
+	for _, n := range nodes {
+		suggestion := "-"
+		if len(n.Exclude) > 0 {
+			suggestion = "exclude (" + strings.Join(n.Exclude, ", ") + ")"
+			exclude = append(exclude, n.Host)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%.2fx\t%s\n", n.Host, n.Tasks, n.Failed,
+			FormatPercent(100*n.FailureRate()), FormatPercent(100*n.PeerFailed), n.Inflation(), suggestion)
+	}
+	w.Flush()
+
+	if len(exclude) > 0 {
+		sort.Strings(exclude)
+		fmt.Printf("\nSuggested exclude list: %s\n", strings.Join(exclude, ","))
+		fmt.Printf("e.g. for SLURM: process.clusterOptions = '--exclude=%s'\n", strings.Join(exclude, ","))
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// taskDirName matches the two levels of a task work directory, e.g.
+// "3f/8a2c41..." for the task with hash 3f/8a2c41
+var (
+	taskDirPrefix = regexp.MustCompile(`^[0-9a-f]{2}$`)
+	taskDirSuffix = regexp.MustCompile(`^[0-9a-f]{6,}$`)
+)
+
+// workDir is a task directory under the work directory
+type workDir struct {
+	Path     string
+	Hash     string // as in traces, e.g. "3f/8a2c41"
+	Size     int64
+	Modified time.Time // latest modification of any file in the directory
+}
+
+// scanWorkDirs lists the task directories under a Nextflow work directory
+// with their size and last modification
+func scanWorkDirs(root string) ([]workDir, error) {
+	prefixes, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("error reading work directory: %w", err)
+	}
+	var dirs []workDir
+	for _, prefix := range prefixes {
+		if !prefix.IsDir() || !taskDirPrefix.MatchString(prefix.Name()) {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(root, prefix.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading work directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || !taskDirSuffix.MatchString(entry.Name()) {
+				continue
+			}
+			dir := workDir{
+				Path: filepath.Join(root, prefix.Name(), entry.Name()),
+				Hash: prefix.Name() + "/" + entry.Name()[:6],
+			}
+			// Symbolic links are counted, not followed: inputs are staged as
+			// links to files that belong elsewhere
+			err := filepath.WalkDir(dir.Path, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() {
+					dir.Size += info.Size()
+				}
+				if info.ModTime().After(dir.Modified) {
+					dir.Modified = info.ModTime()
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error reading work directory: %w", err)
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// findOrphans returns the task directories not referenced by any of the
+// traces and not modified within minAge, largest first
+func findOrphans(dirs []workDir, known map[string]bool, minAge time.Duration, now time.Time) []workDir {
+	var orphans []workDir
+	for _, dir := range dirs {
+		if known[dir.Hash] || now.Sub(dir.Modified) < minAge {
+			continue
+		}
+		orphans = append(orphans, dir)
+	}
+	sort.SliceStable(orphans, func(i, j int) bool { return orphans[i].Size > orphans[j].Size })
+	return orphans
+}
+
+// traceHashes returns the task hashes of the given traces, shortened to the
+// form "3f/8a2c41" used by Nextflow. The hash of every line counts, also of
+// a truncated last line, and none of the filters of the reports apply: a
+// task left out would have its directory reported, and removed, although
+// the trace refers to it.
+func traceHashes(paths []string) (map[string]bool, error) {
+	known := make(map[string]bool)
+	for _, path := range paths {
+		if err := scanHashes(path, known); err != nil {
+			return nil, err
+		}
+	}
+	return known, nil
+}
+
+// scanHashes adds the hashes of a trace to known, reading its lines as they
+// are rather than parsing its records
+func scanHashes(path string, known map[string]bool) error {
+	r, name, err := openTrace(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		if scanner.Err() == nil {
+			return fmt.Errorf("error reading header line: %s is empty", name)
+		}
+		return fmt.Errorf("error reading header line of %s: %w", name, scanner.Err())
+	}
+	header := strings.TrimSuffix(strings.TrimPrefix(scanner.Text(), "\ufeff"), "\r")
+	sep := traceSeparator(header)
+	column := -1
+	for i, col := range strings.Split(header, sep) {
+		if strings.TrimSpace(col) == "hash" {
+			column = i
+		}
+	}
+	if column < 0 {
+		return fmt.Errorf("hash column not found in %s", name)
+	}
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSuffix(scanner.Text(), "\r"), sep)
+		if column >= len(fields) {
+			continue
+		}
+		prefix, suffix, ok := strings.Cut(strings.TrimSpace(fields[column]), "/")
+		if ok && len(suffix) >= 6 {
+			known[prefix+"/"+suffix[:6]] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error scanning %s: %w", name, err)
+	}
+	return nil
+}
+
+// orphanFlags registers the flags shared by "orphans" and "clean"
+func orphanFlags(fs *flag.FlagSet) (workRoot *string, minAge *time.Duration) {
+	workRoot = fs.String("w", "work", "Nextflow work directory")
+	fs.StringVar(workRoot, "work-dir", "work", "Nextflow work directory")
+	minAge = fs.Duration("min-age", 24*time.Hour, "Only consider directories not modified for this long, to spare running sessions")
+	return workRoot, minAge
+}
+
+// runOrphans implements the "orphans" subcommand, listing task directories
+// that none of the given traces refer to
+func runOrphans(args []string) error {
+	fs := newFlagSet("orphans")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu orphans [flags] -i <trace> [-i <trace>...] [<trace>...]")
+		fmt.Fprintln(fs.Output(), "Give the traces of all sessions that should be kept: directories of tasks")
+		fmt.Fprintln(fs.Output(), "missing from them are reported as orphaned. Every task of the traces")
+		fmt.Fprintln(fs.Output(), "counts, so the filters of other reports are not available.")
+		fs.PrintDefaults()
+	}
+	var paths pathList
+	pathFlags(fs, &paths)
+	workRoot, minAge := orphanFlags(fs)
+	pathsOnly := fs.Bool("paths", false, "Print only the paths, one per line, e.g. for xargs")
+	fs.Parse(args)
+
+	if paths = append(paths, fs.Args()...); len(paths) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	known, err := traceHashes(paths)
+	if err != nil {
+		return err
+	}
+	dirs, err := scanWorkDirs(*workRoot)
+	if err != nil {
+		return err
+	}
+	orphans := findOrphans(dirs, known, *minAge, time.Now())
+
+	if *pathsOnly {
+		for _, dir := range orphans {
+			fmt.Println(dir.Path)
+		}
+		return nil
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tSIZE\tMODIFIED")
+	for _, dir := range orphans {
+		total += dir.Size
+		fmt.Fprintf(w, "%s\t%s\t%s\n", dir.Path, FormatSize(dir.Size), dir.Modified.Format(time.DateTime))
+	}
+	w.Flush()
+	fmt.Printf("\nOrphaned: %d of %d task directories, %s reclaimable\n", len(orphans), len(dirs), FormatSize(total))
+	return nil
+}
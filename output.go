@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Output format of reports, set with the global --output-format flag. In
+// the machine-readable formats a report is a list of records, structs
+// whose json tags are the field names in every format: one JSON object
+// per record (JSON Lines) for json, a header row and one row per record
+// for csv and tsv. The record structs are the schema of the output.
+var outputFormat = "text"
+
+// structuredCommands are the commands writing records in the
+// machine-readable formats; "" is nfu without a subcommand
+var structuredCommands = map[string]bool{
+	"": true, "summary": true, "cat": true, "serve": true, "concurrency": true, "recommend": true,
+	"pareto": true, "tools": true, "efficiency": true, "retries": true, "compare": true, "cost": true, "health": true,
+}
+
+// structuredCommandList lists the commands writing records, for help and
+// errors
+func structuredCommandList() string {
+	var names []string
+	for name := range structuredCommands {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return "nfu without a subcommand, " + strings.Join(names, ", ")
+}
+
+// setOutputFormat validates and sets the output format of the command
+func setOutputFormat(format, command string) error {
+	switch format {
+	case "text", "json", "csv", "tsv":
+	default:
+		return fmt.Errorf("unknown output format '%s' (use text, json, csv or tsv)", format)
+	}
+	if format != "text" && !structuredCommands[command] {
+		return fmt.Errorf("'%s' has no %s output (supported by %s)", command, format, structuredCommandList())
+	}
+	outputFormat = format
+	return nil
+}
+
+// redirectOutput makes reports go to the given file instead of stdout
+func redirectOutput(path string) (*os.File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %w", err)
+	}
+	os.Stdout = f
+	return f, nil
+}
+
+// writeRecords writes records, a slice of structs, in the output format
+func writeRecords(w io.Writer, records any) error {
+	return writeRecordsAs(w, outputFormat, records)
+}
+
+// writeRecordsAs writes records in the given machine-readable format
+func writeRecordsAs(w io.Writer, format string, records any) error {
+	v := reflect.ValueOf(records)
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		for i := 0; i < v.Len(); i++ {
+			if err := enc.Encode(v.Index(i).Interface()); err != nil {
+				return fmt.Errorf("error writing record: %w", err)
+			}
+		}
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	if format == "tsv" {
+		cw.Comma = '\t'
+	}
+	t := v.Type().Elem()
+	header := make([]string, t.NumField())
+	for i := range header {
+		header[i], _, _ = strings.Cut(t.Field(i).Tag.Get("json"), ",")
+	}
+	cw.Write(header)
+	for i := 0; i < v.Len(); i++ {
+		record := v.Index(i)
+		row := make([]string, record.NumField())
+		for j := range row {
+			row[j] = recordValue(record.Field(j))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// recordValue renders a field of a record for csv and tsv, a nil pointer
+// (a value not recorded) or an empty map or slice as an empty field
+func recordValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return ""
+		}
+	case reflect.Map, reflect.Slice:
+		if v.Len() == 0 {
+			return ""
+		}
+	}
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Struct, reflect.Map, reflect.Slice:
+		// Nested values are kept in a single field as JSON
+		data, _ := json.Marshal(v.Interface())
+		return string(data)
+	}
+	return fmt.Sprint(v.Interface())
+}
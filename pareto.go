@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// paretoMetrics are the per-process totals "pareto" can rank by, with how
+// to render them and the unit of their values in records
+var paretoMetrics = map[string]struct {
+	name   string
+	column string
+	unit   string
+	value  func(*ProcessStats) float64
+	format func(float64) string
+}{
+	"runtime": {"runtime", "RUNTIME", "hours", func(s *ProcessStats) float64 { return s.Runtime.Hours() },
+		func(h float64) string { return FormatDuration(time.Duration(h * float64(time.Hour))) }},
+	"cpu": {"CPU time", "CPU TIME", "cpu_hours", func(s *ProcessStats) float64 { return s.CPUHours },
+		func(h float64) string { return fmt.Sprintf("%.1f CPU h", h) }},
+	"memory": {"memory-GB-hours", "MEMORY-GB-HOURS", "gb_hours", func(s *ProcessStats) float64 { return s.MemoryGBHours },
+		func(h float64) string { return fmt.Sprintf("%.1f GB h", h) }},
+}
+
+// paretoRecord is a process in the machine-readable output formats of
+// "pareto"; shares are in percent
+type paretoRecord struct {
+	Rank       int     `json:"rank"`
+	Process    string  `json:"process"`
+	Tasks      int     `json:"tasks"`
+	Value      float64 `json:"value"`
+	Unit       string  `json:"unit"`
+	Share      float64 `json:"share"`
+	Cumulative float64 `json:"cumulative"`
+	Covering   bool    `json:"covering"` // among the fewest processes reaching the threshold
+}
+
+// runPareto implements the "pareto" subcommand, ranking processes by their
+// total runtime with the cumulative share of the run
+func runPareto(args []string) error {
+	fs := newFlagSet("pareto")
+	input := inputFlags(fs)
+	by := fs.String("by", "runtime", "Total to rank processes by: runtime, cpu or memory (memory-GB-hours)")
+	threshold := fs.Float64("threshold", 80, "Cumulative share in percent the processes marked with * account for")
+	fs.Parse(args)
+
+	metric, ok := paretoMetrics[*by]
+	if !ok {
+		return fmt.Errorf("unknown total '%s' (use runtime, cpu or memory)", *by)
+	}
+	if *threshold <= 0 || *threshold > 100 {
+		return fmt.Errorf("threshold must be between 0 and 100")
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	stats := aggregateByProcess(trace.Records)
+	sort.SliceStable(stats, func(i, j int) bool { return metric.value(stats[i]) > metric.value(stats[j]) })
+	total := 0.0
+	for _, s := range stats {
+		total += metric.value(s)
+	}
+	if total <= 0 {
+		return fmt.Errorf("no %s recorded in the trace", metric.name)
+	}
+
+	// The processes marked are the fewest that reach the threshold
+	covering, cumulative := 0, 0.0
+	for _, s := range stats {
+		if 100*cumulative/total >= *threshold {
+			break
+		}
+		cumulative += metric.value(s)
+		covering++
+	}
+	if outputFormat != "text" {
+		records := make([]paretoRecord, len(stats))
+		cumulative = 0
+		for i, s := range stats {
+			cumulative += metric.value(s)
+			records[i] = paretoRecord{
+				Rank:       i + 1,
+				Process:    s.Process,
+				Tasks:      s.Tasks,
+				Value:      metric.value(s),
+				Unit:       metric.unit,
+				Share:      100 * metric.value(s) / total,
+				Cumulative: 100 * cumulative / total,
+				Covering:   i < covering,
+			}
+		}
+		return writeRecords(os.Stdout, records)
+	}
+	fmt.Printf("%d of %d processes account for %s of the %s (%s)\n\n", covering, len(stats),
+		FormatPercent(100*cumulative/total), metric.name, metric.format(total))
+
+	cumulative = 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "RANK\tPROCESS\tTASKS\t%s\tSHARE\tCUMULATIVE\t\n", metric.column)
+	for i, s := range stats {
+		cumulative += metric.value(s)
+		mark := ""
+		if i < covering {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\t%s\n", i+1, s.Process, s.Tasks, metric.format(metric.value(s)),
+			FormatPercent(100*metric.value(s)/total), FormatPercent(100*cumulative/total), mark)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// processSpan is the period during which tasks of a process were running
+type processSpan struct {
+	Process    string
+	Start, End time.Time
+	Tasks      int
+	Runtime    time.Duration
+	CPUTime    time.Duration // runtime weighted by the measured CPU utilization
+}
+
+// phase is a group of processes that were running at roughly the same time
+type phase struct {
+	Start, End time.Time
+	Processes  []*processSpan
+}
+
+// Label names the phase after the process that consumed most runtime in it
+func (p *phase) Label() string {
+	main := p.Processes[0]
+	for _, span := range p.Processes {
+		if span.Runtime > main.Runtime {
+			main = span
+		}
+	}
+	return shortProcessName(main.Process)
+}
+
+// collectProcessSpans determines the active period of every process
+func collectProcessSpans(records []TraceRecord) []*processSpan {
+	var order []*processSpan
+	byProcess := make(map[string]*processSpan)
+	for _, rec := range records {
+		if rec.Start.IsZero() {
+			excludeTask(rec, "no start time")
+			continue
+		}
+		span, ok := byProcess[rec.Process]
+		if !ok {
+			span = &processSpan{Process: rec.Process, Start: rec.Start, End: rec.End()}
+			byProcess[rec.Process] = span
+			order = append(order, span)
+		}
+		if rec.Start.Before(span.Start) {
+			span.Start = rec.Start
+		}
+		if rec.End().After(span.End) {
+			span.End = rec.End()
+		}
+		span.Tasks++
+		span.Runtime += rec.Runtime()
+		span.CPUTime += time.Duration(float64(rec.Runtime()) * rec.CPUPercent / 100)
+	}
+	return order
+}
+
+// segmentPhases clusters processes into phases. Processes are visited in
+// order of their first task; a process joins the current phase when at least
+// minOverlap of its active period falls within the phase, otherwise it opens
+// a new phase.
+func segmentPhases(spans []*processSpan, minOverlap float64) []*phase {
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+
+	var phases []*phase
+	var current *phase
+	for _, span := range spans {
+		if current != nil {
+			// Processes with a single instantaneous task join if they fall within the phase
+			joins := !span.Start.After(current.End)
+			if length := span.End.Sub(span.Start); length > 0 {
+				shared := overlap(span.Start, span.End, current.Start, current.End)
+				joins = shared.Seconds()/length.Seconds() >= minOverlap
+			}
+			if joins {
+				current.Processes = append(current.Processes, span)
+				if span.End.After(current.End) {
+					current.End = span.End
+				}
+				continue
+			}
+		}
+		current = &phase{Start: span.Start, End: span.End, Processes: []*processSpan{span}}
+		phases = append(phases, current)
+	}
+	return phases
+}
+
+// runPhases implements the "phases" subcommand, segmenting the run timeline
+// into phases of temporally co-occurring processes
+func runPhases(args []string) error {
+	fs := newFlagSet("phases")
+	input := inputFlags(fs)
+	minOverlap := fs.Float64("min-overlap", 0.5, "Fraction of a process' active period that must overlap a phase for the process to join it")
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	if !trace.HasColumn("start") {
+		return fmt.Errorf("start column not found in input file")
+	}
+
+	phases := segmentPhases(collectProcessSpans(trace.Records), *minOverlap)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PHASE\tSTART\tWALL TIME\tTASKS\tRUNTIME\tCPU TIME\tPROCESSES")
+	for i, p := range phases {
+		var tasks int
+		var runtime, cpuTime time.Duration
+		var names []string
+		for _, span := range p.Processes {
+			tasks += span.Tasks
+			runtime += span.Runtime
+			cpuTime += span.CPUTime
+			names = append(names, span.Process)
+		}
+		fmt.Fprintf(w, "%d (%s)\t%s\t%s\t%d\t%s\t%s\t%s\n", i+1, p.Label(),
+			p.Start.Format(time.DateTime), FormatDuration(p.End.Sub(p.Start)), tasks,
+			FormatDuration(runtime), FormatDuration(cpuTime), strings.Join(names, ", "))
+	}
+	return w.Flush()
+}
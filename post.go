@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// postRun is the run summary "post" sends, by default as JSON
+type postRun struct {
+	Trace      string       `json:"trace"`
+	Tasks      int          `json:"tasks"`
+	Failed     int          `json:"failed"`
+	Submit     string       `json:"submit,omitempty"`
+	Complete   string       `json:"complete,omitempty"`
+	WallTimeMs int64        `json:"wall_time_ms"`
+	RuntimeMs  int64        `json:"runtime_ms"`
+	CPUHours   float64      `json:"cpu_hours"`
+	Samples    []postSample `json:"samples"`
+}
+
+// postSample summarises the tasks of one sample
+type postSample struct {
+	Sample       string  `json:"sample"`
+	Tasks        int     `json:"tasks"`
+	Failed       int     `json:"failed"`
+	Done         bool    `json:"done"`
+	Submit       string  `json:"submit,omitempty"`
+	Complete     string  `json:"complete,omitempty"`
+	TurnaroundMs int64   `json:"turnaround_ms"`
+	CPUHours     float64 `json:"cpu_hours"`
+	PeakRSSBytes int64   `json:"peak_rss_bytes,omitempty"`
+}
+
+// summarizeRun builds the summary of a run and of every sample, samples
+// being the tags of per-sample processes as in "throughput"
+func summarizeRun(trace *Trace) postRun {
+	timestamp := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	run := postRun{Trace: trace.Path, Tasks: len(trace.Records), Samples: []postSample{}}
+	bySample := make(map[string]*postSample)
+	for _, timing := range collectSampleTimings(trace.Records) {
+		run.Samples = append(run.Samples, postSample{
+			Sample:       timing.Sample,
+			Done:         timing.Done,
+			Submit:       timestamp(timing.Submit),
+			Complete:     timestamp(timing.Complete),
+			TurnaroundMs: timing.Turnaround().Milliseconds(),
+		})
+	}
+	for i := range run.Samples {
+		bySample[run.Samples[i].Sample] = &run.Samples[i]
+	}
+
+	var first, last time.Time
+	var runtime time.Duration
+	for _, rec := range trace.Records {
+		failed := rec.Status != "" && !isSuccess(rec.Status)
+		cpuHours := float64(max(rec.CPUs, 1)) * rec.Runtime().Hours()
+		if failed {
+			run.Failed++
+		}
+		run.CPUHours += cpuHours
+		runtime, _ = addDurations(runtime, rec.Runtime())
+		if !rec.Submit.IsZero() && (first.IsZero() || rec.Submit.Before(first)) {
+			first = rec.Submit
+		}
+		if !rec.Start.IsZero() && rec.End().After(last) {
+			last = rec.End()
+		}
+		if s, ok := bySample[rec.Tag]; ok {
+			s.Tasks++
+			s.CPUHours += cpuHours
+			if failed {
+				s.Failed++
+			}
+			s.PeakRSSBytes = max(s.PeakRSSBytes, rec.PeakRSS)
+		}
+	}
+	run.Submit, run.Complete = timestamp(first), timestamp(last)
+	if !first.IsZero() && last.After(first) {
+		run.WallTimeMs = last.Sub(first).Milliseconds()
+	}
+	run.RuntimeMs = runtime.Milliseconds()
+	return run
+}
+
+// renderPayload renders the summary with a text/template, in which "json"
+// encodes a value as JSON, or as indented JSON without a template. The
+// result must be valid JSON.
+func renderPayload(run postRun, templatePath string) ([]byte, error) {
+	if templatePath == "" {
+		return json.MarshalIndent(run, "", "  ")
+	}
+	text, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template: %w", err)
+	}
+	funcs := template.FuncMap{"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	}}
+	tmpl, err := template.New("payload").Funcs(funcs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, run); err != nil {
+		return nil, fmt.Errorf("error rendering template: %w", err)
+	}
+	if !json.Valid(b.Bytes()) {
+		return nil, fmt.Errorf("template %s does not render valid JSON", templatePath)
+	}
+	return b.Bytes(), nil
+}
+
+// headerFlag collects the HTTP headers given with repeated -H flags
+type headerFlag struct {
+	header http.Header
+}
+
+func (f headerFlag) String() string {
+	return ""
+}
+
+func (f headerFlag) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected 'Name: value'")
+	}
+	f.header.Add(strings.TrimSpace(name), strings.TrimSpace(os.ExpandEnv(v)))
+	return nil
+}
+
+// runPost implements the "post" subcommand, sending a summary of a run and
+// its samples as JSON to a URL, such as the webhook of a LIMS
+func runPost(args []string) error {
+	fs := newFlagSet("post")
+	input := inputFlags(fs)
+	url := fs.String("url", "", "URL to post the summary to")
+	templatePath := fs.String("template", "", "text/template file rendering the JSON payload from the summary (default: the summary as JSON)")
+	header := headerFlag{http.Header{}}
+	fs.Var(header, "H", "HTTP header as 'Name: value', with $VARIABLES expanded, e.g. 'Authorization: Bearer $LIMS_TOKEN' (repeatable)")
+	dryRun := fs.Bool("dry-run", false, "Print the payload instead of posting it")
+	fs.Parse(args)
+
+	if *url == "" && !*dryRun {
+		return fmt.Errorf("--url is required")
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	payload, err := renderPayload(summarizeRun(trace), *templatePath)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Println(string(payload))
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, *url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error posting summary: %w", err)
+	}
+	req.Header = header.header
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting summary: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("error posting summary: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	slog.Info("posted run summary", "url", *url, "status", resp.Status, "bytes", len(payload))
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Precision of numbers in reports, set with the global flags; machine
+// outputs (cat, metrics, baselines) keep full precision
+var (
+	durationUnits   = 3 // units of durations shown, e.g. 2 for "1h 5m" instead of "1h 4m 31s"
+	percentDecimals = 1 // decimal places of percentages
+	costDecimals    = 2 // decimal places of costs and energy
+)
+
+// setPrecision validates and sets the precision of numbers in reports
+func setPrecision(units, percent, cost int) error {
+	if units < 1 || units > 3 {
+		return fmt.Errorf("invalid number of duration units %d (use 1 to 3)", units)
+	}
+	if percent < 0 || cost < 0 {
+		return fmt.Errorf("decimal places cannot be negative")
+	}
+	durationUnits, percentDecimals, costDecimals = units, percent, cost
+	return nil
+}
+
+// roundDuration rounds a duration of at least a second to the smallest
+// of the given number of units
+func roundDuration(d time.Duration, units int) time.Duration {
+	largest := time.Second
+	switch {
+	case d >= time.Hour-time.Second/2:
+		largest = time.Hour
+	case d >= time.Minute-time.Second/2:
+		largest = time.Minute
+	}
+	unit := largest
+	for i := 1; i < units && unit > time.Second; i++ {
+		unit /= 60
+	}
+	return d.Round(unit)
+}
+
+// FormatPercent renders a percentage with the configured decimal places
+func FormatPercent(value float64) string {
+	return strconv.FormatFloat(value, 'f', percentDecimals, 64) + "%"
+}
+
+// FormatSignedPercent renders a percentage change with an explicit sign
+func FormatSignedPercent(value float64) string {
+	if value >= 0 {
+		return "+" + FormatPercent(value)
+	}
+	return FormatPercent(value)
+}
+
+// FormatCost renders a cost or an amount of energy with the configured
+// decimal places
+func FormatCost(value float64) string {
+	return strconv.FormatFloat(value, 'f', costDecimals, 64)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// executorPreset holds defaults suited to an executor: the headroom of
+// memory and time requests, the increments time requests are rounded up to
+// and an indicative price of CPU and memory hours
+type executorPreset struct {
+	Headroom      float64
+	TimeIncrement time.Duration
+	Price         *price // nil if tasks are not charged for
+}
+
+// executorPresets are the presets selected with --profile. Batch schedulers
+// plan in coarse time slots and penalize long requests, so time is rounded
+// to 15 minutes on Slurm; spot instances are interrupted anyway and billed
+// by the second, so less headroom pays off on AWS Batch.
+var executorPresets = map[string]executorPreset{
+	"slurm-hpc":     {Headroom: 25, TimeIncrement: 15 * time.Minute, Price: &price{CPUHour: 0.02, GBHour: 0.003}},
+	"awsbatch-spot": {Headroom: 10, TimeIncrement: 5 * time.Minute, Price: &price{CPUHour: 0.012, GBHour: 0.0015}},
+	"k8s":           {Headroom: 15, TimeIncrement: 5 * time.Minute, Price: &price{CPUHour: 0.035, GBHour: 0.004}},
+	"local":         {Headroom: 10, TimeIncrement: time.Minute},
+}
+
+// presetNames returns the names of the presets in alphabetical order
+func presetNames() []string {
+	names := make([]string, 0, len(executorPresets))
+	for name := range executorPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// presetFlag registers the --profile flag selecting an executor preset
+func presetFlag(fs *flag.FlagSet) *string {
+	return fs.String("profile", "", "Executor preset of defaults: "+strings.Join(presetNames(), ", "))
+}
+
+// lookupPreset returns the preset of the given name, nil for none
+func lookupPreset(name string) (*executorPreset, error) {
+	if name == "" {
+		return nil, nil
+	}
+	preset, ok := executorPresets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile '%s' (use %s)", name, strings.Join(presetNames(), ", "))
+	}
+	return &preset, nil
+}
+
+// flagSet reports whether a flag was given on the command line, so that
+// presets only change the defaults of flags not given
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
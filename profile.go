@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Value types detected by "profile", from the most to the least specific
+const (
+	typeInteger   = "integer"
+	typeNumber    = "number"
+	typePercent   = "percent"
+	typeDuration  = "duration"
+	typeSize      = "size"
+	typeTimestamp = "timestamp"
+	typeText      = "text"
+)
+
+// hasUnit matches values consisting of a number followed by a unit
+var hasUnit = regexp.MustCompile(`^` + numberPattern + `\s*[a-zA-Zµ]+(\s+` + numberPattern + `\s*[a-zA-Zµ]+)*$`)
+
+// detectValue returns the type of a raw trace value and its numeric value
+// used for ordering; text values have no numeric value
+func detectValue(value string) (string, float64, bool) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return typeInteger, float64(n), true
+	}
+	if strings.HasSuffix(value, "%") {
+		if n, err := parseNumber(strings.TrimSpace(strings.TrimSuffix(value, "%"))); err == nil {
+			return typePercent, n, true
+		}
+	}
+	if hasUnit.MatchString(value) {
+		if d, err := ParseDuration(value); err == nil {
+			return typeDuration, float64(d), true
+		}
+		if size, err := ParseSize(value); err == nil {
+			return typeSize, float64(size), true
+		}
+	}
+	if ts, err := ParseTimestamp(value); err == nil {
+		return typeTimestamp, float64(ts.UnixNano()), true
+	}
+	if n, err := parseNumber(value); err == nil {
+		return typeNumber, n, true
+	}
+	return typeText, 0, false
+}
+
+// columnProfile summarizes the values of a trace column
+type columnProfile struct {
+	Column   string
+	Values   int
+	Missing  int
+	Types    map[string]int
+	Distinct map[string]bool
+	Examples []string
+	Min, Max string
+	min, max float64
+}
+
+// Type returns the detected type of the column; integers mixed with
+// decimals are numbers, other mixtures are listed by frequency
+func (p *columnProfile) Type() string {
+	if len(p.Types) == 0 {
+		return "-"
+	}
+	if len(p.Types) == 2 && p.Types[typeInteger] > 0 && p.Types[typeNumber] > 0 {
+		return typeNumber
+	}
+	if len(p.Types) == 1 {
+		for t := range p.Types {
+			return t
+		}
+	}
+	types := make([]string, 0, len(p.Types))
+	for t := range p.Types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if p.Types[types[i]] != p.Types[types[j]] {
+			return p.Types[types[i]] > p.Types[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	return "mixed (" + strings.Join(types, ", ") + ")"
+}
+
+// add records one raw value of the column
+func (p *columnProfile) add(value string) {
+	value = strings.TrimSpace(value)
+	p.Values++
+	if value == "" || value == "-" {
+		p.Missing++
+		return
+	}
+
+	kind, n, numeric := detectValue(value)
+	p.Types[kind]++
+	if !p.Distinct[value] {
+		p.Distinct[value] = true
+		if len(p.Examples) < 3 {
+			p.Examples = append(p.Examples, value)
+		}
+	}
+
+	// Order numeric values by their parsed value and text lexicographically
+	switch {
+	case p.Min == "":
+		p.Min, p.Max, p.min, p.max = value, value, n, n
+	case numeric:
+		if n < p.min {
+			p.Min, p.min = value, n
+		}
+		if n > p.max {
+			p.Max, p.max = value, n
+		}
+	default:
+		if value < p.Min {
+			p.Min = value
+		}
+		if value > p.Max {
+			p.Max = value
+		}
+	}
+}
+
+// profileColumns profiles every column of the trace in header order
+func profileColumns(trace *Trace) []*columnProfile {
+	profiles := make([]*columnProfile, len(trace.Columns))
+	for i, col := range trace.Columns {
+		profiles[i] = &columnProfile{Column: col, Types: make(map[string]int), Distinct: make(map[string]bool)}
+	}
+	for _, rec := range trace.Records {
+		for i, profile := range profiles {
+			value := ""
+			if i < len(rec.Fields) {
+				value = rec.Fields[i]
+			}
+			profile.add(value)
+		}
+	}
+	return profiles
+}
+
+// runProfile implements the "profile" subcommand, summarizing type, fill
+// rate and value range of every column
+func runProfile(args []string) error {
+	fs := newFlagSet("profile")
+	input := inputFlags(fs)
+	fs.Parse(args)
+
+	input.Read.KeepFields = true
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d rows, %d columns\n\n", trace.Path, len(trace.Records), len(trace.Columns))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COLUMN\tTYPE\tFILLED\tDISTINCT\tMIN\tMAX\tEXAMPLES")
+	for _, p := range profileColumns(trace) {
+		filled := "-"
+		if p.Values > 0 {
+			filled = FormatPercent(100 * float64(p.Values-p.Missing) / float64(p.Values))
+		}
+		min, max := p.Min, p.Max
+		if min == "" {
+			min, max = "-", "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", p.Column, p.Type(), filled, len(p.Distinct),
+			min, max, strings.Join(p.Examples, " | "))
+	}
+	return w.Flush()
+}
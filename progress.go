@@ -0,0 +1,74 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// progressStep describes a run between two levels of relative progress,
+// measured as the share of tasks completed
+type progressStep struct {
+	Elapsed     time.Duration // time from the first submission to the end of the step
+	Tasks       int           // tasks completed during the step
+	Concurrency float64       // mean number of running tasks
+	Throughput  float64       // tasks completed per hour
+	Process     string        // process with the most runtime during the step
+}
+
+// progressCurve divides a run into steps of equal relative progress, so that
+// runs of different size or speed can be compared phase by phase rather than
+// at the same wall time
+func progressCurve(records []TraceRecord, steps int) []progressStep {
+	var tasks []TraceRecord
+	var runStart time.Time
+	for _, rec := range records {
+		if rec.Start.IsZero() || rec.Runtime() <= 0 {
+			continue
+		}
+		tasks = append(tasks, rec)
+		first := rec.Start
+		if !rec.Submit.IsZero() && rec.Submit.Before(first) {
+			first = rec.Submit
+		}
+		if runStart.IsZero() || first.Before(runStart) {
+			runStart = first
+		}
+	}
+	if len(tasks) == 0 || steps <= 0 {
+		return nil
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].End().Before(tasks[j].End()) })
+
+	curve := make([]progressStep, steps)
+	from, done := runStart, 0
+	for i := range curve {
+		// The step ends when its share of the tasks has completed
+		last := (len(tasks)*(i+1)+steps-1)/steps - 1
+		to := tasks[last].End()
+
+		step := &curve[i]
+		step.Elapsed = to.Sub(runStart)
+		step.Tasks = last + 1 - done
+		length := to.Sub(from)
+
+		busy := make(map[string]time.Duration)
+		var running time.Duration
+		for _, rec := range tasks {
+			d := overlap(rec.Start, rec.End(), from, to)
+			running += d
+			busy[rec.Process] += d
+		}
+		if length > 0 {
+			step.Concurrency = running.Seconds() / length.Seconds()
+			step.Throughput = float64(step.Tasks) / length.Hours()
+		}
+		for process, d := range busy {
+			if d > busy[step.Process] || (d == busy[step.Process] && process < step.Process) {
+				step.Process = process
+			}
+		}
+
+		from, done = to, last+1
+	}
+	return curve
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// resourceRecommendation holds the requested and suggested resources of a
+// process
+type resourceRecommendation struct {
+	Process string
+	Tasks   int    // successful tasks the suggestion is based on
+	Queue   string // queue or partition of the last successful task
+
+	// Partition whose maximum the time was capped to, empty if not capped
+	TimeCappedBy string
+
+	// Anomalous tasks: implausible %cpu, timestamps out of order or run
+	// during a known incident
+	Anomalous int
+
+	// Why the suggestion is of low confidence, empty if it is not
+	LowConfidence []string
+
+	RequestedCPUs   int
+	RequestedMemory int64
+	RequestedTime   time.Duration
+
+	CPUs   int           // 0 if no %cpu was recorded
+	Memory int64         // 0 if no peak RSS was recorded
+	Time   time.Duration // 0 if no runtime was recorded
+
+	// The usage the suggestions are based on
+	CPUEvidence, MemoryEvidence, TimeEvidence usageEvidence
+}
+
+// usageEvidence summarizes the observed usage of a resource by the
+// successful tasks of a process, in CPUs, bytes or nanoseconds
+type usageEvidence struct {
+	Samples  int
+	Observed float64 // at the percentile the suggestion is based on
+	P95, Max float64
+	Failures int // attempts failed for lack of the resource
+}
+
+// newUsageEvidence summarizes the usage values at percentile p
+func newUsageEvidence(values []float64, p float64, failures int) usageEvidence {
+	e := usageEvidence{Samples: len(values), Failures: failures}
+	if len(values) > 0 {
+		e.Observed, e.P95, e.Max = percentile(values, p), percentile(values, 95), percentile(values, 100)
+	}
+	return e
+}
+
+// isTimeout reports whether a task was killed for exceeding its time limit:
+// exit code 140 (SIGUSR2 of SGE and LSF) or a WALLTIME status derived by
+// --status-rules
+func isTimeout(rec TraceRecord) bool {
+	return rec.Status == "WALLTIME" || (rec.Exit == "140" && !isSuccess(rec.Status))
+}
+
+// recommendResources suggests the cpus, memory and time of every process
+// from the given percentile of the usage of its successful tasks. Memory and
+// time get headroom percent on top, as tasks exceeding them fail; CPUs are
+// rounded up to whole CPUs and time to whole timeIncrements.
+func recommendResources(records []TraceRecord, p, headroom float64, timeIncrement time.Duration) []*resourceRecommendation {
+	type usage struct {
+		rec                  *resourceRecommendation
+		cpu, memory, runtime []float64
+		oomKills, timeouts   int
+	}
+	var order []*usage
+	byProcess := make(map[string]*usage)
+	for _, rec := range records {
+		u, ok := byProcess[rec.Process]
+		if !ok {
+			u = &usage{rec: &resourceRecommendation{Process: rec.Process}}
+			byProcess[rec.Process] = u
+			order = append(order, u)
+		}
+		if rec.Status != "" && !isSuccess(rec.Status) {
+			if isOOMKill(rec) {
+				u.oomKills++
+			}
+			if isTimeout(rec) {
+				u.timeouts++
+			}
+			continue
+		}
+		r := u.rec
+		r.Tasks++
+		if rec.Queue != "" {
+			r.Queue = rec.Queue
+		}
+		if rec.CPUSuspect != "" || rec.ClockSkew != "" || rec.Incident != "" {
+			r.Anomalous++
+		}
+		r.RequestedCPUs = max(r.RequestedCPUs, rec.CPUs)
+		r.RequestedMemory = max(r.RequestedMemory, rec.Memory)
+		r.RequestedTime = max(r.RequestedTime, rec.Time)
+		if rec.HasCPUPercent && rec.CPUSuspect == "" {
+			u.cpu = append(u.cpu, rec.CPUPercent)
+		}
+		if rec.PeakRSS > 0 {
+			u.memory = append(u.memory, float64(rec.PeakRSS))
+		}
+		if rec.Runtime() > 0 {
+			u.runtime = append(u.runtime, float64(rec.Runtime()))
+		}
+	}
+
+	factor := 1 + headroom/100
+	var recommendations []*resourceRecommendation
+	for _, u := range order {
+		r := u.rec
+		if r.Tasks == 0 {
+			continue
+		}
+		for i := range u.cpu {
+			u.cpu[i] /= 100
+		}
+		r.CPUEvidence = newUsageEvidence(u.cpu, p, 0)
+		r.MemoryEvidence = newUsageEvidence(u.memory, p, u.oomKills)
+		r.TimeEvidence = newUsageEvidence(u.runtime, p, u.timeouts)
+		if len(u.cpu) > 0 {
+			r.CPUs = max(int(math.Ceil(percentile(u.cpu, p))), 1)
+		}
+		if len(u.memory) > 0 {
+			r.Memory = roundMemory(percentile(u.memory, p) * factor)
+		}
+		if len(u.runtime) > 0 {
+			r.Time = roundTime(percentile(u.runtime, p)*factor, timeIncrement)
+		}
+		recommendations = append(recommendations, r)
+	}
+	return recommendations
+}
+
+// gateConfidence marks recommendations based on fewer than minTasks tasks,
+// on more than maxAnomalous percent of anomalous tasks or on a trace with
+// runReasons, such as a truncated trace, as of low confidence
+func gateConfidence(recommendations []*resourceRecommendation, minTasks int, maxAnomalous float64, runReasons []string) {
+	for _, r := range recommendations {
+		r.LowConfidence = append([]string(nil), runReasons...)
+		if r.Tasks < minTasks {
+			r.LowConfidence = append(r.LowConfidence, fmt.Sprintf("%d tasks, fewer than %d", r.Tasks, minTasks))
+		}
+		if r.Anomalous > 0 && 100*float64(r.Anomalous) > maxAnomalous*float64(r.Tasks) {
+			r.LowConfidence = append(r.LowConfidence, fmt.Sprintf("%d of %d tasks anomalous", r.Anomalous, r.Tasks))
+		}
+	}
+}
+
+// confidentOnly returns the recommendations not of low confidence
+func confidentOnly(recommendations []*resourceRecommendation) []*resourceRecommendation {
+	var confident []*resourceRecommendation
+	for _, r := range recommendations {
+		if len(r.LowConfidence) == 0 {
+			confident = append(confident, r)
+		}
+	}
+	return confident
+}
+
+// loadPartitionLimits reads a YAML mapping of partitions, or queues, to
+// their maximum time, e.g.
+//
+//	short: {max_time: 4h}
+//	long:
+//	  max_time: 7d
+//	default: {max_time: 2d}
+//
+// The default entry, if any, applies to tasks of other or unknown queues.
+func loadPartitionLimits(filePath string) (map[string]time.Duration, error) {
+	mappings, err := loadYAMLMappings(filePath, "partitions")
+	if err != nil {
+		return nil, err
+	}
+	limits := make(map[string]time.Duration)
+	for _, m := range mappings {
+		for _, e := range m.Entries {
+			if e.Key != "max_time" {
+				return nil, fmt.Errorf("partitions file line %d: unknown limit '%s' (use max_time)", e.Line, e.Key)
+			}
+			limit, err := ParseDuration(e.Value)
+			if err == nil && limit <= 0 {
+				err = fmt.Errorf("not positive")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("partitions file line %d: invalid max_time '%s': %w", e.Line, e.Value, err)
+			}
+			limits[m.Name] = limit
+		}
+	}
+	return limits, nil
+}
+
+// capTimes caps the time of every recommendation to the maximum of the
+// partition its tasks ran in, so that the configuration can be submitted
+func capTimes(recommendations []*resourceRecommendation, limits map[string]time.Duration) {
+	for _, r := range recommendations {
+		capTime(r, limits)
+	}
+}
+
+// capTime caps the time of a recommendation to the maximum of its partition
+func capTime(r *resourceRecommendation, limits map[string]time.Duration) {
+	partition := r.Queue
+	limit, ok := limits[partition]
+	if !ok || partition == "" {
+		partition = "default"
+		limit, ok = limits[partition]
+	}
+	r.TimeCappedBy = ""
+	if ok && r.Time > limit {
+		r.Time, r.TimeCappedBy = limit, partition
+	}
+}
+
+// timeCapNote describes why the time of a recommendation was capped, empty
+// if it was not
+func timeCapNote(r *resourceRecommendation) string {
+	if r.TimeCappedBy == "" {
+		return ""
+	}
+	return fmt.Sprintf("time capped at the maximum of partition %s, tasks may need more", r.TimeCappedBy)
+}
+
+// roundMemory rounds a memory request up to whole GB, or to 100 MB below
+// a GB
+func roundMemory(bytes float64) int64 {
+	if bytes >= 1<<30 {
+		return int64(math.Ceil(bytes/(1<<30))) << 30
+	}
+	return max(int64(math.Ceil(bytes/(100<<20))), 1) * (100 << 20)
+}
+
+// roundTime rounds a time request up to whole increments, e.g. minutes
+func roundTime(ns float64, increment time.Duration) time.Duration {
+	return max(time.Duration(math.Ceil(ns/float64(increment))), 1) * increment
+}
+
+// configMemory renders a memory request as in a Nextflow configuration
+func configMemory(bytes int64) string {
+	if bytes >= 1<<30 && bytes%(1<<30) == 0 {
+		return fmt.Sprintf("%d GB", bytes>>30)
+	}
+	return fmt.Sprintf("%d MB", bytes>>20)
+}
+
+// configTime renders a time request as in a Nextflow configuration, down
+// to seconds so that it is never below the request, e.g. "1h 30m" or "1m 30s"
+func configTime(d time.Duration) string {
+	d = d.Round(time.Second)
+	var parts []string
+	for _, unit := range []struct {
+		length time.Duration
+		suffix string
+	}{{time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}} {
+		if n := d / unit.length; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", n, unit.suffix))
+			d -= n * unit.length
+		}
+	}
+	if len(parts) == 0 {
+		return "0s"
+	}
+	return strings.Join(parts, " ")
+}
+
+// printResourceConfig prints the recommendations as process selectors of a
+// Nextflow configuration
+func printResourceConfig(recommendations []*resourceRecommendation, p, headroom float64) {
+	shortNames := make(map[string]int)
+	for _, r := range recommendations {
+		shortNames[shortProcessName(r.Process)]++
+	}
+	fmt.Printf("// Recommended by nfu recommend: p%g of observed usage, %g%% headroom on memory and time\n", p, headroom)
+	fmt.Println("process {")
+	for _, r := range recommendations {
+		if r.CPUs == 0 && r.Memory == 0 && r.Time == 0 {
+			continue
+		}
+		// Short names select the process wherever it is included, unless
+		// processes of several workflows share it
+		name := shortProcessName(r.Process)
+		if shortNames[name] > 1 {
+			name = r.Process
+		}
+		if len(r.LowConfidence) > 0 {
+			fmt.Printf("    // low confidence: %s\n", strings.Join(r.LowConfidence, ", "))
+		}
+		if note := timeCapNote(r); note != "" && r.Time > 0 {
+			fmt.Printf("    // %s\n", note)
+		}
+		fmt.Printf("    withName: '%s' {\n", name)
+		if r.CPUs > 0 {
+			fmt.Printf("        cpus   = %d\n", r.CPUs)
+		}
+		if r.Memory > 0 {
+			fmt.Printf("        memory = '%s'\n", configMemory(r.Memory))
+		}
+		if r.Time > 0 {
+			fmt.Printf("        time   = '%s'\n", configTime(r.Time))
+		}
+		fmt.Println("    }")
+	}
+	fmt.Println("}")
+}
+
+// evidenceRecord is the usage a recommendation is based on, in the unit
+// given
+type evidenceRecord struct {
+	Samples         int     `json:"samples"`
+	Percentile      float64 `json:"percentile"`
+	Observed        float64 `json:"observed"`
+	P95             float64 `json:"p95"`
+	Max             float64 `json:"max"`
+	Unit            string  `json:"unit"`
+	HeadroomPercent float64 `json:"headroom_percent"`
+	Failures        int     `json:"failures"`
+	Anomalous       int     `json:"anomalous"`
+	// Why the recommendation is of low confidence
+	LowConfidence []string `json:"low_confidence,omitempty"`
+}
+
+// recommendationRecord is a recommended directive in the machine-readable
+// output formats, one per process and resource
+type recommendationRecord struct {
+	Process     string         `json:"process"`
+	Resource    string         `json:"resource"`
+	Current     string         `json:"current"`
+	Recommended string         `json:"recommended"`
+	Confidence  string         `json:"confidence"` // high or low
+	Note        string         `json:"note,omitempty"`
+	Evidence    evidenceRecord `json:"evidence"`
+}
+
+// recommendationRecords returns the records of the recommendations; memory
+// is in bytes and time in milliseconds
+func recommendationRecords(recommendations []*resourceRecommendation, p, headroom float64) []recommendationRecord {
+	var records []recommendationRecord
+	add := func(r *resourceRecommendation, resource, current, recommended string, e usageEvidence, unit string, scale, headroom float64) {
+		confidence := "high"
+		if len(r.LowConfidence) > 0 {
+			confidence = "low"
+		}
+		note := ""
+		if resource == "time" {
+			note = timeCapNote(r)
+		}
+		records = append(records, recommendationRecord{
+			Process:     r.Process,
+			Resource:    resource,
+			Current:     current,
+			Recommended: recommended,
+			Confidence:  confidence,
+			Note:        note,
+			Evidence: evidenceRecord{
+				Samples:         e.Samples,
+				Percentile:      p,
+				Observed:        e.Observed / scale,
+				P95:             e.P95 / scale,
+				Max:             e.Max / scale,
+				Unit:            unit,
+				HeadroomPercent: headroom,
+				Failures:        e.Failures,
+				Anomalous:       r.Anomalous,
+				LowConfidence:   r.LowConfidence,
+			},
+		})
+	}
+	for _, r := range recommendations {
+		if r.CPUs > 0 {
+			current := ""
+			if r.RequestedCPUs > 0 {
+				current = strconv.Itoa(r.RequestedCPUs)
+			}
+			add(r, "cpus", current, strconv.Itoa(r.CPUs), r.CPUEvidence, "cpus", 1, 0)
+		}
+		if r.Memory > 0 {
+			current := ""
+			if r.RequestedMemory > 0 {
+				current = configMemory(r.RequestedMemory)
+			}
+			add(r, "memory", current, configMemory(r.Memory), r.MemoryEvidence, "bytes", 1, headroom)
+		}
+		if r.Time > 0 {
+			current := ""
+			if r.RequestedTime > 0 {
+				current = configTime(r.RequestedTime)
+			}
+			add(r, "time", current, configTime(r.Time), r.TimeEvidence, "ms", float64(time.Millisecond), headroom)
+		}
+	}
+	return records
+}
+
+// describeEvidence renders the usage a recommendation of a resource,
+// "cpus", "memory" or "time", is based on
+func describeEvidence(resource string, e usageEvidence, p float64) string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 1, 64) }
+	failures := ""
+	switch resource {
+	case "memory":
+		format = func(v float64) string { return FormatSize(int64(v)) }
+		failures = "killed for exceeding their memory"
+	case "time":
+		format = func(v float64) string { return FormatDuration(time.Duration(v)) }
+		failures = "killed for exceeding their time"
+	}
+	s := fmt.Sprintf("p%g %s, p95 %s, max %s of %d tasks", p, format(e.Observed), format(e.P95), format(e.Max), e.Samples)
+	if e.Failures > 0 {
+		s += fmt.Sprintf(", %d attempts %s", e.Failures, failures)
+	}
+	return s
+}
+
+// printEvidence prints the usage every recommendation is based on
+func printEvidence(recommendations []*resourceRecommendation, p float64) {
+	fmt.Println("\nEvidence (usage of successful tasks):")
+	for _, r := range recommendations {
+		name := shortProcessName(r.Process)
+		if r.CPUs > 0 {
+			fmt.Printf("  %s cpus: %s\n", name, describeEvidence("cpus", r.CPUEvidence, p))
+		}
+		if r.Memory > 0 {
+			fmt.Printf("  %s memory: %s\n", name, describeEvidence("memory", r.MemoryEvidence, p))
+		}
+		if r.Time > 0 {
+			fmt.Printf("  %s time: %s\n", name, describeEvidence("time", r.TimeEvidence, p))
+		}
+	}
+}
+
+// runRecommend implements the "recommend" subcommand, suggesting cpus,
+// memory and time directives per process from the observed usage
+func runRecommend(args []string) error {
+	fs := newFlagSet("recommend")
+	input := inputFlags(fs)
+	p := fs.Float64("percentile", 100, "Percentile of the observed usage to size requests for, 100 for the maximum")
+	headroom := fs.Float64("headroom", 20, "Headroom in percent added to the memory and time observed")
+	config := fs.Bool("config", false, "Print the recommendations as directives of a Nextflow configuration")
+	evidence := fs.Bool("evidence", false, "Print the usage every recommendation is based on")
+	minTasks := fs.Int("min-tasks", 5, "Successful tasks below which a recommendation is of low confidence")
+	maxAnomalous := fs.Float64("max-anomalous", 10, "Percentage of anomalous tasks (implausible %cpu, clock skew, incidents) above which a recommendation is of low confidence")
+	lowConfidence := fs.String("low-confidence", "mark", "Handling of low-confidence recommendations: mark or suppress")
+	review := fs.Bool("review", false, "Review every change interactively and print the accepted ones as a Nextflow configuration")
+	timeIncrement := fs.Duration("time-increment", time.Minute, "Increment time requests are rounded up to, e.g. 15m or 1h to fit the slots of the scheduler")
+	partitions := fs.String("partitions", "", "YAML file of the maximum time of every partition or queue (e.g. short: {max_time: 4h}) time requests are capped to")
+	profile := presetFlag(fs)
+	fs.Parse(args)
+
+	preset, err := lookupPreset(*profile)
+	if err != nil {
+		return err
+	}
+	if preset != nil {
+		if !flagSet(fs, "headroom") {
+			*headroom = preset.Headroom
+		}
+		if !flagSet(fs, "time-increment") {
+			*timeIncrement = preset.TimeIncrement
+		}
+	}
+	if *timeIncrement <= 0 {
+		return fmt.Errorf("time increment must be positive")
+	}
+	var limits map[string]time.Duration
+	if *partitions != "" {
+		if limits, err = loadPartitionLimits(*partitions); err != nil {
+			return err
+		}
+	}
+
+	if *p <= 0 || *p > 100 {
+		return fmt.Errorf("percentile must be between 0 and 100")
+	}
+	if *headroom < 0 {
+		return fmt.Errorf("headroom cannot be negative")
+	}
+	if *lowConfidence != "mark" && *lowConfidence != "suppress" {
+		return fmt.Errorf("unknown low-confidence handling '%s' (use mark or suppress)", *lowConfidence)
+	}
+	if *review && slices.Contains(input.Paths, "-") {
+		return fmt.Errorf("answers of --review are read from stdin, give the trace as a file")
+	}
+	if *review && outputFormat != "text" {
+		return fmt.Errorf("--review writes a Nextflow configuration, not %s", outputFormat)
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	recommendations := recommendResources(trace.Records, *p, *headroom, *timeIncrement)
+	capTimes(recommendations, limits)
+	if len(recommendations) == 0 {
+		return fmt.Errorf("no successful tasks to base recommendations on")
+	}
+	// Usage of a run that did not finish may not be representative
+	var runReasons []string
+	if trace.Truncated {
+		runReasons = append(runReasons, "trace truncated")
+	}
+	if incomplete := len(trace.IncompleteTasks()); incomplete > 0 {
+		runReasons = append(runReasons, fmt.Sprintf("%d tasks of the run incomplete", incomplete))
+	}
+	gateConfidence(recommendations, *minTasks, *maxAnomalous, runReasons)
+	if *lowConfidence == "suppress" {
+		if suppressed := len(recommendations) - len(confidentOnly(recommendations)); suppressed > 0 {
+			slog.Warn("suppressed low-confidence recommendations", "processes", suppressed)
+		}
+		if recommendations = confidentOnly(recommendations); len(recommendations) == 0 {
+			return fmt.Errorf("no recommendations of sufficient confidence")
+		}
+	}
+	if outputFormat != "text" {
+		return writeRecords(os.Stdout, recommendationRecords(recommendations, *p, *headroom))
+	}
+	if *review {
+		// Prompts go to stderr, so that stdout is the configuration only
+		if err := reviewRecommendations(os.Stdin, os.Stderr, recommendations, *p, *timeIncrement, limits); err != nil {
+			return fmt.Errorf("error reading answers: %w", err)
+		}
+		printResourceConfig(recommendations, *p, *headroom)
+		return nil
+	}
+	if *config {
+		printResourceConfig(recommendations, *p, *headroom)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tCPUS\tMEMORY\tTIME")
+	for _, r := range recommendations {
+		requestedCPUs, requestedMemory, requestedTime := "-", "-", "-"
+		cpus, memory, limit := "-", "-", "-"
+		if r.RequestedCPUs > 0 {
+			requestedCPUs = strconv.Itoa(r.RequestedCPUs)
+		}
+		if r.RequestedMemory > 0 {
+			requestedMemory = FormatSize(r.RequestedMemory)
+		}
+		if r.RequestedTime > 0 {
+			requestedTime = FormatDuration(r.RequestedTime)
+		}
+		if r.CPUs > 0 {
+			cpus = strconv.Itoa(r.CPUs)
+		}
+		if r.Memory > 0 {
+			memory = configMemory(r.Memory)
+		}
+		if r.Time > 0 {
+			limit = configTime(r.Time)
+		}
+		tasks := strconv.Itoa(r.Tasks)
+		if len(r.LowConfidence) > 0 {
+			tasks += "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s -> %s\t%s -> %s\t%s -> %s\n", r.Process, tasks, requestedCPUs, cpus,
+			requestedMemory, memory, requestedTime, limit)
+	}
+	w.Flush()
+	fmt.Printf("\nRequested -> recommended, from p%g of the usage of successful tasks with %g%% headroom on memory and time\n", *p, *headroom)
+	if low := len(recommendations) - len(confidentOnly(recommendations)); low > 0 {
+		fmt.Printf("\n* Low confidence (%d):\n", low)
+		for _, r := range recommendations {
+			if len(r.LowConfidence) > 0 {
+				fmt.Printf("  %s: %s\n", r.Process, strings.Join(r.LowConfidence, ", "))
+			}
+		}
+	}
+	first := true
+	for _, r := range recommendations {
+		if note := timeCapNote(r); note != "" {
+			if first {
+				fmt.Println()
+				first = false
+			}
+			fmt.Printf("%s: %s\n", r.Process, note)
+		}
+	}
+	if *evidence {
+		printEvidence(recommendations, *p)
+	}
+	return nil
+}
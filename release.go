@@ -0,0 +1,308 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultReleaseTargets are the platforms release binaries are built for;
+// bioconda builds for Linux and macOS, Windows is included for completeness
+var defaultReleaseTargets = "linux/amd64,linux/arm64,darwin/amd64,darwin/arm64,windows/amd64"
+
+// releaseExtraFiles are shipped in every release archive next to the binary
+var releaseExtraFiles = []string{"README.md", "LICENSE"}
+
+// releaseLdflags returns the linker flags embedding the build metadata; the
+// build ID is cleared so that identical sources yield identical binaries
+func releaseLdflags(version, commit, date string) string {
+	return fmt.Sprintf("-s -w -buildid= -X main.version=%s -X main.commit=%s -X main.buildDate=%s", version, commit, date)
+}
+
+// parseTargets splits a comma-separated list of os/arch pairs
+func parseTargets(targets string) ([][2]string, error) {
+	var result [][2]string
+	for _, target := range strings.Split(targets, ",") {
+		goos, goarch, ok := strings.Cut(strings.TrimSpace(target), "/")
+		if !ok || goos == "" || goarch == "" {
+			return nil, fmt.Errorf("invalid target '%s' (expected os/arch)", target)
+		}
+		result = append(result, [2]string{goos, goarch})
+	}
+	return result, nil
+}
+
+// goreleaserConfig renders a GoReleaser configuration producing the same
+// archives and checksums as "nfu release build"
+func goreleaserConfig(targets [][2]string) string {
+	var b strings.Builder
+	b.WriteString("# Generated by `nfu release config`\n")
+	b.WriteString("version: 2\n")
+	b.WriteString("project_name: nfu\n\n")
+	b.WriteString("builds:\n")
+	b.WriteString("  - id: nfu\n")
+	b.WriteString("    main: .\n")
+	b.WriteString("    binary: nfu\n")
+	b.WriteString("    env:\n")
+	b.WriteString("      - CGO_ENABLED=0\n")
+	b.WriteString("    flags:\n")
+	b.WriteString("      - -trimpath\n")
+	b.WriteString("    ldflags:\n")
+	fmt.Fprintf(&b, "      - %s\n", releaseLdflags("{{ .Version }}", "{{ .FullCommit }}", "{{ .CommitDate }}"))
+	b.WriteString("    # Use the commit time for file timestamps to keep builds reproducible\n")
+	b.WriteString("    mod_timestamp: \"{{ .CommitTimestamp }}\"\n")
+	b.WriteString("    targets:\n")
+	for _, target := range targets {
+		fmt.Fprintf(&b, "      - %s_%s\n", target[0], target[1])
+	}
+	b.WriteString("\narchives:\n")
+	b.WriteString("  - name_template: \"{{ .ProjectName }}_{{ .Version }}_{{ .Os }}_{{ .Arch }}\"\n")
+	b.WriteString("    formats: [tar.gz]\n")
+	b.WriteString("    format_overrides:\n")
+	b.WriteString("      - goos: windows\n")
+	b.WriteString("        formats: [zip]\n")
+	b.WriteString("    files:\n")
+	for _, file := range releaseExtraFiles {
+		fmt.Fprintf(&b, "      - %s\n", file)
+	}
+	b.WriteString("\nchecksum:\n")
+	fmt.Fprintf(&b, "  name_template: %s\n", checksumsAsset)
+	b.WriteString("  algorithm: sha256\n")
+	return b.String()
+}
+
+// gitOutput runs a git command and returns its trimmed output
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("error running git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// sourceDate returns the timestamp used for build metadata and archive
+// entries: SOURCE_DATE_EPOCH if set, otherwise the time of the last commit
+func sourceDate() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		var err error
+		if epoch, err = gitOutput("log", "-1", "--format=%ct"); err != nil {
+			return time.Time{}, err
+		}
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid source date epoch '%s'", epoch)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// writeTarGz packs files into a gzip-compressed tarball with fixed
+// timestamps and ownership
+func writeTarGz(path string, files map[string]string, mtime time.Time) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	gz.ModTime = mtime
+	tw := tar.NewWriter(gz)
+	for _, name := range sortedKeys(files) {
+		data, err := os.ReadFile(files[name])
+		if err != nil {
+			return err
+		}
+		mode := int64(0o644)
+		if name == "nfu" {
+			mode = 0o755
+		}
+		hdr := &tar.Header{Name: name, Mode: mode, Size: int64(len(data)), ModTime: mtime, Format: tar.FormatPAX}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// writeZip packs files into a zip archive with fixed timestamps
+func writeZip(path string, files map[string]string, mtime time.Time) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	for _, name := range sortedKeys(files) {
+		data, err := os.ReadFile(files[name])
+		if err != nil {
+			return err
+		}
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: mtime}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 digest of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildRelease cross-compiles nfu for all targets, packs the archives and
+// writes the checksums file
+func buildRelease(version, outDir string, targets [][2]string) error {
+	commit, err := gitOutput("rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	date, err := sourceDate()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	ldflags := releaseLdflags(version, commit, date.Format(time.RFC3339))
+	var checksums []string
+	for _, target := range targets {
+		goos, goarch := target[0], target[1]
+		name := fmt.Sprintf("nfu_%s_%s_%s", version, goos, goarch)
+		binary := "nfu"
+		if goos == "windows" {
+			binary = "nfu.exe"
+		}
+
+		buildDir := filepath.Join(outDir, name)
+		cmd := exec.Command("go", "build", "-trimpath", "-ldflags", ldflags, "-o", filepath.Join(buildDir, binary), ".")
+		cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS="+goos, "GOARCH="+goarch)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		slog.Info("building", "target", goos+"/"+goarch)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("error building %s/%s: %w", goos, goarch, err)
+		}
+
+		files := map[string]string{binary: filepath.Join(buildDir, binary)}
+		for _, extra := range releaseExtraFiles {
+			files[extra] = extra
+		}
+		archive := name + ".tar.gz"
+		if goos == "windows" {
+			archive = name + ".zip"
+			err = writeZip(filepath.Join(outDir, archive), files, date)
+		} else {
+			err = writeTarGz(filepath.Join(outDir, archive), files, date)
+		}
+		if err != nil {
+			return fmt.Errorf("error writing %s: %w", archive, err)
+		}
+
+		sum, err := fileSHA256(filepath.Join(outDir, archive))
+		if err != nil {
+			return err
+		}
+		checksums = append(checksums, fmt.Sprintf("%s  %s\n", sum, archive))
+	}
+
+	return os.WriteFile(filepath.Join(outDir, checksumsAsset), []byte(strings.Join(checksums, "")), 0o644)
+}
+
+// runRelease implements the "release" subcommand with its "config" and
+// "build" actions
+func runRelease(args []string) error {
+	fs := newFlagSet("release")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu release config [-o .goreleaser.yaml]")
+		fmt.Fprintln(fs.Output(), "       nfu release build --version X.Y.Z [-o dist]")
+		fs.PrintDefaults()
+	}
+	targetsFlag := fs.String("targets", defaultReleaseTargets, "Comma-separated list of os/arch pairs to build for")
+	versionFlag := fs.String("version", "", "Version to embed in the binaries (build only)")
+	outFlag := fs.String("o", "", "Output file (config, default stdout) or directory (build, default dist)")
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Parse(args)
+		fs.Usage()
+		os.Exit(1)
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+
+	targets, err := parseTargets(*targetsFlag)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "config":
+		config := goreleaserConfig(targets)
+		if *outFlag == "" {
+			fmt.Print(config)
+			return nil
+		}
+		return os.WriteFile(*outFlag, []byte(config), 0o644)
+	case "build":
+		if *versionFlag == "" {
+			return fmt.Errorf("--version is required for release builds")
+		}
+		outDir := *outFlag
+		if outDir == "" {
+			outDir = "dist"
+		}
+		return buildRelease(strings.TrimPrefix(*versionFlag, "v"), outDir, targets)
+	default:
+		return fmt.Errorf("unknown release action '%s' (use config or build)", action)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// retryStats summarizes retry behaviour of the tasks of a single process
+type retryStats struct {
+	Process       string
+	Tasks         int
+	Retried       int
+	TotalAttempts int
+	MaxAttempts   int
+	Wasted        time.Duration  // realtime spent on attempts that were retried
+	Failures      map[string]int // failed attempts by status, derived by status rules
+
+	InitialMemory int64
+	InitialTime   time.Duration
+	InitialCPUs   int
+
+	// Requested resources of the attempts that eventually succeeded
+	finalMemory []float64
+	finalTime   []float64
+	finalCPUs   []float64
+}
+
+// retryRecord is a process in the machine-readable output formats of
+// "retries"; the resources that sufficed, at the coverage percentile, are
+// omitted for processes without a successful task
+type retryRecord struct {
+	Process             string         `json:"process"`
+	Tasks               int            `json:"tasks"`
+	Retried             int            `json:"retried"`
+	MeanAttempts        float64        `json:"mean_attempts"`
+	MaxAttempts         int            `json:"max_attempts"`
+	RetriedRealtimeMs   int64          `json:"retried_realtime_ms"`
+	Failures            map[string]int `json:"failures,omitempty"`
+	InitialMemoryBytes  int64          `json:"initial_memory_bytes"`
+	InitialTimeMs       int64          `json:"initial_time_ms"`
+	InitialCPUs         int            `json:"initial_cpus"`
+	Coverage            float64        `json:"coverage"`
+	SufficedMemoryBytes *int64         `json:"sufficed_memory_bytes,omitempty"`
+	SufficedTimeMs      *int64         `json:"sufficed_time_ms,omitempty"`
+	SufficedCPUs        *int           `json:"sufficed_cpus,omitempty"`
+}
+
+// isSuccess reports whether a task status represents a successful execution
+func isSuccess(status string) bool {
+	return status == "COMPLETED" || status == "CACHED"
+}
+
+// collectRetryStats groups attempts of the same logical task and aggregates
+// them per process, preserving the order in which processes first appear
+func collectRetryStats(records []TraceRecord) []*retryStats {
+	// Group attempts by logical task (the task name is the same across attempts)
+	type taskAttempts struct {
+		process  string
+		attempts []TraceRecord
+	}
+	var taskOrder []string
+	tasks := make(map[string]*taskAttempts)
+	for _, rec := range records {
+		key := rec.Name
+		if key == "" {
+			key = rec.Process + "\x00" + rec.Tag
+		}
+		task, ok := tasks[key]
+		if !ok {
+			task = &taskAttempts{process: rec.Process}
+			tasks[key] = task
+			taskOrder = append(taskOrder, key)
+		}
+		task.attempts = append(task.attempts, rec)
+	}
+
+	var order []*retryStats
+	byProcess := make(map[string]*retryStats)
+	for _, key := range taskOrder {
+		task := tasks[key]
+		stats, ok := byProcess[task.process]
+		if !ok {
+			stats = &retryStats{Process: task.process, Failures: make(map[string]int)}
+			byProcess[task.process] = stats
+			order = append(order, stats)
+		}
+
+		final := task.attempts[0]
+		for _, rec := range task.attempts {
+			if rec.Attempt > final.Attempt {
+				final = rec
+			}
+			if rec.Attempt <= 1 {
+				stats.InitialMemory = max(stats.InitialMemory, rec.Memory)
+				stats.InitialTime = max(stats.InitialTime, rec.Time)
+				stats.InitialCPUs = max(stats.InitialCPUs, rec.CPUs)
+			}
+		}
+		for _, rec := range task.attempts {
+			if rec.Attempt < final.Attempt {
+				stats.Wasted += rec.Realtime
+			}
+			if rec.Status != "" && !isSuccess(rec.Status) {
+				stats.Failures[rec.Status]++
+			}
+		}
+
+		attempts := max(final.Attempt, 1)
+		stats.Tasks++
+		stats.TotalAttempts += attempts
+		stats.MaxAttempts = max(stats.MaxAttempts, attempts)
+		if attempts > 1 {
+			stats.Retried++
+		}
+		if isSuccess(final.Status) {
+			stats.finalMemory = append(stats.finalMemory, float64(final.Memory))
+			stats.finalTime = append(stats.finalTime, float64(final.Time))
+			stats.finalCPUs = append(stats.finalCPUs, float64(final.CPUs))
+		}
+	}
+
+	return order
+}
+
+// runRetries implements the "retries" subcommand, reporting how many attempts
+// tasks needed and which resources eventually sufficed
+func runRetries(args []string) error {
+	fs := newFlagSet("retries")
+	input := inputFlags(fs)
+	coverage := fs.Float64("coverage", 90, "Percentage of tasks the recommended initial resources should cover")
+	all := fs.Bool("all", false, "Report processes without any retried tasks as well")
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	if !trace.HasColumn("attempt") {
+		return fmt.Errorf("attempt column not found in input file")
+	}
+
+	var records []retryRecord
+	reported := 0
+	for _, stats := range collectRetryStats(trace.Records) {
+		if stats.Retried == 0 && !*all {
+			continue
+		}
+		reported++
+		if outputFormat != "text" {
+			r := retryRecord{
+				Process:            stats.Process,
+				Tasks:              stats.Tasks,
+				Retried:            stats.Retried,
+				MeanAttempts:       float64(stats.TotalAttempts) / float64(stats.Tasks),
+				MaxAttempts:        stats.MaxAttempts,
+				RetriedRealtimeMs:  stats.Wasted.Milliseconds(),
+				Failures:           stats.Failures,
+				InitialMemoryBytes: stats.InitialMemory,
+				InitialTimeMs:      stats.InitialTime.Milliseconds(),
+				InitialCPUs:        stats.InitialCPUs,
+				Coverage:           *coverage,
+			}
+			if len(stats.finalMemory) > 0 {
+				memory := int64(percentile(stats.finalMemory, *coverage))
+				limit := time.Duration(percentile(stats.finalTime, *coverage)).Milliseconds()
+				cpus := int(percentile(stats.finalCPUs, *coverage))
+				r.SufficedMemoryBytes, r.SufficedTimeMs, r.SufficedCPUs = &memory, &limit, &cpus
+			}
+			records = append(records, r)
+			continue
+		}
+
+		fmt.Printf("Process: %s\n", stats.Process)
+		fmt.Printf("  Tasks: %d (%d retried, %s)\n",
+			stats.Tasks, stats.Retried, FormatPercent(100*float64(stats.Retried)/float64(stats.Tasks)))
+		fmt.Printf("  Attempts: mean %.2f, max %d\n",
+			float64(stats.TotalAttempts)/float64(stats.Tasks), stats.MaxAttempts)
+		fmt.Printf("  Realtime of retried attempts: %s\n", FormatDuration(stats.Wasted))
+		if len(stats.Failures) > 0 {
+			fmt.Printf("  Failed attempts: %s\n", formatStatusCounts(stats.Failures))
+		}
+
+		if len(stats.finalMemory) > 0 {
+			sufficed := int64(percentile(stats.finalMemory, *coverage))
+			fmt.Printf("  Memory: initial %s, sufficed (p%g) %s%s\n",
+				FormatSize(stats.InitialMemory), *coverage, FormatSize(sufficed),
+				recommendation(sufficed > stats.InitialMemory, FormatSize(sufficed)))
+
+			sufficedTime := time.Duration(percentile(stats.finalTime, *coverage))
+			fmt.Printf("  Time: initial %s, sufficed (p%g) %s%s\n",
+				FormatDuration(stats.InitialTime), *coverage, FormatDuration(sufficedTime),
+				recommendation(sufficedTime > stats.InitialTime, FormatDuration(sufficedTime)))
+
+			sufficedCPUs := int(percentile(stats.finalCPUs, *coverage))
+			fmt.Printf("  CPUs: initial %d, sufficed (p%g) %d%s\n",
+				stats.InitialCPUs, *coverage, sufficedCPUs,
+				recommendation(sufficedCPUs > stats.InitialCPUs, fmt.Sprint(sufficedCPUs)))
+		}
+		fmt.Println()
+	}
+
+	if outputFormat != "text" {
+		return writeRecords(os.Stdout, records)
+	}
+	if reported == 0 {
+		fmt.Println("No retried tasks found")
+	}
+	return nil
+}
+
+// formatStatusCounts lists statuses with their counts, most frequent first
+func formatStatusCounts(counts map[string]int) string {
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if counts[statuses[i]] != counts[statuses[j]] {
+			return counts[statuses[i]] > counts[statuses[j]]
+		}
+		return statuses[i] < statuses[j]
+	})
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		parts[i] = fmt.Sprintf("%s %d", status, counts[status])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// recommendation formats the suggestion suffix for a resource line
+func recommendation(increase bool, value string) string {
+	if !increase {
+		return ""
+	}
+	return " -> recommended initial value: " + value
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reviewRecommendations walks through every recommended change, showing
+// its evidence, and asks on out whether to accept, modify or skip it,
+// reading the answers from in. Skipped changes are cleared, so that only
+// accepted or modified ones remain; "q" or the end of the input skips all
+// changes not reviewed yet. Times entered are rounded up to timeIncrement
+// and capped at the partition limits, like recommended ones.
+func reviewRecommendations(in io.Reader, out io.Writer, recommendations []*resourceRecommendation, p float64,
+	timeIncrement time.Duration, limits map[string]time.Duration) error {
+	scanner := bufio.NewScanner(in)
+	quit := false
+	ask := func(prompt string) (string, bool) {
+		fmt.Fprint(out, prompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return "", false
+		}
+		return strings.TrimSpace(scanner.Text()), true
+	}
+
+	// review asks about the change of one resource and returns whether it
+	// is kept; modify parses a value entered instead
+	review := func(r *resourceRecommendation, resource, current, recommended string, e usageEvidence, modify func(string) error) bool {
+		if quit {
+			return false
+		}
+		fmt.Fprintf(out, "\n%s %s: %s -> %s\n", r.Process, resource, current, recommended)
+		fmt.Fprintf(out, "  evidence: %s\n", describeEvidence(resource, e, p))
+		if len(r.LowConfidence) > 0 {
+			fmt.Fprintf(out, "  low confidence: %s\n", strings.Join(r.LowConfidence, ", "))
+		}
+		for {
+			answer, ok := ask("Accept, modify, skip or quit? [A/m/s/q] ")
+			if !ok {
+				quit = true
+				return false
+			}
+			switch strings.ToLower(answer) {
+			case "", "a", "accept":
+				return true
+			case "s", "skip":
+				return false
+			case "q", "quit":
+				quit = true
+				return false
+			case "m", "modify":
+				for {
+					value, ok := ask(fmt.Sprintf("New %s: ", resource))
+					if !ok {
+						quit = true
+						return false
+					}
+					err := modify(value)
+					if err == nil {
+						return true
+					}
+					fmt.Fprintf(out, "  %v\n", err)
+				}
+			default:
+				fmt.Fprintln(out, "  answer a, m, s or q")
+			}
+		}
+	}
+
+	for _, r := range recommendations {
+		requested := func(set bool, value string) string {
+			if !set {
+				return "-"
+			}
+			return value
+		}
+		if r.CPUs > 0 && !review(r, "cpus", requested(r.RequestedCPUs > 0, strconv.Itoa(r.RequestedCPUs)), strconv.Itoa(r.CPUs), r.CPUEvidence,
+			func(value string) error {
+				cpus, err := strconv.Atoi(value)
+				if err != nil || cpus < 1 {
+					return fmt.Errorf("invalid number of CPUs '%s'", value)
+				}
+				r.CPUs = cpus
+				return nil
+			}) {
+			r.CPUs = 0
+		}
+		if r.Memory > 0 && !review(r, "memory", requested(r.RequestedMemory > 0, configMemory(r.RequestedMemory)), configMemory(r.Memory), r.MemoryEvidence,
+			func(value string) error {
+				memory, err := ParseSize(value)
+				if err != nil || memory < 1<<20 {
+					return fmt.Errorf("invalid memory '%s' (use e.g. 8 GB)", value)
+				}
+				r.Memory = memory
+				return nil
+			}) {
+			r.Memory = 0
+		}
+		if r.Time > 0 && !review(r, "time", requested(r.RequestedTime > 0, configTime(r.RequestedTime)), configTime(r.Time), r.TimeEvidence,
+			func(value string) error {
+				limit, err := ParseDuration(value)
+				if err != nil || limit <= 0 {
+					return fmt.Errorf("invalid time '%s' (use e.g. 2h 30m)", value)
+				}
+				r.Time = roundTime(float64(limit), timeIncrement)
+				capTime(r, limits)
+				if note := timeCapNote(r); note != "" {
+					fmt.Fprintf(out, "  %s\n", note)
+				}
+				return nil
+			}) {
+			r.Time = 0
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RO-Crate specification and the Process Run Crate profile the crates
+// written by "rocrate" conform to
+const (
+	roCrateSpec       = "https://w3id.org/ro/crate/1.1"
+	processRunProfile = "https://w3id.org/ro/wfrun/process/0.5"
+)
+
+// entity is a JSON-LD entity of the RO-Crate metadata
+type entity map[string]any
+
+// ref returns a reference to the entity with the given identifier
+func ref(id string) entity {
+	return entity{"@id": id}
+}
+
+// crateMetadata describes a run in RO-Crate metadata: the pipeline run
+// that produced the trace and the nfu run that produced the summary, with
+// the metrics of the run as measured variables of the crate
+func crateMetadata(run postRun, traceName, traceFormat string, compressed bool, traceSHA256, summaryName string, created time.Time) entity {
+	metric := func(id, name string, value any, unit string) entity {
+		e := entity{"@id": id, "@type": "PropertyValue", "name": name, "value": value}
+		if unit != "" {
+			e["unitText"] = unit
+		}
+		return e
+	}
+	metrics := []entity{
+		metric("#tasks", "tasks", run.Tasks, ""),
+		metric("#failed-tasks", "failed tasks", run.Failed, ""),
+		metric("#wall-time", "wall time", float64(run.WallTimeMs)/float64(time.Hour/time.Millisecond), "h"),
+		metric("#runtime", "total task runtime", float64(run.RuntimeMs)/float64(time.Hour/time.Millisecond), "h"),
+		metric("#cpu-hours", "CPU time", run.CPUHours, "h"),
+	}
+	metricRefs := make([]entity, len(metrics))
+	for i, m := range metrics {
+		metricRefs[i] = ref(m["@id"].(string))
+	}
+
+	pipelineRun := entity{
+		"@id":        "#pipeline-run",
+		"@type":      "CreateAction",
+		"name":       "Nextflow pipeline run",
+		"instrument": ref("#nextflow"),
+		"result":     []entity{ref(traceName)},
+	}
+	if run.Submit != "" {
+		pipelineRun["startTime"] = run.Submit
+	}
+	if run.Complete != "" {
+		pipelineRun["endTime"] = run.Complete
+	}
+
+	traceFile := entity{
+		"@id":            traceName,
+		"@type":          "File",
+		"name":           "Nextflow execution trace",
+		"encodingFormat": traceFormat,
+	}
+	if compressed {
+		traceFile["encodingFormat"] = "application/gzip"
+		traceFile["description"] = "gzip-compressed " + traceFormat
+	}
+	if traceSHA256 != "" {
+		traceFile["sha256"] = traceSHA256
+	}
+
+	graph := []entity{
+		{
+			"@id":        "ro-crate-metadata.json",
+			"@type":      "CreativeWork",
+			"conformsTo": ref(roCrateSpec),
+			"about":      ref("./"),
+		},
+		{
+			"@id":              "./",
+			"@type":            "Dataset",
+			"name":             "Resource usage of a Nextflow run",
+			"description":      "Execution trace of a Nextflow run with its resource usage summarized per process by nfu",
+			"datePublished":    created.Format(time.RFC3339),
+			"conformsTo":       ref(processRunProfile),
+			"hasPart":          []entity{ref(traceName), ref(summaryName)},
+			"mentions":         []entity{ref("#pipeline-run"), ref("#nfu-run")},
+			"variableMeasured": metricRefs,
+		},
+		{"@id": processRunProfile, "@type": "CreativeWork", "name": "Process Run Crate", "version": "0.5"},
+		traceFile,
+		{
+			"@id":            summaryName,
+			"@type":          "File",
+			"name":           "Resource usage per process",
+			"description":    "Columns as written by nfu --output-format tsv summary",
+			"encodingFormat": "text/tab-separated-values",
+		},
+		pipelineRun,
+		{
+			"@id":        "#nfu-run",
+			"@type":      "CreateAction",
+			"name":       "Summary of the resource usage per process",
+			"instrument": ref("#nfu"),
+			"object":     []entity{ref(traceName)},
+			"result":     []entity{ref(summaryName)},
+			"endTime":    created.Format(time.RFC3339),
+		},
+		{"@id": "#nextflow", "@type": "SoftwareApplication", "name": "Nextflow", "url": "https://www.nextflow.io/"},
+		{"@id": "#nfu", "@type": "SoftwareApplication", "name": "nfu", "softwareVersion": version},
+	}
+	return entity{"@context": roCrateSpec + "/context", "@graph": append(graph, metrics...)}
+}
+
+// traceMediaType returns the media type of the content of a trace file,
+// tab- or comma-separated values as detected from its header like the trace
+// is read, and whether the file is gzip-compressed
+func traceMediaType(path string) (string, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false, fmt.Errorf("error opening file: %w", err)
+	}
+	magic := make([]byte, 2)
+	n, _ := io.ReadFull(file, magic)
+	file.Close()
+	compressed := n == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+
+	r, _, err := openTrace(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer r.Close()
+	header, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", false, fmt.Errorf("error reading header line of %s: %w", path, err)
+	}
+	if traceSeparator(strings.TrimSuffix(header, "\n")) == "," {
+		return "text/csv", compressed, nil
+	}
+	return "text/tab-separated-values", compressed, nil
+}
+
+// copyFile copies the file at src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// runROCrate implements the "rocrate" subcommand, exporting a run with its
+// resource usage as an RO-Crate
+func runROCrate(args []string) error {
+	fs := newFlagSet("rocrate")
+	input := inputFlags(fs)
+	dir := fs.String("d", "", "Directory to write the crate to, created if missing")
+	fs.StringVar(dir, "dir", "", "Directory to write the crate to, created if missing")
+	fs.Parse(args)
+
+	if *dir == "" {
+		return fmt.Errorf("give the directory of the crate with -d")
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	if len(trace.Files) != 1 || trace.Files[0] == "stdin" {
+		return fmt.Errorf("a crate is made of a single trace file, give it with -i")
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return fmt.Errorf("error creating crate directory: %w", err)
+	}
+
+	// The trace is copied under another name if it has that of a file the
+	// crate is made of
+	traceName, summaryName := filepath.Base(trace.Path), "resource_summary.tsv"
+	if traceName == summaryName || traceName == "ro-crate-metadata.json" {
+		traceName = "trace_" + traceName
+	}
+	traceFormat, compressed, err := traceMediaType(trace.Path)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(trace.Path, filepath.Join(*dir, traceName)); err != nil {
+		return fmt.Errorf("error copying trace into the crate: %w", err)
+	}
+	summary, err := os.Create(filepath.Join(*dir, summaryName))
+	if err != nil {
+		return fmt.Errorf("error writing summary: %w", err)
+	}
+	err = writeRecordsAs(summary, "tsv", summaryRecords(summarizeProcesses(trace.Records)))
+	if closeErr := summary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing summary: %w", err)
+	}
+
+	sum, _ := fileSHA256(trace.Path)
+	metadata, err := json.MarshalIndent(crateMetadata(summarizeRun(trace), traceName, traceFormat, compressed, sum, summaryName, time.Now()), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(*dir, "ro-crate-metadata.json"), append(metadata, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing crate metadata: %w", err)
+	}
+	fmt.Printf("RO-Crate of %d tasks written to %s\n", len(trace.Records), *dir)
+	return nil
+}
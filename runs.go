@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// appendedRun describes one of the runs appended to a trace
+type appendedRun struct {
+	Tasks, Failed, Cached int
+	First, Last           time.Time
+}
+
+// runRuns implements the "runs" subcommand, listing the runs of a trace
+// that several runs appended to, e.g. resumed runs with
+// trace.overwrite = false
+func runRuns(args []string) error {
+	fs := newFlagSet("runs")
+	input := inputFlags(fs)
+	fs.Parse(args)
+
+	if input.Read.Runs != "merge" && input.Read.Runs != "split" {
+		return fmt.Errorf("--runs cannot be used with the runs command, which lists every run")
+	}
+	input.Read.Runs = "split"
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	runs := make([]appendedRun, trace.Runs)
+	for _, rec := range trace.Records {
+		r := &runs[rec.Run]
+		r.Tasks++
+		switch {
+		case rec.Status == "CACHED":
+			r.Cached++
+		case rec.Status != "" && !isSuccess(rec.Status):
+			r.Failed++
+		}
+		if !rec.Submit.IsZero() && (r.First.IsZero() || rec.Submit.Before(r.First)) {
+			r.First = rec.Submit
+		}
+		if !rec.Start.IsZero() && rec.End().After(r.Last) {
+			r.Last = rec.End()
+		}
+	}
+
+	fmt.Printf("Runs: %d\n\n", trace.Runs)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tTASKS\tCACHED\tFAILED\tSTART\tEND\tWALL TIME")
+	for i, r := range runs {
+		start, end, wall := "-", "-", "-"
+		if !r.First.IsZero() {
+			start = r.First.Format(time.DateTime)
+		}
+		if !r.Last.IsZero() {
+			end = r.Last.Format(time.DateTime)
+		}
+		if !r.First.IsZero() && r.Last.After(r.First) {
+			wall = FormatDuration(r.Last.Sub(r.First))
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%s\t%s\t%s\n", i+1, r.Tasks, r.Cached, r.Failed, start, end, wall)
+	}
+	w.Flush()
+	if trace.Runs > 1 {
+		fmt.Println("\nSelect a run with --runs N or --runs last in other commands; by default runs are merged.")
+	}
+	return nil
+}
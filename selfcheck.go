@@ -0,0 +1,308 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// corpus holds trace snippets in the formats written by different Nextflow
+// versions and configurations
+//
+//go:embed corpus/*.txt
+var corpus embed.FS
+
+// corpusCase describes the expected parse result of a corpus file
+type corpusCase struct {
+	Feature    string
+	File       string
+	Records    int
+	Processes  int
+	Duration   time.Duration // sum of the duration column
+	Realtime   time.Duration // sum of the realtime column
+	Memory     int64         // sum of the requested memory
+	CPUPercent float64       // sum of the %cpu column
+}
+
+var corpusCases = []corpusCase{
+	{
+		Feature: "Nextflow 19 default fields", File: "nf19-default.txt",
+		Records: 3, Processes: 2,
+		Duration: 2*time.Minute + 9*time.Second, Realtime: 109200 * time.Millisecond,
+		CPUPercent: 455.6,
+	},
+	{
+		Feature: "Nextflow 22 extended fields", File: "nf22-extended.txt",
+		Records: 3, Processes: 2,
+		Duration: time.Hour + 57*time.Minute + 36*time.Second, Realtime: time.Hour + 56*time.Minute + 28*time.Second,
+		Memory: 144 << 30, CPUPercent: 2654.4,
+	},
+	{
+		Feature: "raw values (trace.raw = true)", File: "nf23-raw.txt",
+		Records: 2, Processes: 1,
+		Duration: 220 * time.Second, Realtime: 210500 * time.Millisecond,
+		Memory: 8 << 30, CPUPercent: 691.0,
+	},
+	{
+		Feature: "decimal commas", File: "european.txt",
+		Records: 2, Processes: 1,
+		Duration: 7213500 * time.Millisecond, Realtime: 7080500 * time.Millisecond,
+		Memory: 15 << 30, CPUPercent: 783.5,
+	},
+	{
+		Feature: "byte order mark and CRLF line endings", File: "windows.txt",
+		Records: 2, Processes: 1,
+		Duration: 37 * time.Second, Realtime: 30 * time.Second,
+	},
+	{
+		Feature: "missing values", File: "missing-values.txt",
+		Records: 3, Processes: 1,
+		Duration: 2*time.Hour + 5*time.Minute + 3200*time.Millisecond, Realtime: 2*time.Hour + 3*time.Minute + 11100*time.Millisecond,
+		CPUPercent: 98.7,
+	},
+}
+
+// durationCases maps duration strings to their expected values
+var durationCases = []struct {
+	Input    string
+	Expected time.Duration
+}{
+	{"3.5d", 84 * time.Hour},
+	{"21h 40m 51s", 21*time.Hour + 40*time.Minute + 51*time.Second},
+	{"1h 21m 27s", time.Hour + 21*time.Minute + 27*time.Second},
+	{"2m", 2 * time.Minute},
+	{"1m 53s", time.Minute + 53*time.Second},
+	{"42.9s", 42900 * time.Millisecond},
+	{"500ms", 500 * time.Millisecond},
+	{"1,5h", 90 * time.Minute},
+	{"1.2e+03s", 20 * time.Minute},
+	{"90500", 90500 * time.Millisecond},
+}
+
+// sizeCases maps memory strings to their expected number of bytes
+var sizeCases = []struct {
+	Input    string
+	Expected int64
+}{
+	{"2 GB", 2 << 30},
+	{"512 MB", 512 << 20},
+	{"102.4 KB", 104858},
+	{"1024", 1024},
+	{"1,5 GB", 3 << 29},
+	{"1.234,5 MB", 1294467072},
+	{"1.2e+05", 120000},
+	{"3.5E2 KB", 358400},
+}
+
+// invalidDurations and invalidSizes must be rejected instead of wrapping to
+// arbitrary (possibly negative) values
+var (
+	invalidDurations = []string{"NaN", "Inf", "-5s", "1e400s", "9999999999999d", "300000000h 300000000h", "1e30"}
+	invalidSizes     = []string{"NaN", "1e30 PB", "9999999999 GB", "-1 GB"}
+)
+
+// baselineProcesses are process names with characters that must survive
+// being quoted as patterns in a baseline and read back
+var baselineProcesses = []string{
+	"NFCORE_RNASEQ:ALIGN_STAR:STAR_ALIGN",
+	`SAMTOOLS.SORT (chr1+chr2)`,
+	`GATK|HC\split`,
+	"it's_[1-9]*",
+}
+
+// checkResult is the outcome of the checks of one format feature
+type checkResult struct {
+	Feature  string
+	Checks   int
+	Failures []string
+}
+
+func (r *checkResult) expect(ok bool, format string, args ...any) {
+	r.Checks++
+	if !ok {
+		r.Failures = append(r.Failures, fmt.Sprintf(format, args...))
+	}
+}
+
+// checkDurations verifies ParseDuration against known values and that
+// formatted durations parse back to the same value
+func checkDurations() *checkResult {
+	result := &checkResult{Feature: "duration formats"}
+	for _, c := range durationCases {
+		d, err := ParseDuration(c.Input)
+		result.expect(err == nil && d == c.Expected, "'%s' parsed as %v (%v), expected %v", c.Input, d, err, c.Expected)
+
+		formatted := FormatDuration(c.Expected)
+		roundTrip, err := ParseDuration(formatted)
+		want := c.Expected.Round(time.Second)
+		if c.Expected < time.Second {
+			want = c.Expected.Round(time.Millisecond)
+		}
+		result.expect(err == nil && roundTrip == want, "'%s' round-tripped to %v (%v), expected %v", formatted, roundTrip, err, want)
+	}
+	return result
+}
+
+// checkSizes verifies ParseSize against known values
+func checkSizes() *checkResult {
+	result := &checkResult{Feature: "memory formats"}
+	for _, c := range sizeCases {
+		size, err := ParseSize(c.Input)
+		result.expect(err == nil && size == c.Expected, "'%s' parsed as %d (%v), expected %d", c.Input, size, err, c.Expected)
+	}
+	return result
+}
+
+// checkPathological verifies that out-of-range and non-finite values are
+// rejected by the parsers and that totals saturate instead of overflowing
+func checkPathological() *checkResult {
+	result := &checkResult{Feature: "pathological values"}
+	for _, input := range invalidDurations {
+		d, err := ParseDuration(input)
+		result.expect(err != nil, "'%s' parsed as %v, expected an error", input, d)
+	}
+	for _, input := range invalidSizes {
+		size, err := ParseSize(input)
+		result.expect(err != nil, "'%s' parsed as %d, expected an error", input, size)
+	}
+	for _, input := range []string{"NaN", "Inf", "-Infinity"} {
+		value, err := parseNumber(input)
+		result.expect(err != nil, "'%s' parsed as %v, expected an error", input, value)
+	}
+
+	sum, overflow := addDurations(math.MaxInt64-time.Second, time.Hour)
+	result.expect(overflow && sum == math.MaxInt64, "overflowing sum gave %v, expected saturation", sum)
+
+	// 1000 runs of 200 years each exceed time.Duration but not a Total
+	var total Total
+	for i := 0; i < 1000; i++ {
+		total.Add(int64(200 * 365 * 24 * time.Hour))
+	}
+	hours, _, _ := total.HMS()
+	result.expect(hours.String() == "1752000000", "total of 1000 x 200 years gave %sh, expected 1752000000h", hours)
+	return result
+}
+
+// checkBaseline verifies that a baseline written for processes with
+// regular expression metacharacters in their names matches them when read
+func checkBaseline() *checkResult {
+	result := &checkResult{Feature: "baseline round trip"}
+	dir, err := os.MkdirTemp("", "nfu-selfcheck-")
+	if err != nil {
+		result.expect(false, "error creating temporary directory: %v", err)
+		return result
+	}
+	defer os.RemoveAll(dir)
+
+	var budgets budgetList
+	for i, process := range baselineProcesses {
+		b, err := budgets.get(regexp.QuoteMeta(process))
+		if err != nil {
+			result.expect(false, "%v", err)
+			return result
+		}
+		b.MaxTime = time.Duration(i+1) * time.Minute
+	}
+	path := filepath.Join(dir, "baseline.yaml")
+	if err := writeBaseline(path, &Trace{Path: "selfcheck"}, budgets); err != nil {
+		result.expect(false, "%v", err)
+		return result
+	}
+	baseline, err := loadBudgets(path)
+	if err != nil {
+		result.expect(false, "%v", err)
+		return result
+	}
+	result.expect(len(baseline) == len(baselineProcesses), "%d budgets read back, expected %d", len(baseline), len(baselineProcesses))
+	for i, process := range baselineProcesses {
+		want := time.Duration(i+1) * time.Minute
+		found := false
+		for _, b := range baseline {
+			if b.matches(process) && b.MaxTime == want {
+				found = true
+			}
+		}
+		result.expect(found, "no budget of %v read back for '%s'", want, process)
+	}
+	return result
+}
+
+// checkCorpusCase parses a corpus file in strict mode and compares the totals
+// with the expected values
+func checkCorpusCase(c corpusCase) *checkResult {
+	result := &checkResult{Feature: fmt.Sprintf("%s (%s)", c.Feature, c.File)}
+
+	file, err := corpus.Open("corpus/" + c.File)
+	if err != nil {
+		result.expect(false, "error opening corpus file: %v", err)
+		return result
+	}
+	defer file.Close()
+
+	trace, err := parseTrace(file, c.File, ReadOptions{Strict: true})
+	if err != nil {
+		result.expect(false, "%v", err)
+		return result
+	}
+
+	var duration, realtime time.Duration
+	var memory int64
+	var cpuPercent float64
+	processes := make(map[string]bool)
+	for _, rec := range trace.Records {
+		duration += rec.Duration
+		realtime += rec.Realtime
+		memory += rec.Memory
+		cpuPercent += rec.CPUPercent
+		processes[rec.Process] = true
+	}
+
+	result.expect(len(trace.Records) == c.Records, "%d records, expected %d", len(trace.Records), c.Records)
+	result.expect(len(processes) == c.Processes, "%d processes, expected %d", len(processes), c.Processes)
+	result.expect(duration == c.Duration, "total duration %v, expected %v", duration, c.Duration)
+	result.expect(realtime == c.Realtime, "total realtime %v, expected %v", realtime, c.Realtime)
+	result.expect(memory == c.Memory, "total memory %d, expected %d", memory, c.Memory)
+	result.expect(fmt.Sprintf("%.1f", cpuPercent) == fmt.Sprintf("%.1f", c.CPUPercent),
+		"total %%cpu %.1f, expected %.1f", cpuPercent, c.CPUPercent)
+	return result
+}
+
+// runSelfCheck implements the "selfcheck" subcommand, verifying the parsers
+// against the embedded corpus and reporting the result per format feature
+func runSelfCheck(args []string) error {
+	fs := newFlagSet("selfcheck")
+	verbose := fs.Bool("v", false, "List every failed check")
+	fs.Parse(args)
+
+	results := []*checkResult{checkDurations(), checkSizes(), checkPathological(), checkBaseline()}
+	for _, c := range corpusCases {
+		results = append(results, checkCorpusCase(c))
+	}
+
+	failed := 0
+	for _, result := range results {
+		status := "PASS"
+		if len(result.Failures) > 0 {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s  %s (%d/%d checks)\n", status, result.Feature,
+			result.Checks-len(result.Failures), result.Checks)
+		for i, failure := range result.Failures {
+			if i > 0 && !*verbose {
+				fmt.Printf("      ... %d more (use -v to list all)\n", len(result.Failures)-1)
+				break
+			}
+			fmt.Printf("      %s\n", failure)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d format features failed", failed, len(results))
+	}
+	return nil
+}
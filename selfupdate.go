@@ -0,0 +1,257 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// releaseRepo is the GitHub repository release binaries are published to
+const releaseRepo = "vmikk/nfu"
+
+// checksumsAsset is the name of the release asset listing SHA-256 checksums
+const checksumsAsset = "checksums.txt"
+
+// githubRelease is the subset of the GitHub release API response we use
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL returns the download URL of the named release asset
+func (r *githubRelease) assetURL(name string) (string, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL, true
+		}
+	}
+	return "", false
+}
+
+// releaseArchiveName returns the name of the release archive for the current
+// platform, following the naming scheme of the release configuration
+func releaseArchiveName(version string) string {
+	ext := "tar.gz"
+	if runtime.GOOS == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("nfu_%s_%s_%s.%s", strings.TrimPrefix(version, "v"), runtime.GOOS, runtime.GOARCH, ext)
+}
+
+// compareVersions compares two semantic versions (ignoring a leading "v" and
+// pre-release suffixes) and returns -1, 0 or 1
+func compareVersions(a, b string) int {
+	parse := func(v string) [3]int {
+		v = strings.TrimPrefix(v, "v")
+		v, _, _ = strings.Cut(v, "-")
+		var parts [3]int
+		for i, part := range strings.SplitN(v, ".", 3) {
+			parts[i], _ = strconv.Atoi(part)
+		}
+		return parts
+	}
+	pa, pb := parse(a), parse(b)
+	for i := range pa {
+		switch {
+		case pa[i] < pb[i]:
+			return -1
+		case pa[i] > pb[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// httpGet downloads a URL into memory
+func httpGet(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// expectedChecksum looks up the SHA-256 checksum of a file in a checksums
+// file in the "<hex digest>  <file name>" format written by sha256sum
+func expectedChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum listed for %s", name)
+}
+
+// extractBinary returns the nfu executable contained in a release archive
+func extractBinary(archive []byte, archiveName string) ([]byte, error) {
+	binaryName := "nfu"
+	if runtime.GOOS == "windows" {
+		binaryName = "nfu.exe"
+	}
+
+	if strings.HasSuffix(archiveName, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, fmt.Errorf("error reading archive: %w", err)
+		}
+		for _, f := range zr.File {
+			if filepath.Base(f.Name) == binaryName {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, fmt.Errorf("error reading archive: %w", err)
+				}
+				defer rc.Close()
+				return io.ReadAll(rc)
+			}
+		}
+		return nil, fmt.Errorf("%s not found in %s", binaryName, archiveName)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in %s", binaryName, archiveName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && filepath.Base(hdr.Name) == binaryName {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// replaceExecutable atomically replaces the running executable. The new
+// binary is written next to the old one and renamed over it, so an
+// interrupted update never leaves a truncated binary behind.
+func replaceExecutable(binary []byte) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("error locating executable: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", fmt.Errorf("error locating executable: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".nfu-update-*")
+	if err != nil {
+		return "", fmt.Errorf("error writing new executable (is the install directory writable?): %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("error writing new executable: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("error writing new executable: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return "", fmt.Errorf("error writing new executable: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return "", fmt.Errorf("error replacing executable: %w", err)
+	}
+	return exe, nil
+}
+
+// runSelfUpdate implements the "self-update" subcommand, replacing the
+// binary with the latest GitHub release after verifying its checksum
+func runSelfUpdate(args []string) error {
+	fs := newFlagSet("self-update")
+	check := fs.Bool("check", false, "Only report whether a newer release is available")
+	force := fs.Bool("force", false, "Install the latest release even if it is not newer")
+	repo := fs.String("repo", releaseRepo, "GitHub repository to fetch releases from")
+	fs.Parse(args)
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+
+	body, err := httpGet(client, fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", *repo))
+	if err != nil {
+		return err
+	}
+	var release githubRelease
+	if err := json.Unmarshal(body, &release); err != nil {
+		return fmt.Errorf("error parsing release information: %w", err)
+	}
+
+	latest := strings.TrimPrefix(release.TagName, "v")
+	if compareVersions(latest, version) <= 0 && !*force {
+		fmt.Printf("nfu %s is up to date (latest release: %s)\n", version, latest)
+		return nil
+	}
+	if *check {
+		fmt.Printf("nfu %s is available (installed: %s)\n", latest, version)
+		return nil
+	}
+
+	archiveName := releaseArchiveName(latest)
+	archiveURL, ok := release.assetURL(archiveName)
+	if !ok {
+		return fmt.Errorf("release %s has no build for %s/%s (%s)", release.TagName, runtime.GOOS, runtime.GOARCH, archiveName)
+	}
+	checksumsURL, ok := release.assetURL(checksumsAsset)
+	if !ok {
+		return fmt.Errorf("release %s has no %s, refusing to install an unverified binary", release.TagName, checksumsAsset)
+	}
+
+	slog.Info("downloading release", "version", latest, "asset", archiveName)
+	checksums, err := httpGet(client, checksumsURL)
+	if err != nil {
+		return err
+	}
+	archive, err := httpGet(client, archiveURL)
+	if err != nil {
+		return err
+	}
+
+	want, err := expectedChecksum(checksums, archiveName)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(archive)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, expected %s", archiveName, got, want)
+	}
+
+	binary, err := extractBinary(archive, archiveName)
+	if err != nil {
+		return err
+	}
+	exe, err := replaceExecutable(binary)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s from %s to %s\n", exe, version, latest)
+	return nil
+}
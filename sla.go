@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// slaBreach is a sample that took longer than the turnaround target
+type slaBreach struct {
+	*sampleTiming
+	Over    time.Duration // turnaround beyond the target
+	Process string        // process the sample spent the most time in
+}
+
+// findSLABreaches returns the completed samples whose turnaround exceeds sla,
+// the largest breach first. The process contributing most to a breach is the
+// one with the longest time from the first submission to the last completion
+// of its tasks of the sample.
+func findSLABreaches(timings []*sampleTiming, sla time.Duration) []slaBreach {
+	var breaches []slaBreach
+	for _, s := range timings {
+		if !s.Done || s.Turnaround() <= sla {
+			continue
+		}
+		b := slaBreach{sampleTiming: s, Over: s.Turnaround() - sla}
+		for process, d := range s.Processes {
+			if b.Process == "" || d > s.Processes[b.Process] || (d == s.Processes[b.Process] && process < b.Process) {
+				b.Process = process
+			}
+		}
+		breaches = append(breaches, b)
+	}
+	sort.SliceStable(breaches, func(i, j int) bool { return breaches[i].Over > breaches[j].Over })
+	return breaches
+}
+
+// runSLA implements the "sla" subcommand, reporting the samples whose
+// turnaround exceeded a target and the process that contributed most
+func runSLA(args []string) error {
+	fs := newFlagSet("sla")
+	input := inputFlags(fs)
+	target := fs.String("sla", "", "Turnaround target per sample, e.g. 24h or 2d (required)")
+	fs.Parse(args)
+
+	if *target == "" {
+		return fmt.Errorf("--sla is required")
+	}
+	sla, err := ParseDuration(*target)
+	if err != nil || sla <= 0 {
+		return fmt.Errorf("invalid SLA '%s'", *target)
+	}
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	timings := collectSampleTimings(trace.Records)
+	if len(timings) == 0 {
+		return fmt.Errorf("no samples found (samples are the tags of processes with more than one tag)")
+	}
+
+	completed := 0
+	for _, s := range timings {
+		if s.Done {
+			completed++
+		}
+	}
+	breaches := findSLABreaches(timings, sla)
+	fmt.Printf("Samples over the SLA of %s: %d of %d completed", FormatDuration(sla), len(breaches), completed)
+	if completed < len(timings) {
+		fmt.Printf(" (%d not completed)", len(timings)-completed)
+	}
+	fmt.Println()
+	if len(breaches) == 0 {
+		return nil
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SAMPLE\tTURNAROUND\tOVER\tTOP PROCESS\tPROCESS TIME\tSHARE")
+	for _, b := range breaches {
+		d := b.Processes[b.Process]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", b.Sample, FormatDuration(b.Turnaround()), FormatDuration(b.Over),
+			shortProcessName(b.Process), FormatDuration(d), FormatPercent(100*d.Seconds()/b.Turnaround().Seconds()))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// processSnapshot is the structure of one process in a run: how many tasks
+// it ran and which share of them came from the cache
+type processSnapshot struct {
+	Process   string
+	Tasks     int
+	Cached    int
+	Attempts  int
+	CacheRate float64
+}
+
+// takeSnapshot summarises the structure of a run by process, sorted by
+// process name so that snapshots of the same pipeline compare line by line.
+// Tasks are counted once however often they were retried.
+func takeSnapshot(records []TraceRecord) []processSnapshot {
+	byProcess := make(map[string]*processSnapshot)
+	tasks := make(map[string]map[string]bool)
+	for _, rec := range records {
+		s, ok := byProcess[rec.Process]
+		if !ok {
+			s = &processSnapshot{Process: rec.Process}
+			byProcess[rec.Process] = s
+			tasks[rec.Process] = make(map[string]bool)
+		}
+		s.Attempts++
+		if rec.Status == "CACHED" {
+			s.Cached++
+		}
+		name := rec.Name
+		if name == "" {
+			name = strconv.Itoa(s.Attempts)
+		}
+		tasks[rec.Process][name] = true
+	}
+
+	snapshot := make([]processSnapshot, 0, len(byProcess))
+	for process, s := range byProcess {
+		s.Tasks = len(tasks[process])
+		s.CacheRate = float64(s.Cached) / float64(s.Attempts)
+		snapshot = append(snapshot, *s)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Process < snapshot[j].Process })
+	return snapshot
+}
+
+// writeSnapshot writes a snapshot as a tab-separated file of process, task
+// count and cache rate, preceded by comments on where it was taken from
+func writeSnapshot(path string, trace *Trace, snapshot []processSnapshot) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Run snapshot recorded by nfu %s on %s\n", version, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# from %s", trace.Path)
+	if sum, err := fileSHA256(trace.Path); err == nil {
+		fmt.Fprintf(&b, " (sha256 %s)", sum)
+	}
+	fmt.Fprintf(&b, "\n# Update with: nfu snapshot save -i <trace> %s\n", path)
+	fmt.Fprintln(&b, "process\ttasks\tcache_rate")
+	for _, s := range snapshot {
+		fmt.Fprintf(&b, "%s\t%d\t%s\n", s.Process, s.Tasks, strconv.FormatFloat(math.Round(1000*s.CacheRate)/1000, 'f', -1, 64))
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// loadSnapshot reads a snapshot written by writeSnapshot
+func loadSnapshot(path string) ([]processSnapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening snapshot: %w", err)
+	}
+	defer file.Close()
+
+	var snapshot []processSnapshot
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "process\t") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("snapshot line %d: expected process, tasks and cache rate", lineNum)
+		}
+		tasks, err1 := strconv.Atoi(fields[1])
+		cacheRate, err2 := strconv.ParseFloat(fields[2], 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("snapshot line %d: invalid task count or cache rate", lineNum)
+		}
+		snapshot = append(snapshot, processSnapshot{Process: fields[0], Tasks: tasks, CacheRate: cacheRate})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// snapshotDiff compares one process between a snapshot and a run; Before or
+// After is nil if the process is missing from it
+type snapshotDiff struct {
+	Process       string
+	Before, After *processSnapshot
+	Problem       string // empty if the process matches the snapshot
+}
+
+// compareSnapshots compares the structure of a run with a snapshot: missing
+// and new processes, task counts that changed by more than tolerance and,
+// if cacheTolerance is not negative, cache rates that changed by more than
+// it
+func compareSnapshots(before, after []processSnapshot, tolerance, cacheTolerance float64) []snapshotDiff {
+	byProcess := make(map[string]*snapshotDiff)
+	var diffs []*snapshotDiff
+	get := func(process string) *snapshotDiff {
+		d, ok := byProcess[process]
+		if !ok {
+			d = &snapshotDiff{Process: process}
+			byProcess[process] = d
+			diffs = append(diffs, d)
+		}
+		return d
+	}
+	for i := range before {
+		get(before[i].Process).Before = &before[i]
+	}
+	for i := range after {
+		get(after[i].Process).After = &after[i]
+	}
+
+	result := make([]snapshotDiff, 0, len(diffs))
+	for _, d := range diffs {
+		switch {
+		case d.After == nil:
+			d.Problem = "missing"
+		case d.Before == nil:
+			d.Problem = "new"
+		case math.Abs(float64(d.After.Tasks-d.Before.Tasks)) > tolerance*float64(d.Before.Tasks):
+			d.Problem = "task count"
+		case cacheTolerance >= 0 && math.Abs(d.After.CacheRate-d.Before.CacheRate) > cacheTolerance:
+			d.Problem = "cache rate"
+		}
+		result = append(result, *d)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Process < result[j].Process })
+	return result
+}
+
+// runSnapshot implements the "snapshot" subcommand, recording the structure
+// of a run and comparing later runs against it
+func runSnapshot(args []string) error {
+	fs := newFlagSet("snapshot")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu snapshot save -i <trace> <snapshot>")
+		fmt.Fprintln(fs.Output(), "       nfu snapshot compare [flags] -i <trace> <snapshot>")
+		fs.PrintDefaults()
+	}
+	input := inputFlags(fs)
+	tolerance := fs.String("tolerance", "0%", "Allowed change of the task count of a process, e.g. 10%")
+	cacheTolerance := fs.String("cache-tolerance", "", "Allowed change of the cache rate of a process, e.g. 20% (default: not compared)")
+	if len(args) == 0 || (args[0] != "save" && args[0] != "compare") {
+		// -h prints the usage and exits with status 0
+		fs.Parse(args)
+		fs.Usage()
+		os.Exit(1)
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	tol, err := parseTolerance(*tolerance)
+	if err != nil {
+		return err
+	}
+	cacheTol := -1.0
+	if *cacheTolerance != "" {
+		if cacheTol, err = parseTolerance(*cacheTolerance); err != nil {
+			return err
+		}
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+
+	if action == "save" {
+		snapshot := takeSnapshot(trace.Records)
+		if err := writeSnapshot(path, trace, snapshot); err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot of %d processes written to %s\n", len(snapshot), path)
+		return nil
+	}
+
+	before, err := loadSnapshot(path)
+	if err != nil {
+		return err
+	}
+	diffs := compareSnapshots(before, takeSnapshot(trace.Records), tol, cacheTol)
+	count := func(s *processSnapshot) string {
+		if s == nil {
+			return "-"
+		}
+		return strconv.Itoa(s.Tasks)
+	}
+	rate := func(s *processSnapshot) string {
+		if s == nil {
+			return "-"
+		}
+		return FormatPercent(100 * s.CacheRate)
+	}
+
+	differ := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tSNAPSHOT TASKS\tTASKS\tSNAPSHOT CACHED\tCACHED\tRESULT")
+	for _, d := range diffs {
+		result := "OK"
+		if d.Problem != "" {
+			result = "DIFF (" + d.Problem + ")"
+			differ++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Process, count(d.Before), count(d.After),
+			rate(d.Before), rate(d.After), result)
+	}
+	w.Flush()
+	if differ > 0 {
+		return fmt.Errorf("%d of %d processes differ from the snapshot", differ, len(diffs))
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// inputFile is a file referenced by a samplesheet
+type inputFile struct {
+	Path  string
+	Size  int64
+	Known bool // the size could be determined
+}
+
+// stagedSample lists the input files of one sample
+type stagedSample struct {
+	Sample string
+	Files  []inputFile
+}
+
+// Size returns the total size of the files whose size is known
+func (s *stagedSample) Size() int64 {
+	var size int64
+	for _, f := range s.Files {
+		size += f.Size
+	}
+	return size
+}
+
+// remoteSchemes are the URL schemes of remote inputs Nextflow stages
+var remoteSchemes = []string{"s3://", "gs://", "az://", "http://", "https://", "ftp://"}
+
+// looksLikeFile reports whether a samplesheet value refers to a file
+func looksLikeFile(value string) bool {
+	for _, scheme := range remoteSchemes {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	if _, err := parseNumber(value); err == nil {
+		return false
+	}
+	return strings.ContainsAny(value, "/\\") || filepath.Ext(value) != ""
+}
+
+// publicObjectURL returns the HTTPS URL of an object in S3 or GCS, which
+// answers HEAD requests without credentials if the bucket is public
+func publicObjectURL(path string) (string, bool) {
+	scheme, rest, _ := strings.Cut(path, "://")
+	bucket, key, ok := strings.Cut(rest, "/")
+	if !ok || bucket == "" || key == "" {
+		return "", false
+	}
+	u := &url.URL{Scheme: "https", Path: "/" + key}
+	switch scheme {
+	case "s3":
+		// Buckets with dots do not match the certificate of the
+		// virtual-hosted endpoint and are addressed by path
+		if strings.Contains(bucket, ".") {
+			u.Host, u.Path = "s3.amazonaws.com", "/"+bucket+u.Path
+		} else {
+			u.Host = bucket + ".s3.amazonaws.com"
+		}
+	case "gs":
+		u.Host, u.Path = "storage.googleapis.com", "/"+bucket+u.Path
+	default:
+		return "", false
+	}
+	return u.String(), true
+}
+
+// statInput determines the size of an input file: local files are stat'ed,
+// HTTP(S) URLs and objects in public S3 and GCS buckets asked with a HEAD
+// request. Private buckets and Azure need credentials and are left unknown.
+func statInput(client *http.Client, path string) inputFile {
+	f := inputFile{Path: path}
+	target := path
+	switch {
+	case strings.HasPrefix(path, "s3://") || strings.HasPrefix(path, "gs://"):
+		var ok bool
+		if target, ok = publicObjectURL(path); !ok {
+			return f
+		}
+		fallthrough
+	case strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://"):
+		resp, err := client.Head(target)
+		if err != nil {
+			return f
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 {
+			f.Size, f.Known = resp.ContentLength, true
+		}
+	case strings.Contains(path, "://"):
+	default:
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			f.Size, f.Known = info.Size(), true
+		}
+	}
+	return f
+}
+
+// containsInput reports whether files contains a file with the given path
+func containsInput(files []inputFile, path string) bool {
+	for _, f := range files {
+		if f.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// readSamplesheet reads a CSV or TSV samplesheet. The sample is taken from
+// the "sample" or "id" column, or the first column; files are the values
+// that look like paths or URLs. Relative paths are resolved against the
+// directory of the samplesheet.
+func readSamplesheet(path string) ([]*stagedSample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening samplesheet: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".tsv" || ext == ".txt" {
+		reader.Comma = '\t'
+	}
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading samplesheet: %w", err)
+	}
+	sampleCol := 0
+	for i, col := range header {
+		if name := strings.ToLower(strings.TrimSpace(col)); name == "sample" || name == "id" {
+			sampleCol = i
+			break
+		}
+	}
+
+	var samples []*stagedSample
+	bySample := make(map[string]*stagedSample)
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading samplesheet: %w", err)
+		}
+		if sampleCol >= len(fields) {
+			continue
+		}
+		name := strings.TrimSpace(fields[sampleCol])
+		s, ok := bySample[name]
+		if !ok {
+			s = &stagedSample{Sample: name}
+			bySample[name] = s
+			samples = append(samples, s)
+		}
+		for i, value := range fields {
+			value = strings.TrimSpace(value)
+			if i == sampleCol || value == "" || !looksLikeFile(value) {
+				continue
+			}
+			if !strings.Contains(value, "://") && !filepath.IsAbs(value) {
+				value = filepath.Join(filepath.Dir(path), value)
+			}
+			if !containsInput(s.Files, value) {
+				s.Files = append(s.Files, inputFile{Path: value})
+			}
+		}
+	}
+	return samples, nil
+}
+
+// runStagein implements the "stagein" subcommand, estimating the volume and
+// time of staging the inputs of a samplesheet before a run is launched
+func runStagein(args []string) error {
+	fs := newFlagSet("stagein")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu stagein [flags] <samplesheet>")
+		fs.PrintDefaults()
+	}
+	bandwidthFlag := fs.String("bandwidth", "100 MB", "Total staging bandwidth per second")
+	fanOut := fs.Int("fan-out", 0, "Number of samples staged at the same time, sharing the bandwidth (default: all samples)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	bandwidth, err := ParseSize(*bandwidthFlag)
+	if err != nil || bandwidth <= 0 {
+		return fmt.Errorf("invalid bandwidth '%s'", *bandwidthFlag)
+	}
+	samples, err := readSamplesheet(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(samples) == 0 {
+		return fmt.Errorf("no samples found in %s", fs.Arg(0))
+	}
+	concurrent := len(samples)
+	if *fanOut > 0 && *fanOut < concurrent {
+		concurrent = *fanOut
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	var total int64
+	files, unknown := 0, 0
+	for _, s := range samples {
+		for i, f := range s.Files {
+			s.Files[i] = statInput(client, f.Path)
+			files++
+			if !s.Files[i].Known {
+				unknown++
+				slog.Debug("input size unknown", "path", f.Path)
+			}
+		}
+		total += s.Size()
+	}
+
+	// Rates are in bytes per second, as floats so that a bandwidth shared by
+	// more samples than it has bytes does not round down to zero
+	transfer := func(size int64, rate float64) time.Duration {
+		return time.Duration(float64(size) / rate * float64(time.Second))
+	}
+	perSample := float64(bandwidth) / float64(concurrent)
+	fmt.Printf("Inputs: %d samples, %d files, %s", len(samples), files, FormatSize(total))
+	if unknown > 0 {
+		fmt.Printf(" (size of %d files unknown)", unknown)
+	}
+	fmt.Println()
+	fmt.Printf("Staging time: %s at %s/s, %s/s per sample with %d samples staged at a time\n\n",
+		FormatDuration(transfer(total, float64(bandwidth))), FormatSize(bandwidth), FormatSize(int64(perSample)), concurrent)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SAMPLE\tFILES\tSIZE\tSTAGING TIME\tUNKNOWN")
+	for _, s := range samples {
+		missing := 0
+		for _, f := range s.Files {
+			if !f.Known {
+				missing++
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\n", s.Sample, len(s.Files), FormatSize(s.Size()),
+			FormatDuration(transfer(s.Size(), perSample)), missing)
+	}
+	return w.Flush()
+}
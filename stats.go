@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// percentile returns the p-th percentile (0-100) of values using linear
+// interpolation between the closest ranks
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// mean returns the arithmetic mean of values
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// median returns the 50th percentile of values
+func median(values []float64) float64 {
+	return percentile(values, 50)
+}
+
+// correlation returns the Pearson correlation coefficient of x and y
+func correlation(x, y []float64) float64 {
+	mx, my := mean(x), mean(y)
+	var sxx, syy, sxy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxx += dx * dx
+		syy += dy * dy
+		sxy += dx * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return math.NaN()
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+// regression holds the result of a simple least-squares linear fit
+type regression struct {
+	Slope     float64
+	Intercept float64
+	R2        float64
+	TStat     float64 // t-statistic of the slope
+}
+
+// linearRegression fits y = Intercept + Slope*x by ordinary least squares
+func linearRegression(x, y []float64) regression {
+	n := float64(len(x))
+	mx, my := mean(x), mean(y)
+
+	var sxx, sxy, syy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return regression{Intercept: my}
+	}
+
+	fit := regression{Slope: sxy / sxx}
+	fit.Intercept = my - fit.Slope*mx
+	if syy > 0 {
+		fit.R2 = sxy * sxy / (sxx * syy)
+	}
+
+	// Standard error of the slope from the residual sum of squares
+	if n > 2 {
+		rss := syy - fit.Slope*sxy
+		if rss <= 0 {
+			fit.TStat = math.Inf(1)
+		} else {
+			se := math.Sqrt(rss / (n - 2) / sxx)
+			fit.TStat = fit.Slope / se
+		}
+	}
+	return fit
+}
+
+// addDurations returns a+b, saturating at the limits of time.Duration
+// instead of wrapping around; overflow reports whether saturation occurred
+func addDurations(a, b time.Duration) (sum time.Duration, overflow bool) {
+	sum = a + b
+	switch {
+	case a > 0 && b > 0 && sum < 0:
+		return math.MaxInt64, true
+	case a < 0 && b < 0 && sum >= 0:
+		return math.MinInt64, true
+	}
+	return sum, false
+}
+
+// Total accumulates non-negative quantities such as nanoseconds or bytes in
+// arbitrary precision, so that sums over many tasks and runs cannot overflow
+// int64 (time.Duration tops out at about 292 years)
+type Total struct {
+	sum big.Int
+}
+
+// Add adds v to the total
+func (t *Total) Add(v int64) {
+	t.sum.Add(&t.sum, big.NewInt(v))
+}
+
+// Int64 returns the total, saturated at the int64 limits; ok is false when
+// the total does not fit
+func (t *Total) Int64() (value int64, ok bool) {
+	if t.sum.IsInt64() {
+		return t.sum.Int64(), true
+	}
+	if t.sum.Sign() < 0 {
+		return math.MinInt64, false
+	}
+	return math.MaxInt64, false
+}
+
+// Float64 returns the nearest float64 value of the total
+func (t *Total) Float64() float64 {
+	f, _ := new(big.Float).SetInt(&t.sum).Float64()
+	return f
+}
+
+// Duration interprets the total as nanoseconds; ok is false when it exceeds
+// the range of time.Duration
+func (t *Total) Duration() (d time.Duration, ok bool) {
+	nanos, ok := t.Int64()
+	return time.Duration(nanos), ok
+}
+
+// HMS splits a total of nanoseconds into whole hours, minutes and seconds
+func (t *Total) HMS() (hours *big.Int, minutes, seconds int) {
+	secs := new(big.Int).Quo(&t.sum, big.NewInt(int64(time.Second)))
+	hours, rem := new(big.Int).QuoRem(secs, big.NewInt(3600), new(big.Int))
+	return hours, int(rem.Int64() / 60), int(rem.Int64() % 60)
+}
+
+// Size renders a total of bytes like FormatSize, in PB for totals beyond
+// the int64 range
+func (t *Total) Size() string {
+	if v, ok := t.Int64(); ok {
+		return FormatSize(v)
+	}
+	return fmt.Sprintf("%.1f PB", t.Float64()/(1<<50))
+}
+
+// String renders a total of nanoseconds like time.Duration.String, falling
+// back to whole seconds for totals beyond the time.Duration range
+func (t *Total) String() string {
+	if d, ok := t.Duration(); ok {
+		return d.String()
+	}
+	hours, minutes, seconds := t.HMS()
+	return fmt.Sprintf("%sh%dm%ds", hours, minutes, seconds)
+}
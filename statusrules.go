@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// statusRule derives a status from the status and exit code Nextflow
+// recorded, e.g. WALLTIME for tasks killed by the scheduler
+type statusRule struct {
+	Derived string
+	Status  string // "" for any status
+	Exit    string // "" for any exit code
+}
+
+// matches reports whether the rule applies to a record
+func (r statusRule) matches(rec TraceRecord) bool {
+	return (r.Status == "" || r.Status == rec.Status) && (r.Exit == "" || r.Exit == rec.Exit)
+}
+
+// loadStatusRules reads a YAML mapping of derived statuses to the
+// conditions they replace, each an exit code, a status or a status and an
+// exit code, e.g.
+//
+//	WALLTIME: [ABORTED 140, 140]
+//	OOM:
+//	  - 137
+//
+// The first matching rule applies. Derived statuses other than COMPLETED
+// and CACHED count as failures.
+func loadStatusRules(filePath string) ([]statusRule, error) {
+	lists, err := loadYAMLLists(filePath, "status rules")
+	if err != nil {
+		return nil, err
+	}
+	var rules []statusRule
+	for _, list := range lists {
+		for _, item := range list.Items {
+			rule := statusRule{Derived: strings.ToUpper(list.Name)}
+			for _, token := range strings.Fields(item.Value) {
+				if _, err := strconv.Atoi(token); err == nil {
+					if rule.Exit != "" {
+						return nil, fmt.Errorf("status rules file line %d: more than one exit code in %q", item.Line, item.Value)
+					}
+					rule.Exit = token
+				} else {
+					if rule.Status != "" {
+						return nil, fmt.Errorf("status rules file line %d: more than one status in %q", item.Line, item.Value)
+					}
+					rule.Status = strings.ToUpper(token)
+				}
+			}
+			if rule.Status == "" && rule.Exit == "" {
+				return nil, fmt.Errorf("status rules file line %d: expected an exit code, a status or both", item.Line)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// applyStatusRules replaces the status of the records matching a rule of
+// the status rules file by the derived status and returns how many records
+// were reclassified
+func applyStatusRules(records []TraceRecord, filePath string) (int, error) {
+	if filePath == "" {
+		return 0, nil
+	}
+	rules, err := loadStatusRules(filePath)
+	if err != nil {
+		return 0, err
+	}
+	reclassified := 0
+	for i := range records {
+		for _, rule := range rules {
+			if rule.matches(records[i]) {
+				if records[i].Status != rule.Derived {
+					records[i].Status = rule.Derived
+					reclassified++
+				}
+				break
+			}
+		}
+	}
+	return reclassified, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// processSummary holds the per-task values of a process behind the
+// "summary" table
+type processSummary struct {
+	*ProcessStats
+	Runtimes   []float64 // per task, in nanoseconds
+	CPUPercent []float64 // per task with a plausible %cpu
+	PeakRSS    int64     // largest peak RSS of any task
+}
+
+// processSummaryRecord is the record of a process in the machine-readable
+// output formats of "summary"; shares are in percent
+type processSummaryRecord struct {
+	Process         string   `json:"process"`
+	Tasks           int      `json:"tasks"`
+	RuntimeMs       int64    `json:"runtime_ms"`
+	RuntimeShare    float64  `json:"runtime_share"`
+	MeanRuntimeMs   int64    `json:"mean_runtime_ms"`
+	MedianRuntimeMs int64    `json:"median_runtime_ms"`
+	MaxRuntimeMs    int64    `json:"max_runtime_ms"`
+	MeanCPUPercent  *float64 `json:"mean_cpu_percent,omitempty"`
+	CPUShare        float64  `json:"cpu_share"`
+	PeakRSSBytes    int64    `json:"peak_rss_bytes,omitempty"`
+}
+
+// summarizeProcesses aggregates the tasks of every process, the process
+// with the largest total runtime first
+func summarizeProcesses(records []TraceRecord) []*processSummary {
+	byProcess := make(map[string]*processSummary)
+	var summaries []*processSummary
+	for _, stats := range aggregateByProcess(records) {
+		s := &processSummary{ProcessStats: stats}
+		byProcess[stats.Process] = s
+		summaries = append(summaries, s)
+	}
+	for _, rec := range records {
+		s := byProcess[rec.Process]
+		s.Runtimes = append(s.Runtimes, float64(rec.Runtime()))
+		if rec.HasCPUPercent && rec.CPUSuspect == "" {
+			s.CPUPercent = append(s.CPUPercent, rec.CPUPercent)
+		}
+		s.PeakRSS = max(s.PeakRSS, rec.PeakRSS)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].Runtime > summaries[j].Runtime })
+	return summaries
+}
+
+// summaryRecords returns the records of the processes in the
+// machine-readable output formats
+func summaryRecords(summaries []*processSummary) []processSummaryRecord {
+	stats := make([]*ProcessStats, len(summaries))
+	var total time.Duration
+	for i, s := range summaries {
+		stats[i] = s.ProcessStats
+		total, _ = addDurations(total, s.Runtime)
+	}
+	cpuShare, _ := processShares(stats)
+
+	milliseconds := func(ns float64) int64 { return int64(ns / float64(time.Millisecond)) }
+	records := make([]processSummaryRecord, len(summaries))
+	for i, s := range summaries {
+		records[i] = processSummaryRecord{
+			Process:         s.Process,
+			Tasks:           s.Tasks,
+			RuntimeMs:       s.Runtime.Milliseconds(),
+			MeanRuntimeMs:   milliseconds(mean(s.Runtimes)),
+			MedianRuntimeMs: milliseconds(median(s.Runtimes)),
+			MaxRuntimeMs:    milliseconds(percentile(s.Runtimes, 100)),
+			CPUShare:        cpuShare[s.Process],
+			PeakRSSBytes:    s.PeakRSS,
+		}
+		if total > 0 {
+			records[i].RuntimeShare = 100 * float64(s.Runtime) / float64(total)
+		}
+		if len(s.CPUPercent) > 0 {
+			cpuPercent := mean(s.CPUPercent)
+			records[i].MeanCPUPercent = &cpuPercent
+		}
+	}
+	return records
+}
+
+// runSummary implements the "summary" subcommand, reporting task counts,
+// runtime, CPU usage and peak memory per process
+func runSummary(args []string) error {
+	fs := newFlagSet("summary")
+	input := inputFlags(fs)
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	summaries := summarizeProcesses(trace.Records)
+	records := summaryRecords(summaries)
+	if outputFormat != "text" {
+		return writeRecords(os.Stdout, records)
+	}
+
+	duration := func(ns float64) string {
+		if math.IsNaN(ns) {
+			return "-"
+		}
+		return FormatDuration(time.Duration(ns))
+	}
+	size := func(bytes int64) string {
+		if bytes <= 0 {
+			return "-"
+		}
+		return FormatSize(bytes)
+	}
+	var total time.Duration
+	var peak int64
+	tasks := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROCESS\tTASKS\tTOTAL RUNTIME\tSHARE\tMEAN\tMEDIAN\tMAX\tMEAN %CPU\tCPU SHARE\tPEAK RSS")
+	for i, s := range summaries {
+		total, _ = addDurations(total, s.Runtime)
+		peak = max(peak, s.PeakRSS)
+		tasks += s.Tasks
+		cpuPercent := "-"
+		if len(s.CPUPercent) > 0 {
+			cpuPercent = FormatPercent(mean(s.CPUPercent))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Process, s.Tasks, FormatDuration(s.Runtime),
+			FormatPercent(records[i].RuntimeShare), duration(mean(s.Runtimes)), duration(median(s.Runtimes)),
+			duration(percentile(s.Runtimes, 100)), cpuPercent, FormatPercent(records[i].CPUShare), size(s.PeakRSS))
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%s\t\t\t\t\t\t\t%s\n", tasks, FormatDuration(total), size(peak))
+	return w.Flush()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// findTaskAttempts returns the attempts of the task identified by query: a
+// task name, a task ID or a hash, also shortened (e.g. "3f/8a2c"). Attempts
+// of a task share its name and are sorted by attempt number.
+func findTaskAttempts(records []TraceRecord, query string) ([]TraceRecord, error) {
+	names := make(map[string]bool)
+	for _, rec := range records {
+		if rec.Name == query || rec.TaskID == query || (strings.Contains(query, "/") && strings.HasPrefix(rec.Hash, query)) {
+			names[rec.Name] = true
+		}
+	}
+	switch {
+	case len(names) == 0:
+		return nil, fmt.Errorf("no task '%s' found (give a task name, task ID or hash)", query)
+	case len(names) > 1:
+		var matches []string
+		for name := range names {
+			matches = append(matches, name)
+		}
+		sort.Strings(matches)
+		return nil, fmt.Errorf("'%s' matches %d tasks: %s", query, len(matches), strings.Join(matches, ", "))
+	}
+
+	var attempts []TraceRecord
+	for _, rec := range records {
+		if names[rec.Name] {
+			attempts = append(attempts, rec)
+		}
+	}
+	sort.SliceStable(attempts, func(i, j int) bool { return attempts[i].Attempt < attempts[j].Attempt })
+	return attempts, nil
+}
+
+// runTask implements the "task" subcommand, showing the details of a single
+// task with every attempt and the delays between them
+func runTask(args []string) error {
+	fs := newFlagSet("task")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu task [flags] -i <trace> <task name, ID or hash>")
+		fs.PrintDefaults()
+	}
+	input := inputFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	input.Read.Attempts = "all"
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+	attempts, err := findTaskAttempts(trace.Records, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	last := attempts[len(attempts)-1]
+	fmt.Printf("Task: %s\n", last.Name)
+	fmt.Printf("Process: %s\n", last.Process)
+	if last.Tag != "" {
+		fmt.Printf("Tag: %s\n", last.Tag)
+	}
+	status := last.Status
+	if status == "" {
+		status = "-"
+	}
+	fmt.Printf("Attempts: %d, final status %s\n", len(attempts), status)
+	if len(attempts) > 1 && !attempts[0].Submit.IsZero() && !last.Start.IsZero() {
+		fmt.Printf("First submission to final completion: %s\n", FormatDuration(last.End().Sub(attempts[0].Submit)))
+	}
+	fmt.Println()
+
+	value := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	timestamp := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format(time.DateTime)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ATTEMPT\tHASH\tSTATUS\tEXIT\tHOST\tCPUS\tMEMORY\tTIME\tSUBMITTED\tSTARTED\tRUNTIME\t%CPU\tPEAK RSS\tDELAY")
+	for i, rec := range attempts {
+		memory, limit, cpuPercent, peakRSS, delay := "-", "-", "-", "-", "-"
+		if rec.Memory > 0 {
+			memory = FormatSize(rec.Memory)
+		}
+		if rec.PeakRSS > 0 {
+			peakRSS = FormatSize(rec.PeakRSS)
+		}
+		if rec.Time > 0 {
+			limit = FormatDuration(rec.Time)
+		}
+		if rec.HasCPUPercent {
+			cpuPercent = FormatPercent(rec.CPUPercent)
+		}
+		// The delay is the time from the end of the previous attempt to the
+		// submission of this one, spent by Nextflow on the retry
+		if i > 0 && !attempts[i-1].Start.IsZero() && !rec.Submit.IsZero() {
+			delay = FormatSignedDuration(rec.Submit.Sub(attempts[i-1].End()))
+		}
+		cpus := "-"
+		if rec.CPUs > 0 {
+			cpus = strconv.Itoa(rec.CPUs)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", max(rec.Attempt, 1), value(rec.Hash),
+			value(rec.Status), value(rec.Exit), value(rec.Hostname), cpus, memory, limit, timestamp(rec.Submit),
+			timestamp(rec.Start), FormatDuration(rec.Runtime()), cpuPercent, peakRSS, delay)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// sampleTiming describes when the tasks of one sample ran
+type sampleTiming struct {
+	Sample   string
+	Submit   time.Time // first submission of a task of the sample
+	Complete time.Time // completion of the last task of the sample
+	Tasks    int
+	Done     bool // every task of the sample completed successfully
+
+	// Processes holds the time from the first submission to the last
+	// completion of the tasks of each process, including queueing and retries
+	Processes map[string]time.Duration
+}
+
+// Turnaround returns the time from the first submission to the completion
+// of the sample
+func (s *sampleTiming) Turnaround() time.Duration {
+	return s.Complete.Sub(s.Submit)
+}
+
+// collectSampleTimings follows every sample through the run. Samples are the
+// tags of per-sample processes, i.e. processes with more than one tag; tasks
+// of processes without tag or with a single one (e.g. MultiQC) belong to no
+// sample. Samples are returned in the order they completed.
+func collectSampleTimings(records []TraceRecord) []*sampleTiming {
+	samples := make(map[string]bool)
+	for _, tags := range tagsByProcess(records) {
+		if len(tags) > 1 {
+			for tag := range tags {
+				samples[tag] = true
+			}
+		}
+	}
+
+	type span struct{ from, to time.Time }
+	bySample := make(map[string]*sampleTiming)
+	spans := make(map[string]map[string]*span)
+	succeeded := make(map[string]bool) // by task name, attempts of a task share it
+	taskSample := make(map[string]string)
+	for _, rec := range records {
+		if !samples[rec.Tag] {
+			continue
+		}
+		s, ok := bySample[rec.Tag]
+		if !ok {
+			s = &sampleTiming{Sample: rec.Tag, Done: true, Processes: make(map[string]time.Duration)}
+			bySample[rec.Tag] = s
+			spans[rec.Tag] = make(map[string]*span)
+		}
+		s.Tasks++
+
+		from, to := rec.Submit, rec.End()
+		if from.IsZero() {
+			from = rec.Start
+		}
+		if rec.Incomplete() || rec.Start.IsZero() {
+			s.Done = false
+			continue
+		}
+		task := rec.Name
+		if task == "" {
+			task = rec.Process + "\x00" + rec.Tag
+		}
+		taskSample[task] = rec.Tag
+		succeeded[task] = succeeded[task] || rec.Status == "" || isSuccess(rec.Status)
+
+		if s.Submit.IsZero() || from.Before(s.Submit) {
+			s.Submit = from
+		}
+		if to.After(s.Complete) {
+			s.Complete = to
+		}
+		sp := spans[rec.Tag][rec.Process]
+		if sp == nil {
+			sp = &span{from: from, to: to}
+			spans[rec.Tag][rec.Process] = sp
+		}
+		if from.Before(sp.from) {
+			sp.from = from
+		}
+		if to.After(sp.to) {
+			sp.to = to
+		}
+	}
+
+	// Tasks whose attempts all failed leave their sample unfinished
+	for task, ok := range succeeded {
+		if !ok {
+			bySample[taskSample[task]].Done = false
+		}
+	}
+
+	timings := make([]*sampleTiming, 0, len(bySample))
+	for tag, s := range bySample {
+		for process, sp := range spans[tag] {
+			s.Processes[process] = sp.to.Sub(sp.from)
+		}
+		timings = append(timings, s)
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		if !timings[i].Complete.Equal(timings[j].Complete) {
+			return timings[i].Complete.Before(timings[j].Complete)
+		}
+		return timings[i].Sample < timings[j].Sample
+	})
+	return timings
+}
+
+// samplesPerDay converts a number of samples completed over a period into
+// samples per day
+func samplesPerDay(samples int, period time.Duration) float64 {
+	if period <= 0 {
+		return 0
+	}
+	return float64(samples) / period.Hours() * 24
+}
+
+// runThroughput implements the "throughput" subcommand, reporting how many
+// samples the run completed per day and how long each sample took
+func runThroughput(args []string) error {
+	fs := newFlagSet("throughput")
+	input := inputFlags(fs)
+	fs.Parse(args)
+
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	timings := collectSampleTimings(trace.Records)
+	if len(timings) == 0 {
+		return fmt.Errorf("no samples found (samples are the tags of processes with more than one tag)")
+	}
+
+	var runStart time.Time
+	for _, rec := range trace.Records {
+		first := rec.Submit
+		if first.IsZero() {
+			first = rec.Start
+		}
+		if !first.IsZero() && (runStart.IsZero() || first.Before(runStart)) {
+			runStart = first
+		}
+	}
+	var done []*sampleTiming
+	var turnarounds []float64
+	for _, s := range timings {
+		if s.Done {
+			done = append(done, s)
+			turnarounds = append(turnarounds, float64(s.Turnaround()))
+		}
+	}
+
+	fmt.Printf("Samples: %d completed, %d not completed\n", len(done), len(timings)-len(done))
+	if len(done) > 0 {
+		last := done[len(done)-1].Complete
+		fmt.Printf("Throughput: %.1f samples/day (%d samples in %s from the first submission)\n",
+			samplesPerDay(len(done), last.Sub(runStart)), len(done), FormatDuration(last.Sub(runStart)))
+		if len(done) > 1 {
+			// Completions after the first one show the rate of a full pipeline
+			first := done[0].Complete
+			fmt.Printf("Steady-state throughput: %.1f samples/day (between the first and the last sample completed)\n",
+				samplesPerDay(len(done)-1, last.Sub(first)))
+		}
+		fmt.Printf("Turnaround: median %s, max %s\n",
+			FormatDuration(time.Duration(median(turnarounds))), FormatDuration(time.Duration(percentile(turnarounds, 100))))
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SAMPLE\tTASKS\tSUBMITTED\tCOMPLETED\tTURNAROUND")
+	for _, s := range timings {
+		submitted, completed, turnaround := "-", "-", "-"
+		if !s.Submit.IsZero() {
+			submitted = s.Submit.Format(time.DateTime)
+		}
+		if s.Done {
+			completed, turnaround = s.Complete.Format(time.DateTime), FormatDuration(s.Turnaround())
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", s.Sample, s.Tasks, submitted, completed, turnaround)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// toolStats aggregates the resource usage of all processes running a tool
+type toolStats struct {
+	Tool      string
+	Processes []string
+	Tasks     int
+	Runtime   time.Duration
+	CPUHours  float64 // allocated CPUs times runtime
+	PeakRSS   int64
+}
+
+// toolRecord is a tool in the machine-readable output formats of "tools";
+// the share of CPU hours is in percent
+type toolRecord struct {
+	Tool         string   `json:"tool"`
+	Processes    []string `json:"processes"`
+	Tasks        int      `json:"tasks"`
+	RuntimeMs    int64    `json:"runtime_ms"`
+	CPUHours     float64  `json:"cpu_hours"`
+	CPUShare     float64  `json:"cpu_share"`
+	PeakRSSBytes int64    `json:"peak_rss_bytes,omitempty"`
+}
+
+// toolOf returns the tool a process runs: the group of the mapping file it
+// matches, the first tool of its nf-core module or, by nf-core convention,
+// the part of the process name before the first underscore
+func toolOf(process string, mapping ProcessGroups, modules *moduleIndex) string {
+	if tool := mapping.Group(process); tool != process {
+		return tool
+	}
+	if modules != nil {
+		if info := modules.Lookup(process); info != nil && len(info.Tools) > 0 {
+			return info.Tools[0]
+		}
+	}
+	tool, _, _ := strings.Cut(shortProcessName(process), "_")
+	return strings.ToLower(tool)
+}
+
+// aggregateByTool sums up the resource usage of the processes of every
+// tool, the largest CPU usage first
+func aggregateByTool(records []TraceRecord, toolOf func(string) string) []*toolStats {
+	byTool := make(map[string]*toolStats)
+	processTool := make(map[string]string)
+	var tools []*toolStats
+	for _, rec := range records {
+		name, ok := processTool[rec.Process]
+		if !ok {
+			name = toolOf(rec.Process)
+			processTool[rec.Process] = name
+		}
+		t, ok := byTool[name]
+		if !ok {
+			t = &toolStats{Tool: name}
+			byTool[name] = t
+			tools = append(tools, t)
+		}
+		if !containsString(t.Processes, rec.Process) {
+			t.Processes = append(t.Processes, rec.Process)
+		}
+		t.Tasks++
+		t.Runtime, _ = addDurations(t.Runtime, rec.Runtime())
+		t.CPUHours += float64(max(rec.CPUs, 1)) * rec.Runtime().Hours()
+		t.PeakRSS = max(t.PeakRSS, rec.PeakRSS)
+	}
+	sort.SliceStable(tools, func(i, j int) bool { return tools[i].CPUHours > tools[j].CPUHours })
+	return tools
+}
+
+// runTools implements the "tools" subcommand, rolling the resource usage of
+// processes up to the tools they run
+func runTools(args []string) error {
+	fs := newFlagSet("tools")
+	input := inputFlags(fs)
+	mappingFile := fs.String("tools", "", "YAML file mapping tools to the processes running them, in the format of --groups")
+	modulesDir := fs.String("modules", "", "Modules directory of the pipeline, e.g. modules/nf-core, to take the tool of every process from")
+	fetch := fs.Bool("fetch", false, "Look up modules not found in --modules in the nf-core/modules repository on GitHub")
+	fs.Parse(args)
+
+	var mapping ProcessGroups
+	if *mappingFile != "" {
+		var err error
+		if mapping, err = loadProcessGroups(*mappingFile); err != nil {
+			return err
+		}
+	}
+	var modules *moduleIndex
+	if *modulesDir != "" || *fetch {
+		var err error
+		if modules, err = newModuleIndex(*modulesDir, *fetch); err != nil {
+			return fmt.Errorf("error reading modules directory: %w", err)
+		}
+	}
+	trace, err := loadTrace(fs, input)
+	if err != nil {
+		return err
+	}
+
+	tools := aggregateByTool(trace.Records, func(process string) string { return toolOf(process, mapping, modules) })
+	total := 0.0
+	for _, t := range tools {
+		total += t.CPUHours
+	}
+	if outputFormat != "text" {
+		records := make([]toolRecord, len(tools))
+		for i, t := range tools {
+			records[i] = toolRecord{
+				Tool:         t.Tool,
+				Processes:    t.Processes,
+				Tasks:        t.Tasks,
+				RuntimeMs:    t.Runtime.Milliseconds(),
+				CPUHours:     t.CPUHours,
+				PeakRSSBytes: t.PeakRSS,
+			}
+			if total > 0 {
+				records[i].CPUShare = 100 * t.CPUHours / total
+			}
+		}
+		return writeRecords(os.Stdout, records)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tPROCESSES\tTASKS\tRUNTIME\tCPU HOURS\tSHARE\tPEAK MEMORY")
+	for _, t := range tools {
+		share := 0.0
+		if total > 0 {
+			share = 100 * t.CPUHours / total
+		}
+		peak := "-"
+		if t.PeakRSS > 0 {
+			peak = FormatSize(t.PeakRSS)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%.1f\t%s\t%s\n", t.Tool, len(t.Processes), t.Tasks,
+			FormatDuration(t.Runtime), t.CPUHours, FormatPercent(share), peak)
+	}
+	return w.Flush()
+}
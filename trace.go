@@ -0,0 +1,1002 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TraceRecord represents a single row from the execution trace file
+type TraceRecord struct {
+	TaskID        string
+	Hash          string
+	Name          string
+	Process       string
+	Tag           string
+	Status        string
+	Exit          string
+	Attempt       int
+	CPUs          int
+	Memory        int64         // requested memory in bytes
+	Time          time.Duration // requested time limit
+	Submit        time.Time
+	Start         time.Time
+	Complete      time.Time
+	Duration      time.Duration
+	Realtime      time.Duration
+	CPUPercent    float64
+	HasCPUPercent bool  // %cpu was recorded; a missing value is not the same as 0%
+	PeakRSS       int64 // peak resident set size in bytes
+	PeakVmem      int64 // peak virtual memory in bytes
+	Rchar         int64 // bytes read, including from the page cache
+	Wchar         int64 // bytes written
+	Hostname      string
+	Queue         string // queue or partition of the executor
+
+	CPUSuspect string // reason why the %cpu value looks like a measurement problem
+	ClockSkew  string // timestamps out of order, e.g. started before it was submitted
+	Incident   string // known incidents the task ran during, kept with --incident-policy flag
+	Run        int    // run of a trace appended to by several runs, counted from 0
+
+	Fields []string // raw field values, kept with ReadOptions.KeepFields
+}
+
+// Runtime returns the task execution time, falling back to the duration
+// (which includes scheduling overhead) when realtime was not recorded
+func (r TraceRecord) Runtime() time.Duration {
+	if r.Realtime > 0 {
+		return r.Realtime
+	}
+	return r.Duration
+}
+
+// End returns the completion time of the task, estimated from the start
+// time and runtime when the complete timestamp is missing
+func (r TraceRecord) End() time.Time {
+	if !r.Complete.IsZero() {
+		return r.Complete
+	}
+	return r.Start.Add(r.Runtime())
+}
+
+// Incomplete reports whether the task was submitted or started but never
+// completed
+func (r TraceRecord) Incomplete() bool {
+	switch {
+	case r.Status == "ABORTED" || r.Status == "RUNNING" || r.Status == "SUBMITTED":
+		return true
+	case r.Status == "" && (!r.Submit.IsZero() || !r.Start.IsZero()) && r.Complete.IsZero() && r.Runtime() == 0:
+		return true
+	}
+	return false
+}
+
+// Trace holds the parsed contents of an execution trace file
+type Trace struct {
+	Path    string   // the file read, or the files joined by ", "
+	Files   []string // the files read, "stdin" for standard input
+	Columns []string
+	Records []TraceRecord
+
+	Truncated   bool         // the last line was cut off, e.g. by a crashed run
+	Runs        int          // runs appended to the files, each starting with a header, and files read
+	Explanation *explanation // how the trace was interpreted, collected with ReadOptions.Explain
+}
+
+// HasColumn reports whether the trace header contains the given column
+func (t *Trace) HasColumn(name string) bool {
+	for _, col := range t.Columns {
+		if col == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IncompleteTasks returns the tasks that were submitted or started but
+// never completed, e.g. because the run was killed while they were running
+func (t *Trace) IncompleteTasks() []TraceRecord {
+	var incomplete []TraceRecord
+	for _, rec := range t.Records {
+		if rec.Incomplete() {
+			incomplete = append(incomplete, rec)
+		}
+	}
+	return incomplete
+}
+
+// warnIncomplete logs when a trace looks like the record of a run that ended
+// abruptly; statistics then include the tasks that were aborted or still
+// running, unless they are filtered out with --status
+func warnIncomplete(trace *Trace) {
+	incomplete := trace.IncompleteTasks()
+	if len(incomplete) == 0 && !trace.Truncated {
+		return
+	}
+	slog.Warn("run is incomplete, statistics include tasks that did not complete (see --status)", "path", trace.Path,
+		"incomplete_tasks", len(incomplete), "truncated", trace.Truncated)
+	for _, rec := range incomplete {
+		slog.Debug("incomplete task", "name", rec.Name, "status", rec.Status)
+	}
+}
+
+// tagSuffix matches the " (tag)" suffix Nextflow appends to task names
+var tagSuffix = regexp.MustCompile(`^(.*?)\s*\((.*)\)$`)
+
+// ReadOptions controls how trace files are parsed
+type ReadOptions struct {
+	Strict     bool // fail on the first malformed field instead of skipping it with a warning
+	Explain    bool // record how columns and values were interpreted
+	KeepFields bool // keep the raw field values of every record
+
+	// ClockSkew is the policy for timestamps out of order because of clock
+	// skew between nodes: "clamp" (the default) moves them into order,
+	// "flag" keeps them and "drop" drops the affected records
+	ClockSkew string
+
+	// Since and Until restrict the trace to tasks active in a time window:
+	// tasks completed at or after Since and submitted at or before Until.
+	// A time of day refers to its first occurrence after the run started.
+	Since, Until string
+
+	// Attempts selects which attempts of retried tasks are kept: "all"
+	// (the default, the true cost of a run), "final" (the logical pipeline
+	// time) or "first"
+	Attempts string
+
+	// Incidents is a CSV file of outages and maintenance windows; tasks
+	// submitted or running during one are handled according to
+	// IncidentPolicy: "drop" (the default) or "flag"
+	Incidents, IncidentPolicy string
+
+	// Runs selects among the runs of a trace appended to by several runs
+	// (trace.overwrite = false), each starting with a repeated header:
+	// "merge" (the default) keeps all with every task once, "split" keeps
+	// the tasks of every run, "last" the last and a number N the N-th run
+	Runs string
+
+	// StatusRules is a YAML file of rules deriving statuses such as OOM or
+	// WALLTIME from the status and exit code, applied before the Filter
+	StatusRules string
+
+	// Filter restricts the trace to tasks of given statuses, processes,
+	// tags and submission times
+	Filter RowFilter
+}
+
+// selectRun keeps the records of the run selected by mode out of a trace
+// holding runs runs
+func selectRun(records []TraceRecord, mode string, runs int) ([]TraceRecord, error) {
+	if mode == "" || mode == "merge" || mode == "split" {
+		return records, nil
+	}
+	selected := runs - 1
+	if mode != "last" {
+		n, err := strconv.Atoi(mode)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("unknown runs mode '%s' (use merge, split, last or the number of a run)", mode)
+		}
+		if n > runs {
+			return nil, fmt.Errorf("run %d selected, but the trace holds %d runs", n, runs)
+		}
+		selected = n - 1
+	}
+	var result []TraceRecord
+	for _, rec := range records {
+		if rec.Run == selected {
+			result = append(result, rec)
+		}
+	}
+	slog.Debug("selected run", "run", selected+1, "runs", runs, "records", len(result))
+	return result, nil
+}
+
+// selectAttempts keeps the attempts of every task selected by mode. Attempts
+// of a task share its name; a missing attempt number counts as the first.
+func selectAttempts(records []TraceRecord, mode string) ([]TraceRecord, error) {
+	if mode == "" || mode == "all" {
+		return records, nil
+	}
+	if mode != "final" && mode != "first" {
+		return nil, fmt.Errorf("unknown attempts mode '%s' (use all, final or first)", mode)
+	}
+
+	taskKey := func(rec TraceRecord) string {
+		if rec.Name != "" {
+			return rec.Name
+		}
+		return rec.Process + "\x00" + rec.Tag
+	}
+	attempt := func(rec TraceRecord) int {
+		return max(rec.Attempt, 1)
+	}
+
+	selected := make(map[string]int)
+	for _, rec := range records {
+		key := taskKey(rec)
+		current, ok := selected[key]
+		switch {
+		case !ok:
+			selected[key] = attempt(rec)
+		case mode == "final" && attempt(rec) > current:
+			selected[key] = attempt(rec)
+		case mode == "first" && attempt(rec) < current:
+			selected[key] = attempt(rec)
+		}
+	}
+
+	var result []TraceRecord
+	for _, rec := range records {
+		if attempt(rec) == selected[taskKey(rec)] {
+			result = append(result, rec)
+		}
+	}
+	slog.Debug("selected attempts", "mode", mode, "records", len(result), "dropped", len(records)-len(result))
+	return result, nil
+}
+
+// checkClockSkew finds records whose start precedes their submission or whose
+// completion precedes their start and handles them according to policy.
+// Clamped records start when submitted and complete after their runtime.
+func checkClockSkew(records []TraceRecord, policy string) ([]TraceRecord, int, error) {
+	if policy != "clamp" && policy != "flag" && policy != "drop" {
+		return nil, 0, fmt.Errorf("unknown clock skew policy '%s' (use clamp, flag or drop)", policy)
+	}
+
+	affected := 0
+	result := records[:0]
+	for _, rec := range records {
+		var reasons []string
+		if !rec.Submit.IsZero() && !rec.Start.IsZero() && rec.Start.Before(rec.Submit) {
+			reasons = append(reasons, fmt.Sprintf("started %s before submission", FormatDuration(rec.Submit.Sub(rec.Start))))
+		}
+		if !rec.Start.IsZero() && !rec.Complete.IsZero() && rec.Complete.Before(rec.Start) {
+			reasons = append(reasons, fmt.Sprintf("completed %s before start", FormatDuration(rec.Start.Sub(rec.Complete))))
+		}
+		if len(reasons) == 0 {
+			result = append(result, rec)
+			continue
+		}
+
+		affected++
+		rec.ClockSkew = strings.Join(reasons, ", ")
+		switch policy {
+		case "drop":
+			continue
+		case "clamp":
+			if rec.Start.Before(rec.Submit) {
+				rec.Start = rec.Submit
+			}
+			if rec.Complete.Before(rec.Start) {
+				rec.Complete = rec.Start.Add(rec.Runtime())
+			}
+		}
+		result = append(result, rec)
+	}
+	return result, affected, nil
+}
+
+// resolveTimeBound parses a --since/--until value: a timestamp in one of the
+// trace formats, a date with an optional time, or a time of day, which refers
+// to its first occurrence after the run started (so "02:00" works for runs
+// started the evening before)
+func resolveTimeBound(value string, runStart time.Time) (time.Time, error) {
+	if ts, err := ParseTimestamp(value); err == nil {
+		return ts, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if ts, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return ts, nil
+		}
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		clock, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+		if runStart.IsZero() {
+			return time.Time{}, fmt.Errorf("time of day '%s' needs a trace with submit or start times", value)
+		}
+		ts := time.Date(runStart.Year(), runStart.Month(), runStart.Day(),
+			clock.Hour(), clock.Minute(), clock.Second(), 0, runStart.Location())
+		if ts.Before(runStart) {
+			ts = ts.AddDate(0, 0, 1)
+		}
+		return ts, nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported time '%s' (use e.g. '2024-03-01 02:00' or '02:00')", value)
+}
+
+// filterTimeWindow keeps the tasks active between since and until; tasks
+// without timestamps cannot be placed and are dropped
+func filterTimeWindow(records []TraceRecord, since, until string) ([]TraceRecord, error) {
+	if since == "" && until == "" {
+		return records, nil
+	}
+
+	runStart := runStart(records)
+	var from, to time.Time
+	var err error
+	if since != "" {
+		if from, err = resolveTimeBound(since, runStart); err != nil {
+			return nil, err
+		}
+	}
+	if until != "" {
+		if to, err = resolveTimeBound(until, runStart); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []TraceRecord
+	for _, rec := range records {
+		if submitted(rec).IsZero() {
+			continue
+		}
+		if !from.IsZero() && rec.End().Before(from) {
+			continue
+		}
+		if !to.IsZero() && submitted(rec).After(to) {
+			continue
+		}
+		result = append(result, rec)
+	}
+	slog.Debug("filtered time window", "since", from, "until", to, "records", len(result), "dropped", len(records)-len(result))
+	return result, nil
+}
+
+// FieldError describes a trace field whose value could not be parsed
+type FieldError struct {
+	Line   int
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("line %d: error parsing %s '%s': %v", e.Line, e.Column, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// readTrace parses an execution trace file into records
+func readTrace(filePath string, opts ReadOptions) (*Trace, error) {
+	return readTraces([]string{filePath}, opts)
+}
+
+// readTraces parses one or more trace files into a single trace, each file
+// a run of its own as if the files were concatenated; "-" reads stdin
+func readTraces(paths []string, opts ReadOptions) (*Trace, error) {
+	p := newTraceParser(opts)
+	for _, path := range paths {
+		r, name, err := openTrace(path)
+		if err != nil {
+			return nil, err
+		}
+		err = p.parse(r, name)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p.finish()
+}
+
+// openTrace opens a trace file, or stdin for "-", decompressing it if it
+// is gzip-compressed
+func openTrace(path string) (io.ReadCloser, string, error) {
+	var r io.ReadCloser = os.Stdin
+	name := "stdin"
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("error opening file: %w", err)
+		}
+		r, name = file, path
+	}
+
+	// Compressed traces are recognized by their content rather than by the
+	// extension, so that compressed stdin works too
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			r.Close()
+			return nil, "", fmt.Errorf("error decompressing %s: %w", name, err)
+		}
+		return readCloser{gz, r}, name, nil
+	}
+	return readCloser{buffered, r}, name, nil
+}
+
+// readCloser reads from a reader wrapping a file and closes the file
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// parseTrace parses trace data read from r; name identifies the source in
+// the returned Trace
+func parseTrace(r io.Reader, name string, opts ReadOptions) (*Trace, error) {
+	p := newTraceParser(opts)
+	if err := p.parse(r, name); err != nil {
+		return nil, err
+	}
+	return p.finish()
+}
+
+// traceParser collects the records of one or more trace sources into a
+// trace
+type traceParser struct {
+	opts  ReadOptions
+	trace *Trace
+
+	// Traces concatenated with cat, or appended to by resumed runs, repeat
+	// the header and may contain the same task more than once; a task
+	// attempt is identified by its hash, within its run if only one run is
+	// selected
+	seen       map[string]bool
+	duplicates int
+	headers    int // repeated header lines
+	run        int // run of the records being read, counting from 0
+	mergeRuns  bool
+}
+
+func newTraceParser(opts ReadOptions) *traceParser {
+	return &traceParser{
+		opts:      opts,
+		trace:     &Trace{},
+		seen:      make(map[string]bool),
+		mergeRuns: opts.Runs == "" || opts.Runs == "merge",
+	}
+}
+
+// parse reads the records of one source. The delimiter, a tab or a comma
+// (Nextflow writes CSV with trace.sep = ','), is detected from the header.
+// Every source after the first is a new run; sources whose columns differ
+// have their fields rearranged into the columns of the trace, the union of
+// the columns of all sources.
+func (p *traceParser) parse(r io.Reader, name string) error {
+	opts, trace := p.opts, p.trace
+	scanner := bufio.NewScanner(r)
+
+	if !scanner.Scan() {
+		if scanner.Err() == nil {
+			return fmt.Errorf("error reading header line: %s is empty", name)
+		}
+		return fmt.Errorf("error reading header line of %s: %w", name, scanner.Err())
+	}
+	// Files edited on Windows may start with a byte order mark and use CRLF line endings
+	header := strings.TrimPrefix(scanner.Text(), "\ufeff")
+	header = strings.TrimSuffix(header, "\r")
+	sep := traceSeparator(header)
+	columns, err := parseHeader(header, sep)
+	if err != nil {
+		return err
+	}
+
+	if trace.Files != nil {
+		p.run++
+	}
+	trace.Files = append(trace.Files, name)
+	trace.Path = strings.Join(trace.Files, ", ")
+	// index maps the fields of this source to the columns of the trace, nil
+	// if they are the same
+	var index []int
+	if trace.Columns == nil {
+		trace.Columns = columns
+	} else if !slices.Equal(columns, trace.Columns) {
+		index = make([]int, len(columns))
+		for i, col := range columns {
+			index[i] = slices.Index(trace.Columns, col)
+			if index[i] < 0 {
+				trace.Columns = append(trace.Columns, col)
+				index[i] = len(trace.Columns) - 1
+			}
+		}
+		slog.Debug("columns of trace differ from the previous traces", "path", name)
+	}
+	if opts.Explain {
+		if trace.Explanation == nil {
+			trace.Explanation = newExplanation(nil)
+		}
+		trace.Explanation.addColumns(trace.Columns)
+	}
+
+	addLine := func(lineNum int, fields []string) error {
+		if opts.Strict && len(fields) != len(columns) {
+			return fmt.Errorf("line %d: expected %d fields, found %d", lineNum, len(columns), len(fields))
+		}
+		if index != nil {
+			fields = rearrangeFields(fields, index, len(trace.Columns))
+		}
+
+		rec, fieldErrs := parseRecord(trace.Columns, fields)
+		rec.Run = p.run
+		if rec.Hash != "" {
+			key := rec.Hash + "\x00" + strconv.Itoa(rec.Attempt)
+			if !p.mergeRuns {
+				key += "\x00" + strconv.Itoa(rec.Run)
+			}
+			if p.seen[key] {
+				p.duplicates++
+				return nil
+			}
+			p.seen[key] = true
+		}
+
+		if opts.KeepFields {
+			rec.Fields = fields
+		}
+		if trace.Explanation != nil {
+			trace.Explanation.Records++
+			trace.Explanation.observe(fields)
+			if index == nil && len(fields) != len(columns) {
+				trace.Explanation.FieldCount = append(trace.Explanation.FieldCount,
+					fmt.Sprintf("line %d: expected %d fields, found %d", lineNum, len(columns), len(fields)))
+			}
+		}
+
+		for _, fieldErr := range fieldErrs {
+			fieldErr.Line = lineNum
+			if opts.Strict {
+				return fieldErr
+			}
+			if trace.Explanation != nil {
+				trace.Explanation.Malformed = append(trace.Explanation.Malformed, fieldErr)
+			}
+			slog.Warn("skipping malformed field", "path", name, "line", fieldErr.Line, "column", fieldErr.Column,
+				"value", fieldErr.Value, "error", fieldErr.Err)
+		}
+		trace.Records = append(trace.Records, rec)
+		return nil
+	}
+
+	// A line with too few fields is held back until the next line is read:
+	// as the last line of the file or of a run, followed by the header the
+	// next run appended, it was cut off by a run that ended abruptly
+	var pending []string
+	pendingLine := 0
+	dropPending := func() {
+		trace.Truncated = true
+		slog.Warn("skipping truncated line, the run probably ended abruptly", "path", name, "line", pendingLine)
+		pending = nil
+	}
+
+	lineNum := 1
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			if trace.Explanation != nil {
+				trace.Explanation.Blank++
+			}
+			continue
+		}
+		if strings.TrimPrefix(line, "\ufeff") == header {
+			if pending != nil {
+				dropPending()
+			}
+			p.headers++
+			p.run++
+			continue
+		}
+
+		if pending != nil {
+			if err := addLine(pendingLine, pending); err != nil {
+				return err
+			}
+			pending = nil
+		}
+		fields := strings.Split(line, sep)
+		if len(fields) < len(columns) {
+			pending, pendingLine = fields, lineNum
+			continue
+		}
+		if err := addLine(lineNum, fields); err != nil {
+			return err
+		}
+	}
+
+	if pending != nil {
+		dropPending()
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error scanning %s: %w", name, err)
+	}
+	return nil
+}
+
+// rearrangeFields puts the fields of a source into the columns of the
+// trace at the given index, with "-" for columns the source does not have
+func rearrangeFields(fields []string, index []int, columns int) []string {
+	rearranged := make([]string, columns)
+	for i := range rearranged {
+		rearranged[i] = "-"
+	}
+	for i, field := range fields {
+		if i < len(index) {
+			rearranged[index[i]] = field
+		}
+	}
+	return rearranged
+}
+
+// finish selects and filters the records read and returns the trace
+func (p *traceParser) finish() (*Trace, error) {
+	opts, trace, name := p.opts, p.trace, p.trace.Path
+	reclassified, err := applyStatusRules(trace.Records, opts.StatusRules)
+	if err != nil {
+		return nil, err
+	}
+	if reclassified > 0 {
+		slog.Debug("reclassified task statuses", "path", name, "records", reclassified)
+	}
+	// dropped records how many records a selection left out, for --explain
+	dropped := func(before int, format string, args ...any) {
+		if n := before - len(trace.Records); n > 0 && trace.Explanation != nil {
+			trace.Explanation.Dropped = append(trace.Explanation.Dropped, fmt.Sprintf("%d ", n)+fmt.Sprintf(format, args...))
+		}
+	}
+
+	trace.Runs = p.run + 1
+	before := len(trace.Records)
+	if trace.Records, err = selectRun(trace.Records, opts.Runs, trace.Runs); err != nil {
+		return nil, err
+	}
+	dropped(before, "of other runs (--runs %s)", opts.Runs)
+	before = len(trace.Records)
+	if trace.Records, err = selectAttempts(trace.Records, opts.Attempts); err != nil {
+		return nil, err
+	}
+	dropped(before, "of other attempts of retried tasks (--attempts %s)", opts.Attempts)
+	skewPolicy := opts.ClockSkew
+	if skewPolicy == "" {
+		skewPolicy = "clamp"
+	}
+	skewed := 0
+	before = len(trace.Records)
+	if trace.Records, skewed, err = checkClockSkew(trace.Records, skewPolicy); err != nil {
+		return nil, err
+	}
+	dropped(before, "with timestamps out of order (--clock-skew %s)", skewPolicy)
+	if skewed > 0 {
+		slog.Warn("timestamps out of order, probably clock skew between nodes", "path", name,
+			"records", skewed, "policy", skewPolicy)
+	}
+	before = len(trace.Records)
+	if trace.Records, err = filterTimeWindow(trace.Records, opts.Since, opts.Until); err != nil {
+		return nil, err
+	}
+	dropped(before, "outside of the time window (--since, --until)")
+	before = len(trace.Records)
+	if trace.Records, err = filterRows(trace.Records, opts.Filter); err != nil {
+		return nil, err
+	}
+	dropped(before, "not matching the filters (--status, --process, --tag, --submitted-after, --submitted-before)")
+	incidentPolicy := opts.IncidentPolicy
+	if incidentPolicy == "" {
+		incidentPolicy = "drop"
+	}
+	duringIncidents := 0
+	before = len(trace.Records)
+	if trace.Records, duringIncidents, err = applyIncidents(trace.Records, opts.Incidents, incidentPolicy); err != nil {
+		return nil, err
+	}
+	dropped(before, "of tasks that ran during known incidents (--incident-policy %s)", incidentPolicy)
+	if duringIncidents > 0 {
+		slog.Warn("tasks ran during known incidents", "path", name, "records", duringIncidents, "policy", incidentPolicy)
+	}
+	if p.duplicates > 0 {
+		slog.Warn("dropped duplicate task rows", "path", name, "count", p.duplicates)
+	}
+	if p.headers > 0 {
+		slog.Debug("skipped repeated header lines", "path", name, "count", p.headers)
+	}
+	if trace.Explanation != nil {
+		trace.Explanation.Duplicates = p.duplicates
+		trace.Explanation.Headers = p.headers
+		trace.Explanation.ClockSkew = skewed
+		trace.Explanation.ClockSkewPolicy = skewPolicy
+		trace.Explanation.Incidents = duringIncidents
+		trace.Explanation.Reclassified = reclassified
+		trace.Explanation.IncidentPolicy = incidentPolicy
+	}
+
+	slog.Debug("parsed trace", "path", name, "columns", len(trace.Columns), "records", len(trace.Records))
+	return trace, nil
+}
+
+// traceSeparator returns the field separator of a trace from its header:
+// tabs as written by Nextflow, or commas for traces converted to CSV
+func traceSeparator(header string) string {
+	if !strings.Contains(header, "\t") && strings.Contains(header, ",") {
+		return ","
+	}
+	return "\t"
+}
+
+// parseHeader splits the header line into column names. Duplicate column
+// names are rejected, as it would be ambiguous which of the values to use.
+func parseHeader(header, sep string) ([]string, error) {
+	columns := strings.Split(header, sep)
+	seen := make(map[string]int)
+	for i, col := range columns {
+		col = strings.TrimSpace(col)
+		columns[i] = col
+		if prev, ok := seen[col]; ok {
+			return nil, fmt.Errorf("duplicate column '%s' in header (columns %d and %d)", col, prev+1, i+1)
+		}
+		seen[col] = i
+	}
+	return columns, nil
+}
+
+// parseRecord converts the fields of a single trace line into a TraceRecord.
+// Fields that cannot be parsed are left at zero value and returned as errors.
+func parseRecord(columns, fields []string) (TraceRecord, []*FieldError) {
+	var rec TraceRecord
+	var errs []*FieldError
+
+	for i, col := range columns {
+		if i >= len(fields) {
+			break
+		}
+		value := strings.TrimSpace(fields[i])
+		// Nextflow writes "-" for values that are not available
+		if value == "" || value == "-" {
+			continue
+		}
+
+		var err error
+		switch col {
+		case "task_id":
+			rec.TaskID = value
+		case "hash":
+			rec.Hash = value
+		case "name":
+			rec.Name = value
+		case "process":
+			rec.Process = value
+		case "tag":
+			rec.Tag = value
+		case "status":
+			rec.Status = value
+		case "exit":
+			rec.Exit = value
+		case "attempt":
+			rec.Attempt, err = strconv.Atoi(value)
+		case "cpus":
+			rec.CPUs, err = strconv.Atoi(value)
+		case "memory":
+			rec.Memory, err = ParseSize(value)
+		case "time":
+			rec.Time, err = ParseDuration(value)
+		case "submit":
+			rec.Submit, err = ParseTimestamp(value)
+		case "start":
+			rec.Start, err = ParseTimestamp(value)
+		case "complete":
+			rec.Complete, err = ParseTimestamp(value)
+		case "duration":
+			rec.Duration, err = ParseDuration(value)
+		case "realtime":
+			rec.Realtime, err = ParseDuration(value)
+		case "%cpu":
+			rec.CPUPercent, err = parseNumber(strings.TrimSpace(strings.TrimSuffix(value, "%")))
+			rec.HasCPUPercent = err == nil
+		case "peak_rss":
+			rec.PeakRSS, err = ParseSize(value)
+		case "peak_vmem":
+			rec.PeakVmem, err = ParseSize(value)
+		case "rchar":
+			rec.Rchar, err = ParseSize(value)
+		case "wchar":
+			rec.Wchar, err = ParseSize(value)
+		case "hostname":
+			rec.Hostname = value
+		case "queue":
+			rec.Queue = value
+		}
+		if err != nil {
+			errs = append(errs, &FieldError{Column: col, Value: value, Err: err})
+		}
+	}
+
+	// Derive process name and tag from the task name when the dedicated
+	// columns are not part of the trace
+	if m := tagSuffix.FindStringSubmatch(rec.Name); m != nil {
+		if rec.Process == "" {
+			rec.Process = m[1]
+		}
+		if rec.Tag == "" {
+			rec.Tag = m[2]
+		}
+	} else if rec.Process == "" {
+		rec.Process = rec.Name
+	}
+
+	if rec.HasCPUPercent {
+		rec.CPUSuspect = checkCPUPercent(rec)
+	}
+
+	return rec, errs
+}
+
+// Thresholds for flagging implausible %cpu measurements
+const (
+	cpuOverTolerance   = 1.1         // allowed excess over cpus × 100%
+	cpuZeroMinDuration = time.Minute // tasks this long cannot really use no CPU at all
+)
+
+// checkCPUPercent returns a description of why the %cpu value of a task is
+// implausible, or an empty string when it looks fine. Values far above the
+// allocated CPUs or exactly zero for long tasks usually point to problems with
+// the task wrapper or cgroup accounting rather than to the task itself.
+func checkCPUPercent(rec TraceRecord) string {
+	if rec.CPUs > 0 && rec.CPUPercent > float64(rec.CPUs)*100*cpuOverTolerance {
+		return fmt.Sprintf("%%cpu %.1f exceeds %d allocated CPUs", rec.CPUPercent, rec.CPUs)
+	}
+	if rec.CPUPercent == 0 && rec.Realtime >= cpuZeroMinDuration && (rec.Status == "" || isSuccess(rec.Status)) {
+		return fmt.Sprintf("%%cpu is 0 for a task running %s", FormatDuration(rec.Realtime))
+	}
+	return ""
+}
+
+// sizeUnits maps memory unit suffixes to their size in bytes.
+// Nextflow uses binary multiples for its memory units.
+var sizeUnits = map[string]float64{
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+	"tb": 1 << 40,
+	"pb": 1 << 50,
+}
+
+// numberPattern matches decimal numbers with either decimal separator and an
+// optional exponent, e.g. "1.5", "1,5", "1.234,5" or "1.2e+05"
+const numberPattern = `[\d][\d\.,]*(?:[eE][+-]?\d+)?`
+
+// parseNumber parses a decimal number written with a dot or a comma as the
+// decimal separator, optionally with thousands separators or in scientific
+// notation. When both separators occur, the last one is the decimal separator;
+// a single comma on its own is treated as a decimal comma.
+func parseNumber(numberStr string) (float64, error) {
+	normalized := numberStr
+	lastDot := strings.LastIndex(normalized, ".")
+	lastComma := strings.LastIndex(normalized, ",")
+	switch {
+	case lastComma >= 0 && lastDot > lastComma:
+		normalized = strings.ReplaceAll(normalized, ",", "")
+	case lastDot >= 0 && lastComma > lastDot:
+		normalized = strings.ReplaceAll(normalized, ".", "")
+		normalized = strings.Replace(normalized, ",", ".", 1)
+	case strings.Count(normalized, ",") == 1:
+		normalized = strings.Replace(normalized, ",", ".", 1)
+	case lastComma >= 0:
+		normalized = strings.ReplaceAll(normalized, ",", "")
+	}
+
+	value, err := strconv.ParseFloat(normalized, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid number %s", numberStr)
+	}
+	return value, nil
+}
+
+// sizePattern matches a size with an optional unit, e.g. "2 GB" or "1024"
+var sizePattern = regexp.MustCompile(`^(` + numberPattern + `)\s*([a-zA-Z]*)$`)
+
+// ParseSize parses memory strings like "2 GB", "512 MB" or "1024" to bytes
+func ParseSize(sizeStr string) (int64, error) {
+	matches := sizePattern.FindStringSubmatch(strings.TrimSpace(sizeStr))
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("unsupported size format: %s", sizeStr)
+	}
+
+	value, err := parseNumber(matches[1])
+	if err != nil {
+		return 0, fmt.Errorf("error parsing size value %s: %w", matches[1], err)
+	}
+
+	unit := strings.ToLower(matches[2])
+	if unit == "" {
+		unit = "b"
+	}
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit: %s", matches[2])
+	}
+
+	bytes := math.Round(value * multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size out of range: %s", sizeStr)
+	}
+	return int64(bytes), nil
+}
+
+// FormatSize renders a byte count using the largest fitting binary unit
+func FormatSize(bytes int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	value := float64(bytes)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+// timestampLayouts lists the date-time formats Nextflow uses in trace files
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+}
+
+// ParseTimestamp parses trace timestamps, either formatted date-times or
+// epoch milliseconds as written when raw trace values are enabled
+func ParseTimestamp(timestampStr string) (time.Time, error) {
+	if millis, err := strconv.ParseInt(timestampStr, 10, 64); err == nil {
+		return time.UnixMilli(millis), nil
+	}
+	for _, layout := range timestampLayouts {
+		if ts, err := time.ParseInLocation(layout, timestampStr, time.Local); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported timestamp format: %s", timestampStr)
+}
+
+// FormatDuration renders a duration in the "1h 2m 3s" style used by Nextflow
+func FormatDuration(d time.Duration) string {
+	return formatDurationUnits(d, durationUnits)
+}
+
+// formatDurationUnits renders a duration like FormatDuration with at most
+// the given number of units
+func formatDurationUnits(d time.Duration, units int) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	d = roundDuration(d.Round(time.Second), units)
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	seconds := int(d.Seconds()) % 60
+
+	var parts []string
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+	if seconds > 0 || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%ds", seconds))
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatSignedDuration renders a duration with an explicit sign
+func FormatSignedDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + FormatDuration(-d)
+	}
+	return "+" + FormatDuration(d)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Build metadata, set at build time via
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = "0.0.0-dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// supportedSchemas lists the trace formats the parsers are verified against
+// by the selfcheck corpus
+var supportedSchemas = []string{
+	"nextflow-trace/19 (default fields)",
+	"nextflow-trace/22 (extended fields)",
+	"nextflow-trace/23 (trace.raw = true)",
+}
+
+// BuildInfo describes the nfu binary as printed by "nfu version"
+type BuildInfo struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	BuildDate string   `json:"build_date,omitempty"`
+	GoVersion string   `json:"go_version"`
+	Schemas   []string `json:"supported_schemas"`
+}
+
+// getBuildInfo returns the build metadata, falling back to the VCS details
+// recorded by the Go toolchain when they were not set via -ldflags
+func getBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Schemas:   supportedSchemas,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// runVersion implements the "version" subcommand
+func runVersion(args []string) error {
+	fs := newFlagSet("version")
+	asJSON := fs.Bool("json", false, "Print build information as JSON")
+	fs.Parse(args)
+
+	info := getBuildInfo()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	fmt.Printf("nfu %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Printf("commit:  %s\n", info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Printf("built:   %s\n", info.BuildDate)
+	}
+	fmt.Printf("go:      %s\n", info.GoVersion)
+	fmt.Printf("schemas: %s\n", strings.Join(info.Schemas, "\n         "))
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+// weblogEvent is a message posted by Nextflow run with -with-weblog. Task
+// events carry the trace fields of the task with raw values: timestamps in
+// epoch milliseconds, durations in milliseconds and memory in bytes.
+type weblogEvent struct {
+	RunName string                     `json:"runName"`
+	RunID   string                     `json:"runId"`
+	Event   string                     `json:"event"`
+	UTCTime string                     `json:"utcTime"`
+	Trace   map[string]json.RawMessage `json:"trace"`
+}
+
+// weblogRecord converts the trace fields of a task event into a record,
+// parsed like a trace written with trace.raw = true, and returns it with its
+// raw fields and their columns
+func weblogRecord(trace map[string]json.RawMessage) (TraceRecord, []string, []*FieldError) {
+	columns := make([]string, 0, len(trace))
+	for col := range trace {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	fields := make([]string, len(columns))
+	for i, col := range columns {
+		value := trace[col]
+		var s string
+		switch {
+		case bytes.Equal(value, []byte("null")):
+			s = "-"
+		case json.Unmarshal(value, &s) == nil:
+		default:
+			s = string(value)
+		}
+		fields[i] = s
+	}
+	rec, errs := parseRecord(columns, fields)
+	rec.Fields = fields
+	return rec, columns, errs
+}
+
+// runServe implements the "serve" subcommand, receiving the events of a
+// running pipeline and writing its completed tasks as "cat" does
+func runServe(args []string) error {
+	fs := newFlagSet("serve")
+	weblog := fs.String("weblog", "", "Address to receive Nextflow -with-weblog events on, e.g. :8000")
+	once := fs.Bool("once", false, "Exit when the run completes")
+	archivePath := fs.String("archive", "", "Append every event received, as posted, to this JSON Lines file")
+	fs.Parse(args)
+
+	if *weblog == "" {
+		return fmt.Errorf("give the address to listen on with --weblog")
+	}
+	var archive *os.File
+	if *archivePath != "" {
+		var err error
+		if archive, err = os.OpenFile(*archivePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+			return fmt.Errorf("error opening event archive: %w", err)
+		}
+		defer archive.Close()
+	}
+
+	var mu sync.Mutex
+	stream := newRecordStream(os.Stdout, outputFormat == "json", false)
+	stream.Flush()
+	done := make(chan struct{})
+	var closeDone sync.Once
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "weblog events are posted", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		var event weblogEvent
+		if err == nil {
+			err = json.Unmarshal(body, &event)
+		}
+		if err != nil {
+			slog.Warn("invalid weblog event", "remote", r.RemoteAddr, "error", err)
+			http.Error(w, "invalid event", http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		// Events are archived one per line and in full, also those not
+		// otherwise used, as a complete record of the run
+		if archive != nil {
+			var line bytes.Buffer
+			json.Compact(&line, body)
+			line.WriteByte('\n')
+			if _, err := archive.Write(line.Bytes()); err != nil {
+				slog.Error("error archiving weblog event", "error", err)
+			}
+		}
+		switch event.Event {
+		case "process_completed":
+			rec, columns, errs := weblogRecord(event.Trace)
+			for _, err := range errs {
+				slog.Warn("malformed field in weblog event", "run", event.RunName, "column", err.Column, "value", err.Value, "error", err.Err)
+			}
+			if err := stream.Write(normalizeRecord(rec, columns)); err != nil {
+				slog.Error(err.Error())
+			}
+			if err := stream.Flush(); err != nil {
+				slog.Error(err.Error())
+			}
+		case "started", "error":
+			slog.Info("run "+event.Event, "run", event.RunName, "id", event.RunID)
+		case "completed":
+			slog.Info("run completed", "run", event.RunName, "id", event.RunID)
+			if *once {
+				closeDone.Do(func() { close(done) })
+			}
+		default:
+			slog.Debug("weblog event", "event", event.Event, "run", event.RunName)
+		}
+	}
+
+	server := &http.Server{Addr: *weblog, Handler: http.HandlerFunc(handler)}
+	errc := make(chan error, 1)
+	go func() { errc <- server.ListenAndServe() }()
+	slog.Info("receiving weblog events", "address", *weblog)
+	select {
+	case err := <-errc:
+		return fmt.Errorf("error receiving weblog events: %w", err)
+	case <-done:
+		return server.Close()
+	}
+}
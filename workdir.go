@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// taskFiles are the files Nextflow writes into every task directory, in the
+// order "workdir-of --files" lists them
+var taskFiles = []string{".command.sh", ".command.run", ".command.out", ".command.err", ".command.log", ".command.trace", ".exitcode"}
+
+// resolveWorkDir returns the directory of the task with the given hash,
+// also shortened as in traces (e.g. "3f/8a2c41"), under the work directory
+func resolveWorkDir(root, hash string) (string, error) {
+	prefix, suffix, ok := strings.Cut(hash, "/")
+	if !ok || !taskDirPrefix.MatchString(prefix) || suffix == "" || strings.Trim(suffix, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("invalid task hash '%s' (expected e.g. 3f/8a2c41)", hash)
+	}
+	dirs, err := filepath.Glob(filepath.Join(root, prefix, suffix+"*"))
+	if err != nil {
+		return "", err
+	}
+	switch len(dirs) {
+	case 0:
+		return "", fmt.Errorf("no task directory for %s under %s", hash, root)
+	case 1:
+		return dirs[0], nil
+	}
+	return "", fmt.Errorf("%s matches %d task directories under %s, give more of the hash", hash, len(dirs), root)
+}
+
+// runWorkdirOf implements the "workdir-of" subcommand, printing the work
+// directory of a task given its hash
+func runWorkdirOf(args []string) error {
+	fs := newFlagSet("workdir-of")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nfu workdir-of [flags] <hash>")
+		fs.PrintDefaults()
+	}
+	workRoot := fs.String("w", "work", "Nextflow work directory")
+	fs.StringVar(workRoot, "work-dir", "work", "Nextflow work directory")
+	files := fs.Bool("files", false, "Also list the .command.* files and the exit code of the task")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	dir, err := resolveWorkDir(*workRoot, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Println(dir)
+	if !*files {
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSIZE\tMODIFIED")
+	for _, name := range taskFiles {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\n", name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, FormatSize(info.Size()), info.ModTime().Format(time.DateTime))
+	}
+	w.Flush()
+	if data, err := os.ReadFile(filepath.Join(dir, ".exitcode")); err == nil {
+		fmt.Printf("\nExit code: %s\n", strings.TrimSpace(string(data)))
+	}
+	return nil
+}